@@ -3,16 +3,20 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // per-chat timezones must resolve even without a system tz database
 
 	"coffeetrix24/internal/bot"
 	"coffeetrix24/internal/config"
 	"coffeetrix24/internal/db"
+	"coffeetrix24/internal/messages"
+	"coffeetrix24/internal/metrics"
 	"coffeetrix24/internal/scheduler"
 	"coffeetrix24/internal/version"
 
@@ -20,18 +24,41 @@ import (
 	"github.com/joho/godotenv"
 )
 
+const defaultDailyTime = "08:00"
+
+// shutdownTimeout bounds how long a stopping process waits for in-flight sends.
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	_ = godotenv.Load()
 	testMode := flag.Bool("test", false, "включить тестовый режим: мгновенное приглашение и окно набора 1 минута")
 	tokenFlag := flag.String("token", "", "токен бота (перекрывает TELEGRAM_BOT_TOKEN)")
 	onceInvite := flag.Bool("once-invite", false, "однократно отправить приглашения сейчас и завершить")
 	showVersion := flag.Bool("version", false, "показать версию и выйти")
+	nextFires := flag.Int("next-fires", 0, "вывести N ближайших срабатываний расписания и выйти (токен не нужен)")
+	checkSessions := flag.Bool("check-sessions", false, "проверить сессии на несогласованность и выйти (токен не нужен)")
+	repair := flag.Bool("repair", false, "вместе с -check-sessions: исправить найденное")
+	simulate := flag.Int("simulate", 0, "провести в SANDBOX_CHAT_ID полную сессию с N тестовыми участниками и выйти")
+	replay := flag.String("replay", "", "прогнать записанные через RECORD_UPDATES обновления без обращения к Telegram и выйти")
+	replayDB := flag.String("replay-db", ":memory:", "вместе с -replay: БД, в которую пишется результат")
 	flag.Parse()
 	if *showVersion {
-		log.Println("coffeetrix24 version", version.Version)
+		log.Println("coffeetrix24 version", version.String())
 		return
 	}
 	cfg := config.FromEnv()
+	if *nextFires > 0 {
+		printNextFires(cfg, *nextFires)
+		return
+	}
+	if *checkSessions {
+		runSessionCheck(cfg, *repair)
+		return
+	}
+	if *replay != "" {
+		runReplay(*replay, *replayDB)
+		return
+	}
 	if *tokenFlag != "" {
 		cfg.Token = *tokenFlag
 	}
@@ -39,18 +66,22 @@ func main() {
 	if cfg.Token == "" {
 		log.Fatal("TELEGRAM_BOT_TOKEN не задан")
 	}
-	log.Printf("startup: version=%s pid=%d", version.Version, os.Getpid())
+	log.Printf("startup: version=%s pid=%d", version.String(), os.Getpid())
 	st, err := db.Open(cfg.DatabasePath)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer st.DB.Close()
+	if cfg.SandboxChatID != 0 {
+		st.SandboxChatID = cfg.SandboxChatID
+		log.Printf("startup: sandbox mode, only chat=%d is processed", cfg.SandboxChatID)
+	}
 	// сохранить токен в таблицу cred
 	if err := st.UpsertToken(cfg.Token); err != nil {
 		log.Fatal(err)
 	}
 	// гарантировать настройки
-	if err := st.EnsureSettings("08:00"); err != nil {
+	if err := st.EnsureSettings(defaultDailyTime); err != nil {
 		log.Fatal(err)
 	}
 	var jm string
@@ -69,12 +100,40 @@ func main() {
 
 	b := bot.New(api, st)
 	b.TestMode = *testMode
+	b.InviteCooldown = cfg.InviteCooldown
+	b.OwnerID = cfg.OwnerID
+	b.OwnerBypass = cfg.OwnerBypass
+	b.InviteJitter = cfg.InviteJitter
+	b.SignupWindow = cfg.SignupWindow
+	if cfg.RecordUpdates != "" {
+		rec, err := b.RecordUpdates(cfg.RecordUpdates)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer rec.Close()
+		log.Printf("startup: recording updates to %s", cfg.RecordUpdates)
+	}
+	if tmpl, err := bot.ParseSettingsTemplate(cfg.DefaultChatSettings); err != nil {
+		log.Printf("config: DEFAULT_CHAT_SETTINGS ignored: %v", err)
+	} else {
+		b.NewChatSettings = tmpl
+	}
 	if *testMode {
 		b.SignupWindow = time.Minute
 	}
+	if *simulate > 0 {
+		if cfg.SandboxChatID == 0 {
+			log.Fatal("-simulate требует SANDBOX_CHAT_ID")
+		}
+		if err := b.Simulate(cfg.SandboxChatID, *simulate); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("simulate: done")
+		return
+	}
 	if *onceInvite {
 		log.Println("manual once-invite trigger start")
-		b.SendDailyInvites()
+		b.SendAllInvites()
 		log.Println("manual once-invite trigger done; exiting")
 		return
 	}
@@ -84,18 +143,152 @@ func main() {
 
 	sch := scheduler.New(st)
 	sch.OnDailyInvite = func() { b.SendDailyInvites() }
+	sch.OnZonedInvite = b.SpreadInvites
 	sch.OnCloseSessions = func(ids []int64) {
 		for _, id := range ids {
+			if b.AutoExtend(id) {
+				continue
+			}
 			b.CloseAndPublish(id)
 		}
 	}
+	sch.OnRemind = func(ids []int64) {
+		for _, id := range ids {
+			b.Remind(id)
+		}
+	}
+	sch.MaxSessionAge = cfg.SessionMaxAge
+	sch.RetentionPeriod = cfg.AnonymizeAfter
+	sch.MinNotice = cfg.ScheduleMinNotice
+	sch.CatchUpWindow = cfg.CatchUpWindow
+	sch.OnAbandonSessions = func(ids []int64) {
+		b.NotifyOwner(fmt.Sprintf(messages.OwnerAbandonedSessions, ids))
+	}
+	b.SetCloseInterval = sch.SetCloseInterval
 	if *testMode {
 		sch.DisableDaily = true
-		sch.CloseInterval = 5 * time.Second // 5s polling to close
+		_ = sch.SetCloseInterval(5 * time.Second) // 5s polling to close
 		// немедленно отправить приглашение во все чаты для удобства теста
-		b.SendDailyInvites()
+		b.SendAllInvites()
 	}
 	sch.Start(ctx)
+	if cfg.HTTPAddr != "" {
+		b.PublicURL = cfg.PublicURL
+		go b.ServeHTTP(ctx, cfg.HTTPAddr)
+	}
+	if cfg.MetricsAddr != "" {
+		go metrics.Serve(ctx, cfg.MetricsAddr)
+	}
+	if cfg.HealthAddr != "" {
+		go b.ServeHealth(ctx, cfg.HealthAddr)
+	}
+
+	if cfg.WebhookURL != "" {
+		if err := b.StartWebhook(ctx, cfg.WebhookURL, cfg.WebhookAddr); err != nil {
+			log.Fatal("webhook: ", err)
+		}
+	} else {
+		b.Start(ctx)
+	}
 
-	b.Start(ctx)
+	// дождаться отправок, начатых до сигнала, прежде чем закрыть БД
+	log.Println("shutdown: waiting for in-flight work")
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer drainCancel()
+	if err := sch.Wait(drainCtx); err != nil {
+		log.Println("shutdown: scheduler did not stop in time:", err)
+	}
+	if err := b.Shutdown(drainCtx); err != nil {
+		log.Println("shutdown: in-flight work did not finish in time:", err)
+	}
+}
+
+// printNextFires prints the upcoming schedule computed from the settings in the DB: the next n
+// fires of each chat in its timezone, with the reason when the chat's invite will be skipped.
+func printNextFires(cfg config.Config, n int) {
+	st, err := db.Open(cfg.DatabasePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer st.DB.Close()
+	daily, err := st.GetDailyTime()
+	if err != nil {
+		// settings row is created on the first normal start
+		daily = defaultDailyTime
+	}
+	fmt.Printf("daily_time=%s (UTC; chats with their own timezone use local time)\n", daily)
+	// settings and skip checks only read the DB, so the bot needs no Telegram connection here
+	planned, err := bot.New(nil, st).NextInvites(time.Now(), n)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(planned) == 0 {
+		fmt.Println("no active chats; bot-wide schedule:")
+		for i, t := range scheduler.NextFires(daily, time.Now(), n) {
+			fmt.Printf("%2d. %s %s\n", i+1, t.Format("2006-01-02 15:04 MST"), t.Weekday())
+		}
+		return
+	}
+	i := 0
+	for j, p := range planned {
+		if j == 0 || p.ChatID != planned[j-1].ChatID {
+			fmt.Printf("chat %d:\n", p.ChatID)
+			i = 0
+		}
+		i++
+		line := fmt.Sprintf("%2d. %s %s", i, p.At.Format("2006-01-02 15:04 MST"), p.At.Weekday())
+		if p.Skip != "" {
+			line += " (skipped: " + p.Skip + ")"
+		}
+		fmt.Println(line)
+	}
+}
+
+// runSessionCheck reports inconsistent sessions; with repair it applies the safe fixes.
+func runSessionCheck(cfg config.Config, repair bool) {
+	st, err := db.Open(cfg.DatabasePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer st.DB.Close()
+	rep, err := st.RepairSessions(context.Background(), time.Now(), repair)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("closed sessions with future deadline (left as is): %v\n", rep.ClosedFutureDeadline)
+	fmt.Printf("stale open sessions without invite: %v\n", rep.StaleNoInvite)
+	for t, n := range rep.Orphans {
+		fmt.Printf("orphan rows in %s: %d\n", t, n)
+	}
+	if rep.Repaired {
+		fmt.Println("repaired: stale sessions abandoned, orphan rows deleted")
+	} else if len(rep.StaleNoInvite) > 0 || len(rep.Orphans) > 0 {
+		fmt.Println("run with -repair to fix")
+	}
+}
+
+// runReplay feeds recorded updates through the bot against dbPath, answering Telegram calls locally.
+func runReplay(path, dbPath string) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	st, err := db.Open(dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer st.DB.Close()
+	if err := st.EnsureSettings(defaultDailyTime); err != nil {
+		log.Fatal(err)
+	}
+	b, err := bot.NewReplayBot(st)
+	if err != nil {
+		log.Fatal(err)
+	}
+	n, err := b.Replay(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("replayed %d updates into %s\n", n, dbPath)
 }
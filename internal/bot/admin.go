@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// adminCacheTTL is how long a GetChatMember answer is reused. Short enough that a demoted
+// admin loses access quickly, long enough that a burst of commands costs one API call.
+const adminCacheTTL = 2 * time.Minute
+
+type adminKey struct{ chatID, userID int64 }
+
+type adminEntry struct {
+	admin bool
+	at    time.Time
+}
+
+// adminCache remembers recent admin checks. Errors are not cached.
+type adminCache struct {
+	mu      sync.Mutex
+	entries map[adminKey]adminEntry
+}
+
+func (c *adminCache) get(k adminKey, now time.Time) (admin, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[k]
+	if !ok || now.Sub(e.at) > adminCacheTTL {
+		return false, false
+	}
+	return e.admin, true
+}
+
+func (c *adminCache) put(k adminKey, admin bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[adminKey]adminEntry{}
+	}
+	for key, e := range c.entries {
+		if now.Sub(e.at) > adminCacheTTL {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[k] = adminEntry{admin: admin, at: now}
+}
+
+// isAdmin reports whether the user administers the chat. A private chat has the user's own ID
+// and no admins, so there the user is its own admin. With OwnerBypass the owner is always one.
+func (b *Bot) isAdmin(chatID, userID int64) (bool, error) {
+	if chatID == userID || (b.OwnerBypass && b.OwnerID != 0 && userID == b.OwnerID) {
+		return true, nil
+	}
+	k := adminKey{chatID, userID}
+	now := time.Now()
+	if admin, ok := b.admins.get(k, now); ok {
+		return admin, nil
+	}
+	member, err := b.API.GetChatMember(tgbotapi.GetChatMemberConfig{ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID}})
+	if err != nil {
+		return false, err
+	}
+	admin := member.IsAdministrator() || member.IsCreator()
+	b.admins.put(k, admin, now)
+	return admin, nil
+}
+
+// requireAdmin replies with a refusal and returns false when the sender is not a chat admin.
+func (b *Bot) requireAdmin(m *tgbotapi.Message) bool {
+	if m.From == nil {
+		return false
+	}
+	admin, err := b.isAdmin(m.Chat.ID, m.From.ID)
+	if err != nil {
+		log.Printf("admin: get chat member failed chat=%d user=%d err=%v", m.Chat.ID, m.From.ID, err)
+		b.reply(m, messages.AdminCheckFailed)
+		return false
+	}
+	if !admin {
+		b.reply(m, messages.AdminOnly)
+	}
+	return admin
+}
@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestIsAdmin(t *testing.T) {
+	const chatID, owner = -100, 1
+	tests := []struct {
+		name        string
+		user        int64
+		chatAdmin   bool
+		ownerBypass bool
+		want        bool
+		wantCalls   int
+	}{
+		{"chat admin", 42, true, false, true, 1},
+		{"member", 42, false, false, false, 1},
+		{"owner without bypass", owner, false, false, false, 1},
+		{"owner with bypass", owner, false, true, true, 0},
+		{"private chat", chatID, false, false, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			b.OwnerID, b.OwnerBypass = owner, tt.ownerBypass
+			if tt.chatAdmin {
+				fake.respond = asAdmin
+			}
+			// the second check is answered from the cache
+			for i := 0; i < 2; i++ {
+				got, err := b.isAdmin(chatID, tt.user)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Errorf("check %d: isAdmin = %v, want %v", i+1, got, tt.want)
+				}
+			}
+			if n := len(fake.sent("getChatMember")); n != tt.wantCalls {
+				t.Errorf("getChatMember calls = %d, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIsAdminCacheExpiry(t *testing.T) {
+	const chatID, user = -100, 42
+	b, fake := newTestBot(t)
+	fake.respond = asAdmin
+	// a cached answer older than the TTL is asked again
+	b.admins.put(adminKey{chatID, user}, false, time.Now().Add(-adminCacheTTL-time.Second))
+	admin, err := b.isAdmin(chatID, user)
+	if err != nil || !admin {
+		t.Fatalf("isAdmin = %v, %v; want true", admin, err)
+	}
+	// a fresh one is not
+	b.admins.put(adminKey{chatID, user}, false, time.Now())
+	if admin, _ := b.isAdmin(chatID, user); admin {
+		t.Error("fresh cached denial was not used")
+	}
+	if n := len(fake.sent("getChatMember")); n != 1 {
+		t.Errorf("getChatMember calls = %d, want 1", n)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	const chatID, user = -100, 42
+	tests := []struct {
+		name      string
+		respond   func(string, map[string]string) *tgbotapi.APIResponse
+		want      bool
+		wantReply string
+		wantCalls int
+	}{
+		{"admin", asAdmin, true, "", 1},
+		{"member denied", nil, false, messages.AdminOnly, 1},
+		// a failed check is not cached, so the second command asks again
+		{"check failed", func(method string, _ map[string]string) *tgbotapi.APIResponse {
+			if method == "getChatMember" {
+				return apiErrorResponse(400, "Bad Request: chat not found", 0)
+			}
+			return nil
+		}, false, messages.AdminCheckFailed, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			fake.respond = tt.respond
+			for i := 0; i < 2; i++ {
+				if got := b.requireAdmin(testCommand(chatID, user, "/config")); got != tt.want {
+					t.Errorf("requireAdmin = %v, want %v", got, tt.want)
+				}
+			}
+			replies := fake.sent("sendMessage")
+			if tt.wantReply == "" {
+				if len(replies) != 0 {
+					t.Errorf("replies = %d, want none", len(replies))
+				}
+			} else if len(replies) != 2 || replies[1]["text"] != tt.wantReply {
+				t.Errorf("replies = %v, want two of %q", replies, tt.wantReply)
+			}
+			if n := len(fake.sent("getChatMember")); n != tt.wantCalls {
+				t.Errorf("getChatMember calls = %d, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -2,14 +2,19 @@ package bot
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
+	"html"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"coffeetrix24/internal/db"
 	"coffeetrix24/internal/logic"
 	"coffeetrix24/internal/messages"
+	"coffeetrix24/internal/metrics"
+	"coffeetrix24/internal/scheduler"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -20,20 +25,152 @@ type Bot struct {
 	// runtime options
 	TestMode     bool
 	SignupWindow time.Duration
+	// InviteCooldown blocks a new invite in a chat sooner than this after the previous one (0 disables).
+	InviteCooldown time.Duration
+	// InviteJitter spreads scheduled invites over a random delay up to this per chat (0 disables).
+	InviteJitter time.Duration
+	// OwnerID is the bot operator's Telegram user ID for owner-only commands.
+	OwnerID int64
+	// OwnerBypass lets the owner run admin commands in any chat without being its admin.
+	OwnerBypass bool
+	// StartedAt is when the process started, reported by /uptime.
+	StartedAt time.Time
+	// NewChatSettings (column → value) seeds the settings of chats the bot is added to.
+	NewChatSettings map[string]interface{}
+	// PublicURL is the external base URL of the HTTP server, used in /ical links ("" disables /ical).
+	PublicURL string
+	// SetCloseInterval changes how often due sessions are closed, for /closeinterval (nil disables it).
+	SetCloseInterval func(time.Duration) error
+
+	// secret signs confirmation tokens for destructive commands; regenerated on every start.
+	secret []byte
+	// callbacks holds recently handled callback query IDs so a redelivered one is not applied twice.
+	callbacks *recentSet
+	admins    adminCache
+	// groupSeed, when set, seeds the grouping shuffle in place of the clock.
+	groupSeed func() int64
+	// recorder, when set, keeps a copy of every polled update (RECORD_UPDATES).
+	recorder *updateRecorder
+	feed     updateFeed
+	health   telegramHealth
+	// inflight counts sends and updates in progress; stop is closed by Shutdown.
+	inflight sync.WaitGroup
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// seenCallbacks bounds how many callback query IDs are remembered for deduplication.
+const seenCallbacks = 1024
+
+func New(api *tgbotapi.BotAPI, store *db.Store) *Bot {
+	secret := make([]byte, 16)
+	_, _ = rand.Read(secret)
+	return &Bot{API: api, Store: store, StartedAt: time.Now(), secret: secret, callbacks: newRecentSet(seenCallbacks), stop: make(chan struct{})}
 }
 
-func New(api *tgbotapi.BotAPI, store *db.Store) *Bot { return &Bot{API: api, Store: store} }
+// maxUpdateAttempts bounds how many times a failing update is re-polled before it is skipped.
+const maxUpdateAttempts = 3
 
+// Start long-polls Telegram with a manually managed offset persisted in the DB, so that after
+// a restart updates are neither lost nor processed twice. The offset moves past an update only
+// once it was handled.
 func (b *Bot) Start(ctx context.Context) {
-	updates := b.API.GetUpdatesChan(tgbotapi.UpdateConfig{Timeout: 30})
+	b.loadUpdateOffset()
+	// a webhook left from an earlier run would make getUpdates fail
+	if _, err := b.API.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		log.Println("updates: delete webhook error:", err)
+	}
+	log.Printf("updates: polling from offset=%d", b.feed.offset)
+	type pollResult struct {
+		updates []tgbotapi.Update
+		err     error
+	}
 	for {
+		res := make(chan pollResult, 1)
+		go func(offset int) {
+			upds, err := b.API.GetUpdates(tgbotapi.UpdateConfig{Offset: offset, Timeout: 30})
+			res <- pollResult{upds, err}
+		}(b.feed.offset)
+		var r pollResult
 		select {
 		case <-ctx.Done():
 			return
-		case upd := <-updates:
-			b.handleUpdate(upd)
+		case r = <-res:
+		}
+		if r.err != nil {
+			log.Println("updates: poll error:", r.err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(3 * time.Second):
+			}
+			continue
+		}
+		for _, upd := range r.updates {
+			if !b.deliver(upd) {
+				break
+			}
+		}
+	}
+}
+
+// updateFeed is the delivery state shared by polling and the webhook: the persisted offset of the
+// next update to handle and how many times the current one has failed.
+type updateFeed struct {
+	mu       sync.Mutex
+	offset   int
+	attempts int
+}
+
+func (b *Bot) loadUpdateOffset() {
+	offset, err := b.Store.GetUpdateOffset()
+	if err != nil {
+		log.Println("updates: load offset error:", err)
+	}
+	b.feed.mu.Lock()
+	b.feed.offset = offset
+	b.feed.mu.Unlock()
+}
+
+// deliver handles an update from either transport, one at a time, and reports whether it is done
+// with: handled, skipped after maxUpdateAttempts failures, or already handled before. When it is
+// not, the transport has to deliver it again.
+func (b *Bot) deliver(upd tgbotapi.Update) bool {
+	defer b.track()()
+	f := &b.feed
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if upd.UpdateID < f.offset {
+		return true
+	}
+	if b.recorder != nil && f.attempts == 0 {
+		b.recorder.record(upd)
+	}
+	if err := b.processUpdate(upd); err != nil {
+		f.attempts++
+		if f.attempts < maxUpdateAttempts {
+			log.Printf("updates: update=%d failed attempt=%d, will retry: %v", upd.UpdateID, f.attempts, err)
+			return false
 		}
+		log.Printf("updates: update=%d failed %d times, skipping: %v", upd.UpdateID, f.attempts, err)
 	}
+	f.attempts = 0
+	f.offset = upd.UpdateID + 1
+	if err := b.Store.SetUpdateOffset(f.offset); err != nil {
+		log.Println("updates: save offset error:", err)
+	}
+	return true
+}
+
+// processUpdate runs handleUpdate, turning a panic into an error so the offset is not advanced.
+func (b *Bot) processUpdate(upd tgbotapi.Update) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	b.handleUpdate(upd)
+	return nil
 }
 
 func (b *Bot) handleUpdate(upd tgbotapi.Update) {
@@ -42,7 +179,34 @@ func (b *Bot) handleUpdate(upd tgbotapi.Update) {
 		return
 	}
 	if cb := upd.CallbackQuery; cb != nil {
+		b.rememberUser(cb.From)
 		b.onCallback(cb)
+		return
+	}
+	if m := upd.Message; m != nil {
+		b.rememberUser(m.From)
+		// the old group gets migrate_to_chat_id, the new supergroup migrate_from_chat_id
+		if m.MigrateToChatID != 0 {
+			b.onChatMigrated(m.Chat.ID, m.MigrateToChatID)
+			return
+		}
+		if m.MigrateFromChatID != 0 {
+			b.onChatMigrated(m.MigrateFromChatID, m.Chat.ID)
+			return
+		}
+		if m.IsCommand() {
+			b.onCommand(m)
+		}
+	}
+}
+
+// rememberUser keeps the user directory current so admin commands can resolve @usernames.
+func (b *Bot) rememberUser(u *tgbotapi.User) {
+	if u == nil || u.IsBot {
+		return
+	}
+	if err := b.Store.RememberUser(u.ID, u.UserName, userDisplayName(u)); err != nil {
+		log.Printf("directory: update failed user=%d err=%v", u.ID, err)
 	}
 }
 
@@ -52,12 +216,43 @@ func (b *Bot) onMyChatMember(m tgbotapi.ChatMemberUpdated) {
 	if status == "member" || status == "administrator" || status == "creator" {
 		b.onAddedToGroup(m.Chat.ID, m.Chat.Title)
 	}
+	if status == "left" || status == "kicked" {
+		b.onRemovedFromGroup(m.Chat.ID, status)
+	}
+}
+
+// onChatMigrated moves the chat's data to its supergroup ID so invites keep flowing there.
+// Telegram notifies both chats; the second call finds nothing left to move.
+func (b *Bot) onChatMigrated(oldID, newID int64) {
+	moved, err := b.Store.MigrateChatID(oldID, newID)
+	if err != nil {
+		log.Printf("migrate: failed old=%d new=%d err=%v", oldID, newID, err)
+		return
+	}
+	if moved {
+		log.Printf("migrate: chat old=%d moved to new=%d", oldID, newID)
+	}
+}
+
+// onRemovedFromGroup stops invites to a chat the bot left or was kicked from. Its history stays,
+// and adding the bot back resumes invites.
+func (b *Bot) onRemovedFromGroup(chatID int64, status string) {
+	abandoned, err := b.Store.DeactivateChat(context.Background(), chatID)
+	if err != nil {
+		log.Printf("removed: deactivate failed chat=%d err=%v", chatID, err)
+		return
+	}
+	log.Printf("removed: chat=%d status=%s abandoned sessions=%v", chatID, status, abandoned)
 }
 
 func (b *Bot) onAddedToGroup(chatID int64, title string) {
 	_ = b.Store.UpsertChat(chatID, title)
+	if err := b.Store.SeedChatSettings(chatID, b.NewChatSettings); err != nil {
+		log.Printf("added: seeding settings failed chat=%d err=%v", chatID, err)
+	}
 	txt := messages.IntroMessage
 	msg := tgbotapi.NewMessage(chatID, txt)
+	msg.ReplyMarkup = setupStartKeyboard()
 	_, _ = b.API.Send(msg)
 	if b.TestMode {
 		// в тестовом режиме сразу отправляем приглашение
@@ -65,147 +260,432 @@ func (b *Bot) onAddedToGroup(chatID int64, title string) {
 	}
 }
 
+// SendAllInvites sends today's invites to every chat right away, whatever its timezone.
+func (b *Bot) SendAllInvites() {
+	log.Println("daily: begin scanning chats for invites")
+	chatIDs, err := b.Store.ChatIDs()
+	if err != nil {
+		log.Println("daily: query chats error:", err)
+		return
+	}
+	b.SendInvites(chatIDs)
+}
+
+// SendDailyInvites sends today's invites to the chats scheduled in UTC. Chats with their own
+// timezone are left to SpreadInvites at their local time.
 func (b *Bot) SendDailyInvites() {
-	start := time.Now()
 	log.Println("daily: begin scanning chats for invites")
-	rows, err := b.Store.DB.Queryx("SELECT chat_id FROM chats")
+	chatIDs, err := b.Store.ChatIDs()
 	if err != nil {
 		log.Println("daily: query chats error:", err)
 		return
 	}
-	defer rows.Close()
-	var total, sent, skipped int
-	for rows.Next() {
-		var chatID int64
-		if err := rows.Scan(&chatID); err != nil {
-			log.Println("daily: scan chat_id error:", err)
-			continue
-		}
-		total++
-		if b.sendInviteToChat(chatID) {
-			sent++
-		} else {
-			skipped++
+	zoned, err := b.Store.ChatTimezones()
+	if err != nil {
+		log.Println("daily: query timezones error:", err)
+	}
+	utc := chatIDs[:0:0]
+	for _, chatID := range chatIDs {
+		if _, ok := zoned[chatID]; !ok {
+			utc = append(utc, chatID)
 		}
 	}
-	log.Printf("daily: done chats=%d sent=%d skipped=%d elapsed=%s", total, sent, skipped, time.Since(start))
+	b.SpreadInvites(utc)
+}
+
+// SendInvites sends today's invites to the given chats and records the run.
+func (b *Bot) SendInvites(chatIDs []int64) {
+	defer b.track()()
+	start := time.Now()
+	run := db.DailyRun{At: start.UTC(), Chats: len(chatIDs)}
+	for _, chatID := range chatIDs {
+		countOutcome(&run, b.sendInviteToChat(chatID))
+	}
+	b.finishRun(run, start)
+}
+
+// finishRun logs and records an invite run that began at start.
+func (b *Bot) finishRun(run db.DailyRun, start time.Time) {
+	run.Elapsed = time.Since(start)
+	log.Printf("daily: done chats=%d sent=%d skipped=%d failed=%d elapsed=%s", run.Chats, run.Sent, run.Skipped, run.Failed, run.Elapsed)
+	if err := b.Store.SetDailyRun(run); err != nil {
+		log.Println("daily: save run error:", err)
+	}
 }
 
-// sendInviteToChat returns true if it actually sent a new invite message.
-func (b *Bot) sendInviteToChat(chatID int64) bool {
+// inviteOutcome is what sendInviteToChat did for a chat.
+type inviteOutcome int
+
+// countOutcome adds a chat's outcome to the run's tallies.
+func countOutcome(run *db.DailyRun, o inviteOutcome) {
+	switch o {
+	case inviteSent:
+		run.Sent++
+	case inviteSkipped:
+		run.Skipped++
+	default:
+		run.Failed++
+	}
+}
+
+const (
+	inviteSent inviteOutcome = iota
+	// inviteSkipped: nothing to do, e.g. today's invite went out already or the cooldown applies
+	inviteSkipped
+	inviteFailed
+)
+
+// sendInviteToChat sends today's invite to the chat unless it is not due.
+func (b *Bot) sendInviteToChat(chatID int64) inviteOutcome {
 	now := time.Now().UTC()
-	date := now.Format("2006-01-02")
-	// если на сегодня уже отправляли приглашение (invite_message_id не NULL), не дублировать
-	if id, inviteID, err := b.Store.GetSessionByChatDate(chatID, date); err == nil && id != 0 && inviteID.Valid {
-		log.Printf("daily: skip existing invite chat=%d date=%s session=%d inviteMsgID=%d", chatID, date, id, inviteID.Int64)
-		return false
-	}
-	window := b.SignupWindow
-	if window == 0 {
-		window = 30 * time.Minute
-	}
-	deadline := now.Add(window)
-	sessionID, err := b.Store.CreateOrGetTodaySession(chatID, date, deadline)
+	cfg, err := b.EffectiveSettings(chatID)
+	if err != nil {
+		log.Printf("daily: settings lookup failed chat=%d err=%v", chatID, err)
+	}
+	date, slot := chatDate(cfg, now), chatSlot(cfg, now)
+	if reason := b.skipReason(chatID, cfg, now); reason != "" {
+		log.Printf("daily: skip invite chat=%d reason=%s date=%s days=%s", chatID, reason, date, cfg.InviteDays)
+		return inviteSkipped
+	}
+	// если для этого времени сегодня уже отправляли приглашение (invite_message_id не NULL), не дублировать
+	if id, inviteID, err := b.Store.GetSessionByChatDate(chatID, date, slot); err == nil && id != 0 && inviteID.Valid {
+		log.Printf("daily: skip existing invite chat=%d date=%s slot=%d session=%d inviteMsgID=%d", chatID, date, slot, id, inviteID.Int64)
+		return inviteSkipped
+	}
+	if cfg.InviteCooldown > 0 {
+		last, ok, err := b.Store.LastInviteAt(chatID)
+		if err != nil {
+			log.Printf("daily: last invite lookup failed chat=%d err=%v", chatID, err)
+		} else if ok && now.Sub(last.UTC()) < cfg.InviteCooldown {
+			log.Printf("daily: skip invite chat=%d reason=cooldown last=%s cooldown=%s", chatID, last.UTC().Format(time.RFC3339), cfg.InviteCooldown)
+			return inviteSkipped
+		}
+	}
+	// rendered before the session exists so today's empty session does not count as missed
+	text := b.inviteText(chatID, cfg)
+	deadline := now.Add(cfg.SignupWindow)
+	sessionID, err := b.Store.CreateOrGetTodaySession(chatID, date, slot, deadline)
 	if err != nil {
-		log.Printf("session create error chat=%d date=%s deadline=%s err=%v", chatID, date, deadline.Format(time.RFC3339), err)
-		return false
+		log.Printf("session create error chat=%d date=%s slot=%d deadline=%s err=%v", chatID, date, slot, deadline.Format(time.RFC3339), err)
+		return inviteFailed
 	}
 
-	btn := tgbotapi.NewInlineKeyboardButtonData(messages.ImInButton, fmt.Sprintf("join:%d", sessionID))
-	kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(btn))
-	msg := tgbotapi.NewMessage(chatID, messages.DailyInvite)
-	msg.ReplyMarkup = kb
-	resp, err := b.API.Send(msg)
+	// participants of a round cancelled under pair_only=carry start this one already signed up,
+	// unless they opted out of today with /skip
+	carried, err := b.Store.TakeCarriedOver(chatID, sessionID, date)
+	if err != nil {
+		log.Printf("daily: carry over failed chat=%d session=%d err=%v", chatID, sessionID, err)
+	}
+
+	resp, err := b.sendInvite(chatID, inviteKeyboard(sessionID, carried, cfg, cfg.ExtendVotes > 0), text, cfg)
 	if err == nil {
 		if dbErr := b.Store.SetInviteMessageID(sessionID, resp.MessageID); dbErr != nil {
 			log.Printf("daily: failed to set invite_message_id chat=%d session=%d msg=%d err=%v", chatID, sessionID, resp.MessageID, dbErr)
 		}
 		log.Printf("daily: sent invite chat=%d session=%d msgID=%d deadline=%s", chatID, sessionID, resp.MessageID, deadline.Format(time.RFC3339))
-		return true
+		if cfg.PinInvite {
+			b.pinInvite(chatID, resp.MessageID)
+		}
+		metrics.InvitesSent.Inc()
+		return inviteSent
 	}
 	log.Printf("daily: telegram send failed chat=%d session=%d err=%v", chatID, sessionID, err)
-	return false
+	return inviteFailed
 }
 
+// Reasons returned by skipReason.
+const (
+	skipWeekday = "weekday"
+	skipPaused  = "paused"
+	skipHoliday = "holiday"
+)
+
+// skipReason tells why the chat gets no invite at t even though its schedule fires, or "" when it
+// gets one: a day outside /set days, a /pause, or a holiday. Failed lookups are logged and do not
+// skip.
+func (b *Bot) skipReason(chatID int64, cfg ChatConfig, t time.Time) string {
+	loc := cfg.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	if !cfg.InviteDays.allows(t.In(loc).Weekday()) {
+		return skipWeekday
+	}
+	if paused, err := b.Store.ChatPaused(chatID); err != nil {
+		log.Printf("daily: pause lookup failed chat=%d err=%v", chatID, err)
+	} else if paused {
+		return skipPaused
+	}
+	date := chatDate(cfg, t)
+	if holiday, err := b.Store.IsHoliday(chatID, date); err != nil {
+		log.Printf("daily: holiday lookup failed chat=%d date=%s err=%v", chatID, date, err)
+	} else if holiday {
+		return skipHoliday
+	}
+	return ""
+}
+
+// PlannedInvite is an upcoming fire of a chat's schedule. Skip is the skipReason when the chat
+// will get no invite then.
+type PlannedInvite struct {
+	ChatID int64
+	At     time.Time
+	Skip   string
+}
+
+// NextInvites lists the next n fires of every active chat's schedule after from, in the chat's
+// timezone, marking those sendInviteToChat will skip. Later checks such as the cooldown or an
+// invite already sent are not predicted.
+func (b *Bot) NextInvites(from time.Time, n int) ([]PlannedInvite, error) {
+	chatIDs, err := b.Store.ChatIDs()
+	if err != nil {
+		return nil, err
+	}
+	var res []PlannedInvite
+	for _, chatID := range chatIDs {
+		cfg, err := b.EffectiveSettings(chatID)
+		if err != nil {
+			log.Printf("daily: settings lookup failed chat=%d err=%v", chatID, err)
+		}
+		for _, t := range scheduler.NextFiresIn(cfg.DailyTime, from, n, cfg.Location) {
+			res = append(res, PlannedInvite{ChatID: chatID, At: t, Skip: b.skipReason(chatID, cfg, t)})
+		}
+	}
+	return res, nil
+}
+
+// sendInvite posts the invite with the join keyboard, honouring the chat's invite media.
+// A photo carries the text as caption; a sticker cannot have a caption, so it goes first
+// and the text with the keyboard follows. The returned message is the one with the keyboard.
+func (b *Bot) sendInvite(chatID int64, kb tgbotapi.InlineKeyboardMarkup, text string, cfg ChatConfig) (tgbotapi.Message, error) {
+	kind, fileID, _ := strings.Cut(cfg.InviteMedia, ":")
+	switch {
+	case kind == "photo" && fileID != "":
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(fileID))
+		photo.Caption = text
+		photo.ParseMode = tgbotapi.ModeHTML
+		photo.ReplyMarkup = kb
+		resp, err := b.sendRetrying(photo)
+		if err == nil {
+			return resp, nil
+		}
+		log.Printf("daily: invite photo failed chat=%d, falling back to text: %v", chatID, err)
+	case kind == "sticker" && fileID != "":
+		if _, err := b.sendRetrying(tgbotapi.NewSticker(chatID, tgbotapi.FileID(fileID))); err != nil {
+			log.Printf("daily: invite sticker failed chat=%d: %v", chatID, err)
+		}
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = kb
+	return b.sendRetrying(msg)
+}
+
+// inviteKeyboard builds the join button; the label carries the participant count once someone joined.
+// A leave button follows when the chat allows leaving; extendable adds the button for participants
+// to ask for more time.
+func inviteKeyboard(sessionID int64, count int, cfg ChatConfig, extendable bool) tgbotapi.InlineKeyboardMarkup {
+	label := joinLabel(cfg)
+	if count > 0 {
+		label = fmt.Sprintf(messages.ImInButtonCount, label, count)
+	}
+	row := []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("join:%d", sessionID))}
+	if cfg.AllowLeave {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(messages.LeaveButton, fmt.Sprintf("leave:%d", sessionID)))
+	}
+	if extendable {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(messages.ExtendButton, fmt.Sprintf("extend:%d", sessionID)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row)
+}
+
+// joinLabel is the join button text with the chat's emoji, if any.
+func joinLabel(cfg ChatConfig) string {
+	if cfg.InviteEmoji == "" {
+		return messages.ImInButton
+	}
+	return messages.ImInButton + " " + cfg.InviteEmoji
+}
+
+// refreshInviteCount recomputes the participant count from the DB and shows it on the invite button.
+func (b *Bot) refreshInviteCount(sessionID int64) (int, error) {
+	n, err := b.Store.CountParticipants(sessionID)
+	if err != nil {
+		return 0, err
+	}
+	b.editInvite(sessionID, func(chatID int64, msgID int) tgbotapi.Chattable {
+		cfg, _ := b.EffectiveSettings(chatID)
+		return tgbotapi.NewEditMessageReplyMarkup(chatID, msgID, inviteKeyboard(sessionID, n, cfg, b.extendable(cfg, sessionID)))
+	})
+	return n, nil
+}
+
+// inviteBaseHTML is the escaped invite text. It is the same for every chat, so it is rendered
+// once instead of per chat on each daily run; chats without mentions share the string as is.
+var inviteBaseHTML = html.EscapeString(messages.DailyInvite)
+
+// inviteText renders the invite as HTML, mentioning lapsed participants when the chat opted in.
+func (b *Bot) inviteText(chatID int64, cfg ChatConfig) string {
+	if cfg.LapsedMentions <= 0 {
+		return inviteBaseHTML
+	}
+	lapsed, err := b.Store.LapsedParticipants(chatID, cfg.LapsedAfter)
+	if err != nil {
+		log.Printf("daily: lapsed participants lookup failed chat=%d err=%v", chatID, err)
+		return inviteBaseHTML
+	}
+	if cfg.NoShowThreshold > 0 {
+		lapsed = b.dropNoShows(chatID, lapsed, cfg.NoShowThreshold)
+	}
+	if len(lapsed) == 0 {
+		return inviteBaseHTML
+	}
+	if len(lapsed) > cfg.LapsedMentions {
+		lapsed = lapsed[:cfg.LapsedMentions]
+	}
+	var sb strings.Builder
+	sb.Grow(len(inviteBaseHTML) + len(messages.LapsedMentionPrefix) + 64*len(lapsed))
+	sb.WriteString(inviteBaseHTML)
+	sb.WriteString("\n\n")
+	sb.WriteString(messages.LapsedMentionPrefix)
+	for i, p := range lapsed {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(mentionHTML(p))
+	}
+	return sb.String()
+}
+
+// No-show detection looks at this many recent sessions and needs this many answers per member.
+const (
+	noShowSessions   = 10
+	noShowMinAnswers = 3
+)
+
+// dropNoShows removes members whose feedback shows they rarely meet, so nudges go to engaged ones.
+func (b *Bot) dropNoShows(chatID int64, parts []db.Participant, threshold int) []db.Participant {
+	low, err := b.Store.LowShowUpUsers(chatID, noShowSessions, noShowMinAnswers, threshold)
+	if err != nil {
+		log.Printf("daily: no-show lookup failed chat=%d err=%v", chatID, err)
+		return parts
+	}
+	kept := parts[:0]
+	for _, p := range parts {
+		if !low[p.UserID] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// onCallback marks the query ID as handled up front so a concurrent redelivery is dropped.
+// Handlers that fail before their change is stored call retryCallback, and a panic does the
+// same, so that Telegram's redelivery or deliver's retry applies the tap after all.
 func (b *Bot) onCallback(cb *tgbotapi.CallbackQuery) {
+	if !b.callbacks.Add(cb.ID) {
+		// already handled; answer again only to clear the client's spinner
+		_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, ""))
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			b.retryCallback(cb)
+			panic(r)
+		}
+	}()
 	data := cb.Data
+	if strings.HasPrefix(data, "hist:") {
+		b.onHistoryCallback(cb)
+		return
+	}
+	if strings.HasPrefix(data, "setup:") {
+		b.onSetupCallback(cb)
+		return
+	}
+	if strings.HasPrefix(data, "fb:") {
+		b.onFeedbackCallback(cb)
+		return
+	}
+	if data == testInviteData {
+		_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, messages.TestInviteTapped))
+		return
+	}
+	if strings.HasPrefix(data, "extend:") {
+		b.onExtendCallback(cb)
+		return
+	}
+	if strings.HasPrefix(data, "leave:") {
+		b.onLeaveCallback(cb)
+		return
+	}
 	if strings.HasPrefix(data, "join:") {
 		var sessionID int64
 		_, _ = fmt.Sscanf(data, "join:%d", &sessionID)
 		user := cb.From
-		name := strings.TrimSpace(strings.Join([]string{user.FirstName, user.LastName}, " "))
-		if name == "" {
-			name = user.UserName
-		}
+		name := userDisplayName(user)
 		// prevent late signups
 		open, err := b.Store.SessionOpen(sessionID, time.Now())
 		if err == nil && !open {
-			_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, "Набор участников уже закрыт."))
+			_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, messages.SignupClosed))
+			b.onLateTap(sessionID, user.ID)
 			return
 		}
+		if chatID, date, err := b.Store.GetSessionInfo(sessionID); err == nil {
+			if skipped, err := b.Store.IsSkipped(chatID, user.ID, date); err == nil && skipped {
+				_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, messages.JoinSkipped))
+				return
+			}
+		}
 		in, err := b.Store.IsParticipant(sessionID, user.ID)
 		if err == nil && !in {
-			_ = b.Store.AddParticipant(sessionID, user.ID, user.UserName, name)
-			_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, messages.JoinedAck))
+			err = b.Store.AddParticipant(sessionID, user.ID, user.UserName, name)
+		}
+		if err != nil {
+			log.Printf("join: store failed session=%d user=%d err=%v", sessionID, user.ID, err)
+			b.retryCallback(cb)
+			_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, messages.InternalError))
+			return
+		}
+		if !in {
+			metrics.ParticipantsJoined.Inc()
+			_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, b.joinedAck(sessionID)))
+			_, _ = b.refreshInviteCount(sessionID)
 			return
 		}
 		_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, messages.AlreadyIn))
 	}
 }
 
-func (b *Bot) CloseAndPublish(sessionID int64) {
+// retryCallback forgets that the query was handled, for a handler that failed to store its change.
+func (b *Bot) retryCallback(cb *tgbotapi.CallbackQuery) {
+	b.callbacks.Remove(cb.ID)
+}
+
+// joinedAck is the join confirmation, ending with the chat's emoji if it has one.
+func (b *Bot) joinedAck(sessionID int64) string {
 	chatID, _, err := b.Store.GetSessionInfo(sessionID)
 	if err != nil {
-		return
-	}
-	parts, err := b.Store.GetParticipants(sessionID)
-	if err != nil {
-		return
-	}
-	// In test mode, if only one participant, add few fake participants
-	if b.TestMode && len(parts) == 1 {
-		fakes := []db.Participant{
-			{UserID: 900001, Username: "", DisplayName: "Тестовый участник 1"},
-			{UserID: 900002, Username: "", DisplayName: "Тестовый участник 2"},
-			{UserID: 900003, Username: "", DisplayName: "Тестовый участник 3"},
-			{UserID: 900004, Username: "", DisplayName: "Тестовый участник 4"},
-		}
-		for _, fp := range fakes {
-			_ = b.Store.AddParticipant(sessionID, fp.UserID, fp.Username, fp.DisplayName)
-		}
-		parts, _ = b.Store.GetParticipants(sessionID)
+		return messages.JoinedAck
 	}
-	if len(parts) == 0 {
-		msg := tgbotapi.NewMessage(chatID, messages.NoParticipants)
-		_, _ = b.API.Send(msg)
-		_ = b.Store.CloseSession(sessionID)
-		return
+	if cfg, _ := b.EffectiveSettings(chatID); cfg.InviteEmoji != "" {
+		return messages.JoinedAck + " " + cfg.InviteEmoji
 	}
-	users := make([]logic.User, 0, len(parts))
-	for _, p := range parts {
-		name := p.DisplayName
-		if name == "" && p.Username != "" {
-			name = "@" + p.Username
-		}
-		if name == "" {
-			name = fmt.Sprintf("id:%d", p.UserID)
-		}
-		users = append(users, logic.User{ID: p.UserID, Name: name})
-	}
-	groups := logic.MakeGroups(users)
-	var sb strings.Builder
-	sb.WriteString("Итоги Random Coffee на сегодня:\n")
-	for i, g := range groups {
-		sb.WriteString(fmt.Sprintf("Группа %d: ", i+1))
-		for j, u := range g.Members {
-			if j > 0 {
-				sb.WriteString(", ")
-			}
-			sb.WriteString(u.Name)
-		}
-		sb.WriteString("\n")
+	return messages.JoinedAck
+}
+
+// userDisplayName builds the stored display name of a Telegram user.
+func userDisplayName(user *tgbotapi.User) string {
+	name := logic.SanitizeName(strings.Join([]string{user.FirstName, user.LastName}, " "))
+	if name == "" {
+		name = user.UserName
 	}
-	msg := tgbotapi.NewMessage(chatID, sb.String())
-	_, _ = b.API.Send(msg)
-	_ = b.Store.CloseSession(sessionID)
+	return name
+}
+
+// chatSession returns the chat's session key for now, in its timezone: the date and the slot.
+func (b *Bot) chatSession(chatID int64) (string, int) {
+	cfg, _ := b.EffectiveSettings(chatID)
+	now := time.Now()
+	return chatDate(cfg, now), chatSlot(cfg, now)
 }
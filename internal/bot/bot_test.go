@@ -0,0 +1,627 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/db"
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeCall is one Bot API request seen by fakeTelegram.
+type fakeCall struct {
+	Method string
+	Params map[string]string
+}
+
+// fakeTelegram stands in for the Bot API as the HTTP client of a tgbotapi.BotAPI. It records
+// every request and answers it with respond, or with a plausible success when respond is nil
+// or returns nil. Uploaded files are recorded as parameters holding their content; files,
+// keyed by file ID, are served to downloads.
+type fakeTelegram struct {
+	mu      sync.Mutex
+	calls   []fakeCall
+	nextMsg int
+	respond func(method string, params map[string]string) *tgbotapi.APIResponse
+	files   map[string][]byte
+}
+
+func (f *fakeTelegram) Do(req *http.Request) (*http.Response, error) {
+	method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+	if strings.HasPrefix(req.URL.Path, "/file/") {
+		f.mu.Lock()
+		data, ok := f.files[method]
+		f.mu.Unlock()
+		if !ok {
+			return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(data)), Header: http.Header{}}, nil
+	}
+	params := map[string]string{}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/") {
+		_ = req.ParseMultipartForm(1 << 20)
+		if req.MultipartForm != nil {
+			for k, fhs := range req.MultipartForm.File {
+				if r, err := fhs[0].Open(); err == nil {
+					data, _ := io.ReadAll(r)
+					_ = r.Close()
+					params[k] = string(data)
+				}
+			}
+		}
+	} else {
+		_ = req.ParseForm()
+	}
+	for k, v := range req.Form {
+		params[k] = v[0]
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, fakeCall{Method: method, Params: params})
+	respond := f.respond
+	f.mu.Unlock()
+
+	var resp *tgbotapi.APIResponse
+	if respond != nil {
+		resp = respond(method, params)
+	}
+	if resp == nil {
+		resp = &tgbotapi.APIResponse{Ok: true, Result: f.defaultResult(method, params)}
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body)), Header: http.Header{}}, nil
+}
+
+// defaultResult is the successful result of a method: a fresh message for sends and edits,
+// a member for getChatMember, a file named by its ID for getFile and true otherwise.
+func (f *fakeTelegram) defaultResult(method string, params map[string]string) json.RawMessage {
+	var result interface{} = true
+	switch {
+	case method == "getMe":
+		result = tgbotapi.User{ID: 1, IsBot: true, UserName: "testbot"}
+	case method == "getChatMember":
+		userID, _ := strconv.ParseInt(params["user_id"], 10, 64)
+		result = tgbotapi.ChatMember{Status: "member", User: &tgbotapi.User{ID: userID}}
+	case method == "getChatAdministrators":
+		result = []tgbotapi.ChatMember{}
+	case method == "getFile":
+		result = tgbotapi.File{FileID: params["file_id"], FilePath: "documents/" + params["file_id"]}
+	case strings.HasPrefix(method, "send") || strings.HasPrefix(method, "edit"):
+		chatID, _ := strconv.ParseInt(params["chat_id"], 10, 64)
+		f.mu.Lock()
+		f.nextMsg++
+		id := f.nextMsg
+		f.mu.Unlock()
+		result = tgbotapi.Message{MessageID: id, Chat: &tgbotapi.Chat{ID: chatID}, Date: int(time.Now().Unix())}
+	}
+	raw, _ := json.Marshal(result)
+	return raw
+}
+
+// sent returns the parameters of every request to method, in order.
+func (f *fakeTelegram) sent(method string) []map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var res []map[string]string
+	for _, c := range f.calls {
+		if c.Method == method {
+			res = append(res, c.Params)
+		}
+	}
+	return res
+}
+
+// apiErrorResponse builds a failed Bot API response.
+func apiErrorResponse(code int, description string, retryAfter int) *tgbotapi.APIResponse {
+	resp := &tgbotapi.APIResponse{Ok: false, ErrorCode: code, Description: description}
+	if retryAfter > 0 {
+		resp.Parameters = &tgbotapi.ResponseParameters{RetryAfter: retryAfter}
+	}
+	return resp
+}
+
+// newTestBot returns a bot backed by an in-memory store with invites at 09:00 UTC and
+// talking to a fakeTelegram.
+func newTestBot(t testing.TB) (*Bot, *fakeTelegram) {
+	t.Helper()
+	st, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.DB.Close() })
+	if err := st.EnsureSettings("09:00"); err != nil {
+		t.Fatalf("settings: %v", err)
+	}
+	fake := &fakeTelegram{}
+	api, err := tgbotapi.NewBotAPIWithClient("test", tgbotapi.APIEndpoint, fake)
+	if err != nil {
+		t.Fatalf("bot api: %v", err)
+	}
+	return New(api, st), fake
+}
+
+// addTestChat registers an active group chat.
+func addTestChat(t testing.TB, b *Bot, chatID int64) {
+	t.Helper()
+	if err := b.Store.UpsertChat(chatID, "test"); err != nil {
+		t.Fatalf("upsert chat: %v", err)
+	}
+}
+
+func TestInviteCooldown(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		name string
+		// previous invite of the chat relative to now: days before today, other slot, age
+		prevDays  int
+		otherSlot bool
+		prevAge   time.Duration
+		noPrev    bool
+		// chatCooldown overrides the global 10m cooldown for the chat ("" keeps it)
+		chatCooldown string
+		want         inviteOutcome
+	}{
+		{name: "no previous invite", noPrev: true, want: inviteSent},
+		{name: "yesterday's invite moments ago", prevDays: 1, want: inviteSkipped},
+		{name: "yesterday's invite past cooldown", prevDays: 1, prevAge: time.Hour, want: inviteSent},
+		{name: "other slot of today moments ago", otherSlot: true, want: inviteSkipped},
+		{name: "other slot of today past cooldown", otherSlot: true, prevAge: time.Hour, want: inviteSent},
+		{name: "chat cooldown longer than global", otherSlot: true, prevAge: time.Hour, chatCooldown: "2h", want: inviteSkipped},
+		{name: "chat cooldown shorter than global", otherSlot: true, prevAge: 7 * time.Minute, chatCooldown: "5m", want: inviteSent},
+		{name: "chat cooldown disabled", otherSlot: true, chatCooldown: "0", want: inviteSent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			b.InviteCooldown = 10 * time.Minute
+			b.SignupWindow = 30 * time.Minute
+			addTestChat(t, b, chatID)
+			if err := b.Store.SetDailyTime("00:00,12:00"); err != nil {
+				t.Fatal(err)
+			}
+			if tt.chatCooldown != "" {
+				setTestSetting(t, b, chatID, "cooldown", tt.chatCooldown)
+			}
+			cfg, err := b.EffectiveSettings(chatID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Now().UTC()
+			if !tt.noPrev {
+				date, slot := chatDate(cfg, now.AddDate(0, 0, -tt.prevDays)), chatSlot(cfg, now)
+				if tt.otherSlot {
+					slot = 1 - slot
+				}
+				id, err := b.Store.CreateOrGetTodaySession(chatID, date, slot, now)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := b.Store.SetInviteMessageID(id, 1); err != nil {
+					t.Fatal(err)
+				}
+				created := now.Add(-tt.prevAge).Format("2006-01-02 15:04:05")
+				if _, err := b.Store.DB.Exec("UPDATE daily_sessions SET created_at=? WHERE id=?", created, id); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := b.sendInviteToChat(chatID); got != tt.want {
+				t.Fatalf("outcome = %d, want %d", got, tt.want)
+			}
+			wantSent := 0
+			if tt.want == inviteSent {
+				wantSent = 1
+			}
+			if n := len(fake.sent("sendMessage")); n != wantSent {
+				t.Errorf("sendMessage calls = %d, want %d", n, wantSent)
+			}
+		})
+	}
+}
+
+func TestInviteBackToBack(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	b.InviteCooldown = 10 * time.Minute
+	b.SignupWindow = 30 * time.Minute
+	addTestChat(t, b, chatID)
+
+	for i, want := range []inviteOutcome{inviteSent, inviteSkipped, inviteSkipped} {
+		if got := b.sendInviteToChat(chatID); got != want {
+			t.Fatalf("attempt %d: outcome = %d, want %d", i+1, got, want)
+		}
+	}
+	if n := len(fake.sent("sendMessage")); n != 1 {
+		t.Errorf("sendMessage calls = %d, want 1", n)
+	}
+}
+
+// BenchmarkInviteText renders the invite for many chats without lapsed mentions: "per chat"
+// escapes the text each time as before inviteBaseHTML, "shared" is what inviteText does now.
+func BenchmarkInviteText(b *testing.B) {
+	const chats = 200
+	bot, _ := newTestBot(b)
+	cfgs := make([]ChatConfig, chats)
+	for i := range cfgs {
+		chatID := int64(-100 - i)
+		addTestChat(b, bot, chatID)
+		cfg, err := bot.EffectiveSettings(chatID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		cfgs[i] = cfg
+	}
+	b.Run("per chat", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			for range cfgs {
+				_ = html.EscapeString(messages.DailyInvite)
+			}
+		}
+	})
+	b.Run("shared", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			for i, cfg := range cfgs {
+				_ = bot.inviteText(int64(-100-i), cfg)
+			}
+		}
+	})
+}
+
+func TestDeliverOffset(t *testing.T) {
+	b, _ := newTestBot(t)
+	ok := func(id int) tgbotapi.Update {
+		return tgbotapi.Update{UpdateID: id, Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: -100}, Text: "hi"}}
+	}
+	// a migration notice without a chat makes handleUpdate panic
+	failing := func(id int) tgbotapi.Update {
+		return tgbotapi.Update{UpdateID: id, Message: &tgbotapi.Message{MigrateToChatID: -200}}
+	}
+	steps := []struct {
+		name       string
+		upd        tgbotapi.Update
+		done       bool
+		wantOffset int
+	}{
+		{"handled", ok(10), true, 11},
+		{"already handled", ok(5), true, 11},
+		{"failure is retried", failing(11), false, 11},
+		{"second failure is retried", failing(11), false, 11},
+		{"third failure is skipped", failing(11), true, 12},
+		{"next update", ok(12), true, 13},
+	}
+	for _, s := range steps {
+		if got := b.deliver(s.upd); got != s.done {
+			t.Errorf("%s: done = %v, want %v", s.name, got, s.done)
+		}
+		if b.feed.offset != s.wantOffset {
+			t.Errorf("%s: offset = %d, want %d", s.name, b.feed.offset, s.wantOffset)
+		}
+		stored, err := b.Store.GetUpdateOffset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored != s.wantOffset {
+			t.Errorf("%s: stored offset = %d, want %d", s.name, stored, s.wantOffset)
+		}
+	}
+
+	// a restarted bot resumes from the stored offset
+	restarted := New(b.API, b.Store)
+	restarted.loadUpdateOffset()
+	if restarted.feed.offset != 13 {
+		t.Errorf("restarted offset = %d, want 13", restarted.feed.offset)
+	}
+}
+
+func TestMyChatMemberTransitions(t *testing.T) {
+	const chatID = -100
+	b, _ := newTestBot(t)
+	update := func(status string) tgbotapi.ChatMemberUpdated {
+		return tgbotapi.ChatMemberUpdated{
+			Chat:          tgbotapi.Chat{ID: chatID, Type: "supergroup", Title: "test"},
+			NewChatMember: tgbotapi.ChatMember{User: &tgbotapi.User{ID: 1, IsBot: true}, Status: status},
+		}
+	}
+	active := func() bool {
+		ids, err := b.Store.ChatIDs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range ids {
+			if id == chatID {
+				return true
+			}
+		}
+		return false
+	}
+
+	steps := []struct {
+		status string
+		active bool
+	}{
+		{"member", true},
+		{"kicked", false},
+		{"administrator", true},
+		{"left", false},
+	}
+	var session int64
+	for i, s := range steps {
+		b.onMyChatMember(update(s.status))
+		if got := active(); got != s.active {
+			t.Errorf("%s: active = %v, want %v", s.status, got, s.active)
+		}
+		if s.active {
+			date := time.Date(2024, 5, 6+i, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+			var err error
+			if session, err = b.Store.CreateOrGetTodaySession(chatID, date, 0, time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if closed, err := b.Store.IsSessionClosed(session); err != nil || !closed {
+			t.Errorf("%s: open session closed = %v, err = %v", s.status, closed, err)
+		}
+	}
+}
+
+func TestNextInvites(t *testing.T) {
+	// 2024-05-10 is a Friday
+	from := time.Date(2024, 5, 10, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, b *Bot, chatID int64)
+		want  []string // skip reason of each fire, Friday to Monday
+	}{
+		{"every day", func(*testing.T, *Bot, int64) {}, []string{"", "", "", ""}},
+		{"weekdays", func(t *testing.T, b *Bot, chatID int64) {
+			setTestSetting(t, b, chatID, "days", "weekdays")
+		}, []string{"", skipWeekday, skipWeekday, ""}},
+		{"holiday on monday", func(t *testing.T, b *Bot, chatID int64) {
+			setTestSetting(t, b, chatID, "days", "weekdays")
+			if err := b.Store.AddHoliday(chatID, "2024-05-13"); err != nil {
+				t.Fatal(err)
+			}
+		}, []string{"", skipWeekday, skipWeekday, skipHoliday}},
+		{"paused", func(t *testing.T, b *Bot, chatID int64) {
+			if _, err := b.Store.SetChatPaused(chatID, true); err != nil {
+				t.Fatal(err)
+			}
+		}, []string{skipPaused, skipPaused, skipPaused, skipPaused}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const chatID = -100
+			b, _ := newTestBot(t)
+			addTestChat(t, b, chatID)
+			tt.setup(t, b, chatID)
+
+			planned, err := b.NextInvites(from, len(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(planned) != len(tt.want) {
+				t.Fatalf("%d fires, want %d", len(planned), len(tt.want))
+			}
+			for i, p := range planned {
+				if want := from.Add(time.Hour).AddDate(0, 0, i); !p.At.Equal(want) {
+					t.Errorf("fire %d at %s, want %s", i, p.At, want)
+				}
+				if p.ChatID != chatID || p.Skip != tt.want[i] {
+					t.Errorf("fire %d: chat %d skip %q, want chat %d skip %q", i, p.ChatID, p.Skip, chatID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// joinUpdate is a tap on the join button of sessionID delivered as callback query id.
+func joinUpdate(id string, sessionID, userID int64) tgbotapi.Update {
+	return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:   id,
+		From: &tgbotapi.User{ID: userID, FirstName: "Ann"},
+		Data: fmt.Sprintf("join:%d", sessionID),
+	}}
+}
+
+// callbackAnswers returns the texts of every answerCallbackQuery, in order.
+func callbackAnswers(fake *fakeTelegram) []string {
+	var texts []string
+	for _, p := range fake.sent("answerCallbackQuery") {
+		texts = append(texts, p["text"])
+	}
+	return texts
+}
+
+func TestCallbackRedelivered(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	sessionID := newTestSessionWith(t, b, chatID)
+
+	b.handleUpdate(joinUpdate("cb1", sessionID, 7))
+	b.handleUpdate(joinUpdate("cb1", sessionID, 7))
+
+	parts, err := b.Store.GetParticipants(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 1 {
+		t.Fatalf("participants = %d, want 1", len(parts))
+	}
+	// the redelivery only clears the spinner instead of answering "already in"
+	want := []string{b.joinedAck(sessionID), ""}
+	if got := callbackAnswers(fake); !reflect.DeepEqual(got, want) {
+		t.Errorf("answers = %q, want %q", got, want)
+	}
+}
+
+func TestCallbackRetriedAfterStoreFailure(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	sessionID := newTestSessionWith(t, b, chatID)
+	if _, err := b.Store.DB.Exec(`CREATE TRIGGER fail_join BEFORE INSERT ON participants BEGIN SELECT RAISE(ABORT, 'disk full'); END`); err != nil {
+		t.Fatal(err)
+	}
+
+	b.handleUpdate(joinUpdate("cb1", sessionID, 7))
+	if _, err := b.Store.DB.Exec(`DROP TRIGGER fail_join`); err != nil {
+		t.Fatal(err)
+	}
+	b.handleUpdate(joinUpdate("cb1", sessionID, 7))
+
+	parts, err := b.Store.GetParticipants(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 1 {
+		t.Fatalf("participants = %d, want 1", len(parts))
+	}
+	want := []string{messages.InternalError, b.joinedAck(sessionID)}
+	if got := callbackAnswers(fake); !reflect.DeepEqual(got, want) {
+		t.Errorf("answers = %q, want %q", got, want)
+	}
+}
+
+func TestPinInvite(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		name string
+		pin  string
+		// pinFails answers pinChatMessage with missing rights
+		pinFails bool
+	}{
+		{"off", "off", false},
+		{"on", "on", false},
+		{"pin refused", "on", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			if tt.pinFails {
+				fake.respond = func(method string, _ map[string]string) *tgbotapi.APIResponse {
+					if method == "pinChatMessage" {
+						return apiErrorResponse(400, "Bad Request: not enough rights to manage pinned messages in the chat", 0)
+					}
+					return nil
+				}
+			}
+			addTestChat(t, b, chatID)
+			setTestSetting(t, b, chatID, "pin_invite", tt.pin)
+
+			// a refused pin does not fail the invite
+			if got := b.sendInviteToChat(chatID); got != inviteSent {
+				t.Fatalf("outcome = %d, want sent", got)
+			}
+			id, ok := b.openSessionToday(chatID)
+			if !ok {
+				t.Fatal("no open session")
+			}
+			ref, err := b.Store.GetInviteRef(id)
+			if err != nil || ref.MessageID == 0 {
+				t.Fatalf("invite ref = %+v, %v", ref, err)
+			}
+			invite := strconv.Itoa(ref.MessageID)
+			pins := fake.sent("pinChatMessage")
+			if tt.pin == "off" {
+				if len(pins) != 0 {
+					t.Errorf("pins = %v, want none", pins)
+				}
+			} else if len(pins) != 1 || pins[0]["message_id"] != invite || pins[0]["disable_notification"] != "true" {
+				t.Errorf("pins = %v, want a silent pin of message %s", pins, invite)
+			}
+
+			b.CloseAndPublish(id)
+			unpins := fake.sent("unpinChatMessage")
+			if tt.pin == "off" {
+				if len(unpins) != 0 {
+					t.Errorf("unpins = %v, want none", unpins)
+				}
+			} else if len(unpins) != 1 || unpins[0]["message_id"] != invite {
+				t.Errorf("unpins = %v, want message %s unpinned at close", unpins, invite)
+			}
+		})
+	}
+}
+
+func TestPinResults(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	addTestChat(t, b, chatID)
+	setTestSetting(t, b, chatID, "pin_results", "on")
+	var results []string
+	for _, date := range []string{"2024-05-06", "2024-05-07"} {
+		id, err := b.Store.CreateOrGetTodaySession(chatID, date, 0, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range []int64{1, 2} {
+			if err := b.Store.AddParticipant(id, u, "", fmt.Sprintf("User %d", u)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b.CloseAndPublish(id)
+		var msgID string
+		if err := b.Store.DB.Get(&msgID, "SELECT result_message_id FROM daily_sessions WHERE id=?", id); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, msgID)
+	}
+	pins := fake.sent("pinChatMessage")
+	if len(pins) != 2 || pins[0]["message_id"] != results[0] || pins[1]["message_id"] != results[1] {
+		t.Errorf("pins = %v, want messages %v", pins, results)
+	}
+	// the previous results make way for the new ones
+	unpins := fake.sent("unpinChatMessage")
+	if len(unpins) != 1 || unpins[0]["message_id"] != results[0] {
+		t.Errorf("unpins = %v, want message %s", unpins, results[0])
+	}
+}
+
+func TestOnAddedToGroupSeedsSettings(t *testing.T) {
+	const chatID = -100
+	b, _ := newTestBot(t)
+	tmpl, err := ParseSettingsTemplate("window=45m;days=weekdays;max_group=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.NewChatSettings = tmpl
+
+	b.onAddedToGroup(chatID, "test")
+	cfg, err := b.EffectiveSettings(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SignupWindow != 45*time.Minute || cfg.InviteDays != workingWeek || cfg.Grouping.MaxSize != 4 {
+		t.Errorf("new chat: window %s, days %s, max group %d; want the template", cfg.SignupWindow, cfg.InviteDays, cfg.Grouping.MaxSize)
+	}
+
+	// a chat re-added later keeps what its admins changed
+	setTestSetting(t, b, chatID, "window", "15m")
+	b.onAddedToGroup(chatID, "test")
+	if cfg, _ := b.EffectiveSettings(chatID); cfg.SignupWindow != 15*time.Minute {
+		t.Errorf("re-added chat: window = %s, want its own 15m", cfg.SignupWindow)
+	}
+
+	// without a template a new chat only has the global defaults
+	b.NewChatSettings = nil
+	b.onAddedToGroup(-200, "other")
+	if cs, err := b.Store.GetChatSettings(-200); err != nil || !reflect.DeepEqual(cs, db.ChatSettings{}) {
+		t.Errorf("chat without template: settings = %+v, %v; want none", cs, err)
+	}
+}
@@ -0,0 +1,614 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"coffeetrix24/internal/db"
+	"coffeetrix24/internal/logic"
+	"coffeetrix24/internal/messages"
+	"coffeetrix24/internal/scheduler"
+	"coffeetrix24/internal/version"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (b *Bot) onCommand(m *tgbotapi.Message) {
+	switch m.Command() {
+	case "help", "start":
+		b.cmdHelp(m)
+	case "version":
+		b.reply(m, fmt.Sprintf(messages.VersionReply, version.String()))
+	case "config":
+		b.cmdConfig(m)
+	case "explain":
+		b.cmdExplain(m)
+	case "set":
+		b.cmdSet(m)
+	case "settime":
+		b.cmdSetTime(m)
+	case "setwindow":
+		b.cmdSetWindow(m)
+	case "addholiday", "delholiday":
+		b.cmdHoliday(m)
+	case "pause", "resume":
+		b.cmdPause(m)
+	case "coffee":
+		b.cmdCoffee(m)
+	case "feedback":
+		b.cmdFeedback(m)
+	case "history":
+		b.cmdHistory(m)
+	case "diversity":
+		b.cmdDiversity(m)
+	case "stats":
+		b.cmdStats(m)
+	case "leaderboard":
+		b.cmdLeaderboard(m)
+	case "ical":
+		b.cmdICal(m)
+	case "add":
+		b.cmdAdd(m)
+	case "preview", "reshuffle":
+		b.cmdPreview(m)
+	case "confirm":
+		b.cmdConfirm(m)
+	case "close":
+		b.cmdClose(m)
+	case "export":
+		b.cmdExport(m)
+	case "exportconfig":
+		b.cmdExportConfig(m)
+	case "importconfig":
+		b.cmdImportConfig(m)
+	case "testinvite":
+		b.cmdTestInvite(m)
+	case "facilitator":
+		b.cmdFacilitator(m)
+	case "remove":
+		b.cmdRemove(m)
+	case "identify":
+		b.cmdIdentify(m)
+	case "leave":
+		b.cmdLeave(m)
+	case "skip":
+		b.cmdSkip(m)
+	case "unskip":
+		b.cmdUnskip(m)
+	case "setleavetext":
+		b.cmdSetLeaveText(m)
+	case "team":
+		b.cmdTeam(m)
+	case "recount":
+		b.cmdRecount(m)
+	case "setinviteimage":
+		b.cmdSetInviteImage(m)
+	case "inspect":
+		b.cmdInspect(m)
+	case "uptime":
+		b.cmdUptime(m)
+	case "diag":
+		b.cmdDiag(m)
+	case "day":
+		b.cmdDay(m)
+	case "closeinterval":
+		b.cmdCloseInterval(m)
+	case "purgechat":
+		b.cmdPurgeChat(m)
+	case "mergeusers":
+		b.cmdMergeUsers(m)
+	}
+}
+
+func (b *Bot) reply(m *tgbotapi.Message, text string) {
+	msg := tgbotapi.NewMessage(m.Chat.ID, text)
+	msg.ReplyToMessageID = m.MessageID
+	if _, err := b.API.Send(msg); err != nil {
+		log.Printf("command: reply failed chat=%d err=%v", m.Chat.ID, err)
+	}
+}
+
+func (b *Bot) cmdConfig(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	cfg, err := b.EffectiveSettings(m.Chat.ID)
+	if err != nil {
+		log.Printf("config: resolve failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	paused, err := b.Store.ChatPaused(m.Chat.ID)
+	if err != nil {
+		log.Printf("config: paused lookup failed chat=%d err=%v", m.Chat.ID, err)
+	}
+	// mark flags a line whose value comes from any of keys set for this chat
+	mark := func(keys ...string) string {
+		for _, key := range keys {
+			if cfg.Overridden[key] {
+				return messages.OverriddenMark
+			}
+		}
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(messages.ConfigHeader + "\n")
+	sb.WriteString(fmt.Sprintf(messages.ConfigDailyTime+"\n", cfg.DailyTime, zoneName(cfg), mark("timezone")))
+	sb.WriteString(fmt.Sprintf(messages.ConfigWindow+"\n", fmtDuration(cfg.SignupWindow), mark("window")))
+	sb.WriteString(fmt.Sprintf(messages.ConfigGrace+"\n", fmtDuration(cfg.GracePeriod), mark("grace")))
+	sb.WriteString(fmt.Sprintf(messages.ConfigCooldown+"\n", fmtDuration(cfg.InviteCooldown), mark("cooldown")))
+	sb.WriteString(fmt.Sprintf(messages.ConfigVerifyMembers+"\n", fmtBool(cfg.VerifyMembers), mark("verify_members")))
+	sb.WriteString(fmt.Sprintf(messages.ConfigInviteMedia+"\n", fmtBool(cfg.InviteMedia != ""), mark("invite_media")))
+	sb.WriteString(fmt.Sprintf(messages.ConfigInviteDays+"\n", cfg.InviteDays, mark("days")))
+	sb.WriteString(fmt.Sprintf(messages.ConfigGroupSize+"\n", fmtGroupSize(cfg), mark("group_size", "balance", "min_group", "max_group")))
+	sb.WriteString(fmt.Sprintf(messages.ConfigResults+"\n", cfg.ResultsVisibility, fmtLabels(cfg.Render), mark("results", "labels")))
+	sb.WriteString(fmt.Sprintf(messages.ConfigPaused+"\n", fmtBool(paused)))
+	sb.WriteString(messages.ConfigFooter)
+	b.reply(m, sb.String())
+}
+
+// cmdSetTime changes the daily invite time: /settime HH:MM, /settime HH:MM,HH:MM for several
+// invites a day, or a cron expression such as /settime 0 10 * * 2,4. The time is bot-wide, in
+// UTC or in a chat's own timezone; the scheduler picks the change up on its next minute tick.
+func (b *Bot) cmdSetTime(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	daily, ok := scheduler.NormalizeDaily(m.CommandArguments())
+	if !ok {
+		b.reply(m, messages.SetTimeUsage)
+		return
+	}
+	if err := b.Store.SetDailyTime(daily); err != nil {
+		log.Printf("settime: store failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	log.Printf("settime: chat=%d user=%d daily_time=%s", m.Chat.ID, m.From.ID, daily)
+	if err := b.Store.Audit(m.From.ID, m.Chat.ID, "settime", daily); err != nil {
+		log.Printf("settime: audit failed err=%v", err)
+	}
+	cfg, _ := b.EffectiveSettings(m.Chat.ID)
+	if scheduler.IsCron(daily) {
+		b.reply(m, fmt.Sprintf(messages.SetTimeCronDone, daily, zoneName(cfg)))
+		return
+	}
+	b.reply(m, fmt.Sprintf(messages.SetTimeDone, strings.ReplaceAll(daily, ",", ", "), zoneName(cfg)))
+}
+
+// cmdHoliday adds or removes a day without invites: /addholiday YYYY-MM-DD [all], where "all"
+// (owner only) makes it a holiday for every chat.
+func (b *Bot) cmdHoliday(m *tgbotapi.Message) {
+	args := strings.Fields(m.CommandArguments())
+	global := len(args) == 2 && args[1] == "all"
+	if global && !b.isOwner(m) || !global && !b.requireAdmin(m) {
+		return
+	}
+	if len(args) == 0 || len(args) > 2 || len(args) == 2 && !global {
+		b.reply(m, messages.HolidayUsage)
+		return
+	}
+	day, err := time.Parse("2006-01-02", args[0])
+	if err != nil {
+		b.reply(m, messages.HolidayUsage)
+		return
+	}
+	date := day.Format("2006-01-02")
+	chatID := m.Chat.ID
+	if global {
+		chatID = db.GlobalHolidayChatID
+	}
+	add := m.Command() == "addholiday"
+	if add {
+		err = b.Store.AddHoliday(chatID, date)
+	} else {
+		var removed bool
+		if removed, err = b.Store.RemoveHoliday(chatID, date); err == nil && !removed {
+			b.reply(m, fmt.Sprintf(messages.HolidayNotFound, date))
+			return
+		}
+	}
+	if err != nil {
+		log.Printf("holiday: store failed chat=%d date=%s err=%v", m.Chat.ID, date, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	log.Printf("holiday: %s chat=%d user=%d date=%s global=%t", m.Command(), m.Chat.ID, m.From.ID, date, global)
+	if err := b.Store.Audit(m.From.ID, m.Chat.ID, m.Command(), date); err != nil {
+		log.Printf("holiday: audit failed err=%v", err)
+	}
+	if add {
+		b.reply(m, fmt.Sprintf(messages.HolidayAdded, date))
+	} else {
+		b.reply(m, fmt.Sprintf(messages.HolidayRemoved, date))
+	}
+}
+
+// cmdSet changes a per-chat setting: /set <key> <value>, or /set <key> default to drop the override.
+func (b *Bot) cmdSet(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	args := strings.Fields(m.CommandArguments())
+	if len(args) != 2 {
+		b.reply(m, messages.SetUsage)
+		return
+	}
+	if _, ok := settingDefs[args[0]]; !ok {
+		b.reply(m, messages.SetUsage)
+		return
+	}
+	b.applySetting(m, args[0], args[1])
+}
+
+// cmdSetWindow is a shortcut for /set window: /setwindow 45m, or /setwindow default.
+func (b *Bot) cmdSetWindow(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	args := strings.Fields(m.CommandArguments())
+	if len(args) != 1 {
+		b.reply(m, messages.SetWindowUsage)
+		return
+	}
+	b.applySetting(m, "window", args[0])
+}
+
+// applySetting stores the chat setting key parsed from raw, or clears it for "default", and
+// replies with the outcome. key must be in settingDefs.
+func (b *Bot) applySetting(m *tgbotapi.Message, key, raw string) {
+	def := settingDefs[key]
+	var value interface{}
+	if raw != "default" {
+		v, err := def.parse(raw)
+		if errors.Is(err, errBadEmoji) {
+			b.reply(m, messages.SetBadEmoji)
+			return
+		}
+		if err != nil {
+			b.reply(m, fmt.Sprintf(messages.SetBadValue, key))
+			return
+		}
+		value = v
+	}
+	if key == "min_group" || key == "max_group" {
+		cfg, err := b.EffectiveSettings(m.Chat.ID)
+		if err != nil {
+			log.Printf("set: load settings failed chat=%d err=%v", m.Chat.ID, err)
+			b.reply(m, messages.InternalError)
+			return
+		}
+		if lo, hi, ok := groupBounds(cfg, key, value); !ok {
+			b.reply(m, fmt.Sprintf(messages.SetGroupBounds, lo, hi))
+			return
+		}
+	}
+	var patch db.ChatSettingsPatch
+	err := patch.SetColumn(def.column, value)
+	if err == nil {
+		err = b.Store.UpdateChatSettings(m.Chat.ID, patch)
+	}
+	if err != nil {
+		log.Printf("set: store failed chat=%d key=%s err=%v", m.Chat.ID, key, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	log.Printf("set: chat=%d user=%d %s=%s", m.Chat.ID, m.From.ID, key, raw)
+	b.reply(m, messages.SetDone)
+}
+
+// cmdSetInviteImage stores the photo or sticker of the replied-to message as the chat's invite media.
+// "/setinviteimage off" goes back to a plain text invite.
+func (b *Bot) cmdSetInviteImage(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	var patch db.ChatSettingsPatch
+	switch src := m.ReplyToMessage; {
+	case strings.TrimSpace(m.CommandArguments()) == "off":
+		patch.Reset = []string{"invite_media"}
+	case src != nil && len(src.Photo) > 0:
+		// the last size is the largest one
+		media := "photo:" + src.Photo[len(src.Photo)-1].FileID
+		patch.InviteMedia = &media
+	case src != nil && src.Sticker != nil:
+		media := "sticker:" + src.Sticker.FileID
+		patch.InviteMedia = &media
+	default:
+		b.reply(m, messages.SetInviteImageUsage)
+		return
+	}
+	if err := b.Store.UpdateChatSettings(m.Chat.ID, patch); err != nil {
+		log.Printf("setinviteimage: store failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	b.reply(m, messages.SetDone)
+}
+
+// maxLeaveAckLen bounds a custom /leave reply.
+const maxLeaveAckLen = 200
+
+// cmdSetLeaveText sets the chat's reply to /leave: /setleavetext <text>, or /setleavetext default.
+func (b *Bot) cmdSetLeaveText(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	text := strings.TrimSpace(m.CommandArguments())
+	if text == "" || utf8.RuneCountInString(text) > maxLeaveAckLen {
+		b.reply(m, fmt.Sprintf(messages.SetLeaveTextUsage, maxLeaveAckLen))
+		return
+	}
+	var patch db.ChatSettingsPatch
+	if text == "default" {
+		patch.Reset = []string{"leave_ack"}
+	} else {
+		patch.LeaveAck = &text
+	}
+	if err := b.Store.UpdateChatSettings(m.Chat.ID, patch); err != nil {
+		log.Printf("setleavetext: store failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	b.reply(m, messages.SetDone)
+}
+
+// cmdLeave takes the sender out of today's open session, unless the chat does not allow leaving.
+func (b *Bot) cmdLeave(m *tgbotapi.Message) {
+	cfg, err := b.EffectiveSettings(m.Chat.ID)
+	if err != nil {
+		log.Printf("leave: settings lookup failed chat=%d err=%v", m.Chat.ID, err)
+	}
+	if !cfg.AllowLeave {
+		b.reply(m, messages.LeaveNotAllowed)
+		return
+	}
+	sessionID, ok := b.openSessionToday(m.Chat.ID)
+	if !ok {
+		b.reply(m, messages.NoOpenSession)
+		return
+	}
+	removed, err := b.Store.RemoveParticipant(sessionID, m.From.ID)
+	if err != nil {
+		log.Printf("leave: store failed chat=%d session=%d user=%d err=%v", m.Chat.ID, sessionID, m.From.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if !removed {
+		b.reply(m, messages.LeaveNotIn)
+		return
+	}
+	log.Printf("leave: chat=%d session=%d user=%d", m.Chat.ID, sessionID, m.From.ID)
+	_, _ = b.refreshInviteCount(sessionID)
+	b.reply(m, cfg.LeaveAck)
+}
+
+// onLeaveCallback handles "leave:<sessionID>" from the invite's leave button, like /leave but
+// for the session the invite belongs to.
+func (b *Bot) onLeaveCallback(cb *tgbotapi.CallbackQuery) {
+	var sessionID int64
+	if _, err := fmt.Sscanf(cb.Data, "leave:%d", &sessionID); err != nil {
+		return
+	}
+	answer := func(text string) { _, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, text)) }
+	if open, err := b.Store.SessionOpen(sessionID, time.Now()); err != nil || !open {
+		answer(messages.SignupClosed)
+		return
+	}
+	chatID, _, err := b.Store.GetSessionInfo(sessionID)
+	if err != nil {
+		log.Printf("leave: session lookup failed session=%d err=%v", sessionID, err)
+		return
+	}
+	cfg, _ := b.EffectiveSettings(chatID)
+	if !cfg.AllowLeave {
+		answer(messages.LeaveNotAllowed)
+		return
+	}
+	removed, err := b.Store.RemoveParticipant(sessionID, cb.From.ID)
+	if err != nil {
+		log.Printf("leave: store failed chat=%d session=%d user=%d err=%v", chatID, sessionID, cb.From.ID, err)
+		b.retryCallback(cb)
+		answer(messages.InternalError)
+		return
+	}
+	if !removed {
+		answer(messages.LeaveNotIn)
+		return
+	}
+	log.Printf("leave: chat=%d session=%d user=%d via button", chatID, sessionID, cb.From.ID)
+	answer(cfg.LeaveAck)
+	_, _ = b.refreshInviteCount(sessionID)
+}
+
+// targetUser resolves the user an admin command is about: the author of the replied-to message,
+// or an @username the bot has seen before. Resolving by reply gives the real user ID, which a bare
+// @username alone cannot; the user directory fills that gap.
+func (b *Bot) targetUser(m *tgbotapi.Message) (db.Participant, bool) {
+	if r := m.ReplyToMessage; r != nil && r.From != nil && !r.From.IsBot {
+		return db.Participant{UserID: r.From.ID, Username: r.From.UserName, DisplayName: userDisplayName(r.From)}, true
+	}
+	arg := strings.TrimSpace(m.CommandArguments())
+	if !strings.HasPrefix(arg, "@") {
+		return db.Participant{}, false
+	}
+	p, ok, err := b.Store.LookupUsername(arg)
+	if err != nil {
+		log.Printf("directory: lookup failed username=%s err=%v", arg, err)
+	}
+	if ok && p.DisplayName == "" {
+		p.DisplayName = p.Username
+	}
+	return p, ok
+}
+
+// cmdAdd adds a user (by reply or @username) to today's open session.
+func (b *Bot) cmdAdd(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	user, ok := b.targetUser(m)
+	if !ok {
+		b.reply(m, messages.AddUsage)
+		return
+	}
+	sessionID, ok := b.openSessionToday(m.Chat.ID)
+	if !ok {
+		b.reply(m, messages.NoOpenSession)
+		return
+	}
+	name := user.DisplayName
+	if in, err := b.Store.IsParticipant(sessionID, user.UserID); err == nil && in {
+		b.reply(m, fmt.Sprintf(messages.AddAlreadyIn, name))
+		return
+	}
+	if err := b.Store.AddParticipant(sessionID, user.UserID, user.Username, name); err != nil {
+		log.Printf("add: store failed chat=%d session=%d user=%d err=%v", m.Chat.ID, sessionID, user.UserID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	log.Printf("add: chat=%d session=%d user=%d added by=%d", m.Chat.ID, sessionID, user.UserID, m.From.ID)
+	_, _ = b.refreshInviteCount(sessionID)
+	b.reply(m, fmt.Sprintf(messages.AddDone, name))
+}
+
+// cmdRemove takes a user (by reply or @username) out of today's open session.
+func (b *Bot) cmdRemove(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	user, ok := b.targetUser(m)
+	if !ok {
+		b.reply(m, messages.RemoveUsage)
+		return
+	}
+	sessionID, ok := b.openSessionToday(m.Chat.ID)
+	if !ok {
+		b.reply(m, messages.NoOpenSession)
+		return
+	}
+	removed, err := b.Store.RemoveParticipant(sessionID, user.UserID)
+	if err != nil {
+		log.Printf("remove: store failed chat=%d session=%d user=%d err=%v", m.Chat.ID, sessionID, user.UserID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if !removed {
+		b.reply(m, fmt.Sprintf(messages.RemoveNotIn, user.DisplayName))
+		return
+	}
+	log.Printf("remove: chat=%d session=%d user=%d removed by=%d", m.Chat.ID, sessionID, user.UserID, m.From.ID)
+	_, _ = b.refreshInviteCount(sessionID)
+	b.reply(m, fmt.Sprintf(messages.RemoveDone, user.DisplayName))
+}
+
+// cmdIdentify lets users register their @username explicitly; any interaction does the same implicitly.
+func (b *Bot) cmdIdentify(m *tgbotapi.Message) {
+	if m.From.UserName == "" {
+		b.reply(m, messages.IdentifyNoUsername)
+		return
+	}
+	b.reply(m, fmt.Sprintf(messages.IdentifyDone, m.From.UserName))
+}
+
+// cmdFacilitator toggles the facilitator role of the replied-to user. Facilitators may join
+// but are left out of the groups.
+func (b *Bot) cmdFacilitator(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	if m.ReplyToMessage == nil || m.ReplyToMessage.From == nil || m.ReplyToMessage.From.IsBot {
+		b.reply(m, messages.FacilitatorUsage)
+		return
+	}
+	user := m.ReplyToMessage.From
+	was, err := b.Store.IsFacilitator(m.Chat.ID, user.ID)
+	if err == nil {
+		err = b.Store.SetFacilitator(m.Chat.ID, user.ID, !was)
+	}
+	if err != nil {
+		log.Printf("facilitator: store failed chat=%d user=%d err=%v", m.Chat.ID, user.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	text := messages.FacilitatorOn
+	if was {
+		text = messages.FacilitatorOff
+	}
+	b.reply(m, fmt.Sprintf(text, userDisplayName(user)))
+}
+
+// cmdRecount re-reads today's participant count from the DB and fixes the count shown on the invite,
+// e.g. after a join was stored but the invite edit failed.
+func (b *Bot) cmdRecount(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	date, slot := b.chatSession(m.Chat.ID)
+	sessionID, _, err := b.Store.GetSessionByChatDate(m.Chat.ID, date, slot)
+	if err != nil {
+		b.reply(m, messages.NoOpenSession)
+		return
+	}
+	n, err := b.refreshInviteCount(sessionID)
+	if err != nil {
+		log.Printf("recount: failed chat=%d session=%d err=%v", m.Chat.ID, sessionID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	b.reply(m, fmt.Sprintf(messages.RecountDone, n))
+}
+
+// maxTeamLen bounds a team name so it does not swamp the results line.
+const maxTeamLen = 32
+
+// cmdTeam lets users name their team or department, shown next to them in results of chats with
+// team_tags on: /team Маркетинг, or /team off to clear it.
+func (b *Bot) cmdTeam(m *tgbotapi.Message) {
+	team := logic.SanitizeName(m.CommandArguments())
+	if team == "" {
+		b.reply(m, messages.TeamUsage)
+		return
+	}
+	if strings.EqualFold(team, "off") {
+		team = ""
+	}
+	if utf8.RuneCountInString(team) > maxTeamLen {
+		b.reply(m, fmt.Sprintf(messages.TeamTooLong, maxTeamLen))
+		return
+	}
+	if err := b.Store.SetTeam(m.From.ID, team); err != nil {
+		log.Printf("team: store failed user=%d err=%v", m.From.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if team == "" {
+		b.reply(m, messages.TeamCleared)
+		return
+	}
+	b.reply(m, fmt.Sprintf(messages.TeamDone, team))
+}
+
+// cmdCoffee sends the chat's invite right away instead of waiting for the schedule. The usual
+// checks apply: one invite per chat and time slot a day, pause, days, holidays and cooldown.
+func (b *Bot) cmdCoffee(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	date, slot := b.chatSession(m.Chat.ID)
+	if id, inviteID, err := b.Store.GetSessionByChatDate(m.Chat.ID, date, slot); err == nil && id != 0 && inviteID.Valid {
+		b.reply(m, messages.CoffeeAlreadySent)
+		return
+	}
+	log.Printf("coffee: manual invite chat=%d by=%d", m.Chat.ID, m.From.ID)
+	switch b.sendInviteToChat(m.Chat.ID) {
+	case inviteSkipped:
+		b.reply(m, messages.CoffeeSkipped)
+	case inviteFailed:
+		b.reply(m, messages.CoffeeFailed)
+	}
+}
@@ -0,0 +1,494 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/db"
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// testCommand builds a command message sent by userID in chatID.
+func testCommand(chatID, userID int64, text string) *tgbotapi.Message {
+	cmd := text
+	if i := strings.IndexByte(text, ' '); i >= 0 {
+		cmd = text[:i]
+	}
+	return &tgbotapi.Message{
+		MessageID: 10,
+		From:      &tgbotapi.User{ID: userID, FirstName: "Test"},
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "supergroup"},
+		Text:      text,
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(cmd)}},
+	}
+}
+
+// asAdmin makes getChatMember report every user as a chat administrator.
+func asAdmin(method string, params map[string]string) *tgbotapi.APIResponse {
+	if method != "getChatMember" {
+		return nil
+	}
+	return &tgbotapi.APIResponse{Ok: true, Result: []byte(`{"status":"administrator","user":{"id":` + params["user_id"] + `}}`)}
+}
+
+// setTestSetting stores a chat override the way /set key raw would.
+func setTestSetting(t *testing.T, b *Bot, chatID int64, key, raw string) {
+	t.Helper()
+	def, ok := settingDefs[key]
+	if !ok {
+		t.Fatalf("unknown setting %s", key)
+	}
+	v, err := def.parse(raw)
+	if err != nil {
+		t.Fatalf("parse %s=%s: %v", key, raw, err)
+	}
+	var patch db.ChatSettingsPatch
+	if err := patch.SetColumn(def.column, v); err != nil {
+		t.Fatalf("set %s: %v", key, err)
+	}
+	if err := b.Store.UpdateChatSettings(chatID, patch); err != nil {
+		t.Fatalf("set %s: %v", key, err)
+	}
+}
+
+// lastReply returns the text of the latest sendMessage.
+func lastReply(t *testing.T, fake *fakeTelegram) string {
+	t.Helper()
+	sent := fake.sent("sendMessage")
+	if len(sent) == 0 {
+		t.Fatal("no message sent")
+	}
+	return sent[len(sent)-1]["text"]
+}
+
+func TestCmdConfig(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	fake.respond = asAdmin
+	addTestChat(t, b, chatID)
+	setTestSetting(t, b, chatID, "window", "45m")
+	setTestSetting(t, b, chatID, "timezone", "Europe/Moscow")
+	setTestSetting(t, b, chatID, "days", "weekdays")
+	setTestSetting(t, b, chatID, "min_group", "3")
+	setTestSetting(t, b, chatID, "max_group", "5")
+	setTestSetting(t, b, chatID, "results", "both")
+	if _, err := b.Store.SetChatPaused(chatID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	b.cmdConfig(testCommand(chatID, 42, "/config"))
+	text := lastReply(t, fake)
+
+	tests := []struct {
+		line   string
+		marked bool
+	}{
+		{"• время приглашения: 09:00 Europe/Moscow", true},
+		{"• окно набора: 45m", true},
+		{"• приём опоздавших после дедлайна:", false},
+		{"• проверять, что участники ещё в чате: выкл", false},
+		{"• дни приглашений:", true},
+		{"• мин. интервал между приглашениями:", false},
+		{"• размер групп: 2–3, не меньше 3, не больше 5", true},
+		{"• итоги: both, подписи групп: numeric", true},
+		{"• приглашения на паузе: вкл", false},
+	}
+	lines := strings.Split(text, "\n")
+	if lines[0] != messages.ConfigHeader {
+		t.Errorf("header = %q, want %q", lines[0], messages.ConfigHeader)
+	}
+	for _, tt := range tests {
+		found := false
+		for _, l := range lines {
+			if !strings.HasPrefix(l, tt.line) {
+				continue
+			}
+			found = true
+			if got := strings.HasSuffix(l, messages.OverriddenMark); got != tt.marked {
+				t.Errorf("%q marked = %v, want %v", l, got, tt.marked)
+			}
+		}
+		if !found {
+			t.Errorf("no line %q in:\n%s", tt.line, text)
+		}
+	}
+}
+
+func TestCmdConfigNonAdmin(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	addTestChat(t, b, chatID)
+
+	b.cmdConfig(testCommand(chatID, 42, "/config"))
+	if got := lastReply(t, fake); got != messages.AdminOnly {
+		t.Errorf("reply = %q, want %q", got, messages.AdminOnly)
+	}
+}
+
+func TestCmdSetAndReset(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	fake.respond = asAdmin
+	addTestChat(t, b, chatID)
+	setTestSetting(t, b, chatID, "window", "45m")
+
+	b.cmdSet(testCommand(chatID, 42, "/set grace 5m"))
+	cfg, err := b.EffectiveSettings(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GracePeriod != 5*time.Minute || cfg.SignupWindow != 45*time.Minute {
+		t.Errorf("after set: grace = %s, window = %s; want 5m, 45m", cfg.GracePeriod, cfg.SignupWindow)
+	}
+
+	b.cmdSet(testCommand(chatID, 42, "/set grace default"))
+	cfg, err = b.EffectiveSettings(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Overridden["grace"] || cfg.GracePeriod != 0 {
+		t.Errorf("after reset: grace = %s, overridden = %v; want default", cfg.GracePeriod, cfg.Overridden["grace"])
+	}
+	if !cfg.Overridden["window"] || cfg.SignupWindow != 45*time.Minute {
+		t.Errorf("after reset: window = %s; want 45m kept", cfg.SignupWindow)
+	}
+	if got := lastReply(t, fake); got != messages.SetDone {
+		t.Errorf("reply = %q, want %q", got, messages.SetDone)
+	}
+}
+
+func TestCmdDay(t *testing.T) {
+	b, fake := newTestBot(t)
+	b.OwnerID = 42
+	newTestSessionWith(t, b, -100, 1, 2)
+	newTestSessionWith(t, b, -200)
+	today := time.Now().UTC().Format("2006-01-02")
+
+	b.cmdDay(testCommand(42, 42, "/day"))
+	want := fmt.Sprintf(messages.DaySummary, today, 2, 2) +
+		"\n" + fmt.Sprintf(messages.DaySession, -200, 0, 0) +
+		"\n" + fmt.Sprintf(messages.DaySession, -100, 0, 2) + ": User 1, User 2"
+	if got := lastReply(t, fake); got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+
+	b.cmdDay(testCommand(42, 42, "/day 2000-01-01"))
+	if got := lastReply(t, fake); got != fmt.Sprintf(messages.DaySummary, "2000-01-01", 0, 0) {
+		t.Errorf("reply for a day without sessions = %q", got)
+	}
+	b.cmdDay(testCommand(42, 42, "/day yesterday"))
+	if got := lastReply(t, fake); got != messages.DayUsage {
+		t.Errorf("reply for a bad date = %q, want usage", got)
+	}
+	// not the owner: no reply at all
+	n := len(fake.sent("sendMessage"))
+	b.cmdDay(testCommand(-100, 7, "/day"))
+	if len(fake.sent("sendMessage")) != n {
+		t.Errorf("non-owner got a reply")
+	}
+}
+
+func TestCmdSetTime(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		name      string
+		admin     bool
+		arg       string
+		wantReply string
+		wantDaily string
+	}{
+		{"admin", true, "10:30", fmt.Sprintf(messages.SetTimeDone, "10:30", "UTC"), "10:30"},
+		{"admin, bad time", true, "25:00", messages.SetTimeUsage, "09:00"},
+		{"non-admin", false, "10:30", messages.AdminOnly, "09:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			if tt.admin {
+				fake.respond = asAdmin
+			}
+			addTestChat(t, b, chatID)
+
+			b.cmdSetTime(testCommand(chatID, 42, "/settime "+tt.arg))
+			if got := lastReply(t, fake); got != tt.wantReply {
+				t.Errorf("reply = %q, want %q", got, tt.wantReply)
+			}
+			if n := len(fake.sent("getChatMember")); n != 1 {
+				t.Errorf("getChatMember calls = %d, want 1", n)
+			}
+			daily, err := b.Store.GetDailyTime()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if daily != tt.wantDaily {
+				t.Errorf("daily time = %q, want %q", daily, tt.wantDaily)
+			}
+		})
+	}
+}
+
+func TestCmdAdd(t *testing.T) {
+	const chatID, admin = -100, 42
+	replyTo := func(from *tgbotapi.User, text string) *tgbotapi.Message {
+		m := testCommand(chatID, admin, text)
+		m.ReplyToMessage = &tgbotapi.Message{MessageID: 5, From: from, Chat: m.Chat, Text: "hi"}
+		return m
+	}
+	masha := &tgbotapi.User{ID: 7, FirstName: "Маша", LastName: "Петрова", UserName: "masha"}
+	tests := []struct {
+		name     string
+		msg      *tgbotapi.Message
+		wantUser int64 // 0: nobody added, the usage is shown
+		wantName string
+	}{
+		{"reply", replyTo(masha, "/add"), 7, "Маша Петрова"},
+		// the replied-to author wins over an argument naming someone else
+		{"reply with argument", replyTo(masha, "/add @petya"), 7, "Маша Петрова"},
+		{"username", testCommand(chatID, admin, "/add @petya"), 8, "Петя"},
+		{"unknown username", testCommand(chatID, admin, "/add @nobody"), 0, ""},
+		{"reply to the bot", replyTo(&tgbotapi.User{ID: 99, IsBot: true}, "/add"), 0, ""},
+		{"no target", testCommand(chatID, admin, "/add"), 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			fake.respond = asAdmin
+			id := newTestSessionWith(t, b, chatID, 1)
+			if err := b.Store.RememberUser(8, "petya", "Петя"); err != nil {
+				t.Fatal(err)
+			}
+
+			b.cmdAdd(tt.msg)
+			count, err := b.Store.CountParticipants(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantUser == 0 {
+				if got := lastReply(t, fake); got != messages.AddUsage {
+					t.Errorf("reply = %q, want the usage", got)
+				}
+				if count != 1 {
+					t.Errorf("participants = %d, want 1", count)
+				}
+				return
+			}
+			if got, want := lastReply(t, fake), fmt.Sprintf(messages.AddDone, tt.wantName); got != want {
+				t.Errorf("reply = %q, want %q", got, want)
+			}
+			if in, _ := b.Store.IsParticipant(id, tt.wantUser); !in || count != 2 {
+				t.Errorf("user %d added = %v, participants = %d; want added, 2", tt.wantUser, in, count)
+			}
+			b.cmdAdd(tt.msg)
+			if got, want := lastReply(t, fake), fmt.Sprintf(messages.AddAlreadyIn, tt.wantName); got != want {
+				t.Errorf("repeated add: reply = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCmdAddAfterDeadline(t *testing.T) {
+	const chatID, admin = -100, 42
+	b, fake := newTestBot(t)
+	fake.respond = asAdmin
+	id := newTestSessionWith(t, b, chatID, 1)
+	if _, err := b.Store.DB.Exec("UPDATE daily_sessions SET signup_deadline=? WHERE id=?", time.Now().UTC().Add(-time.Minute), id); err != nil {
+		t.Fatal(err)
+	}
+	m := testCommand(chatID, admin, "/add")
+	m.ReplyToMessage = &tgbotapi.Message{MessageID: 5, From: &tgbotapi.User{ID: 7, FirstName: "Маша"}, Chat: m.Chat}
+
+	b.cmdAdd(m)
+	if got := lastReply(t, fake); got != messages.NoOpenSession {
+		t.Errorf("reply = %q, want %q", got, messages.NoOpenSession)
+	}
+	if in, _ := b.Store.IsParticipant(id, 7); in {
+		t.Error("user added after the deadline")
+	}
+}
+
+func TestCmdFacilitator(t *testing.T) {
+	const chatID, admin = -100, 42
+	b, fake := newTestBot(t)
+	fake.respond = asAdmin
+	addTestChat(t, b, chatID)
+	masha := &tgbotapi.User{ID: 7, FirstName: "Маша"}
+	command := func(from *tgbotapi.User) *tgbotapi.Message {
+		m := testCommand(chatID, admin, "/facilitator")
+		if from != nil {
+			m.ReplyToMessage = &tgbotapi.Message{MessageID: 5, From: from, Chat: m.Chat}
+		}
+		return m
+	}
+	steps := []struct {
+		name      string
+		msg       *tgbotapi.Message
+		wantReply string
+		wantFac   bool
+	}{
+		{"on", command(masha), fmt.Sprintf(messages.FacilitatorOn, "Маша"), true},
+		{"off", command(masha), fmt.Sprintf(messages.FacilitatorOff, "Маша"), false},
+		{"on again", command(masha), fmt.Sprintf(messages.FacilitatorOn, "Маша"), true},
+		{"no reply", command(nil), messages.FacilitatorUsage, true},
+		{"reply to a bot", command(&tgbotapi.User{ID: 99, IsBot: true}), messages.FacilitatorUsage, true},
+	}
+	for _, s := range steps {
+		b.cmdFacilitator(s.msg)
+		if got := lastReply(t, fake); got != s.wantReply {
+			t.Errorf("%s: reply = %q, want %q", s.name, got, s.wantReply)
+		}
+		if fac, err := b.Store.IsFacilitator(chatID, 7); err != nil || fac != s.wantFac {
+			t.Errorf("%s: facilitator = %v, %v; want %v", s.name, fac, err, s.wantFac)
+		}
+	}
+	// the role belongs to the chat
+	if fac, _ := b.Store.IsFacilitator(-200, 7); fac {
+		t.Error("facilitator in another chat")
+	}
+
+	// members cannot hand out the role
+	fake.respond = nil
+	m := command(&tgbotapi.User{ID: 8, FirstName: "Петя"})
+	m.From.ID = 43
+	b.cmdFacilitator(m)
+	if got := lastReply(t, fake); got != messages.AdminOnly {
+		t.Errorf("non-admin: reply = %q, want %q", got, messages.AdminOnly)
+	}
+	if fac, _ := b.Store.IsFacilitator(chatID, 8); fac {
+		t.Error("non-admin made a facilitator")
+	}
+}
+
+func TestCmdRecount(t *testing.T) {
+	const chatID, inviteID = -100, 77
+	tests := []struct {
+		name      string
+		admin     bool
+		session   bool
+		wantReply string
+		wantEdit  bool
+	}{
+		{"admin", true, true, fmt.Sprintf(messages.RecountDone, 3), true},
+		{"no session today", true, false, messages.NoOpenSession, false},
+		{"non-admin", false, true, messages.AdminOnly, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			if tt.admin {
+				fake.respond = asAdmin
+			}
+			addTestChat(t, b, chatID)
+			if tt.session {
+				id := newTestSessionWith(t, b, chatID, 1, 2, 3)
+				if err := b.Store.SetInviteMessageID(id, inviteID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			b.cmdRecount(testCommand(chatID, 42, "/recount"))
+			if got := lastReply(t, fake); got != tt.wantReply {
+				t.Errorf("reply = %q, want %q", got, tt.wantReply)
+			}
+			edits := fake.sent("editMessageReplyMarkup")
+			if !tt.wantEdit {
+				if len(edits) != 0 {
+					t.Errorf("edits = %v, want none", edits)
+				}
+				return
+			}
+			label := fmt.Sprintf(messages.ImInButtonCount, messages.ImInButton, 3)
+			if len(edits) != 1 || edits[0]["message_id"] != "77" || !strings.Contains(edits[0]["reply_markup"], label) {
+				t.Errorf("edits = %v, want the invite showing %q", edits, label)
+			}
+		})
+	}
+}
+
+func TestCmdLeave(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		name       string
+		allowLeave string // "" keeps the default
+		ack        string
+		session    bool
+		user       int64
+		want       string
+		wantIn     bool
+	}{
+		{"allowed by default", "", "", true, 1, messages.LeaveDone, false},
+		{"custom reply", "on", "Жаль, до завтра!", true, 1, "Жаль, до завтра!", false},
+		{"not allowed", "off", "", true, 1, messages.LeaveNotAllowed, true},
+		{"not joined", "on", "", true, 5, messages.LeaveNotIn, false},
+		{"no session", "on", "", false, 1, messages.NoOpenSession, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			addTestChat(t, b, chatID)
+			var id int64
+			if tt.session {
+				id = newTestSessionWith(t, b, chatID, 1, 2)
+			}
+			if tt.allowLeave != "" {
+				setTestSetting(t, b, chatID, "allow_leave", tt.allowLeave)
+			}
+			if tt.ack != "" {
+				if err := b.Store.UpdateChatSettings(chatID, db.ChatSettingsPatch{ChatSettings: db.ChatSettings{LeaveAck: &tt.ack}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			b.cmdLeave(testCommand(chatID, tt.user, "/leave"))
+			if got := lastReply(t, fake); got != tt.want {
+				t.Errorf("reply = %q, want %q", got, tt.want)
+			}
+			if tt.session {
+				if in, err := b.Store.IsParticipant(id, tt.user); err != nil || in != tt.wantIn {
+					t.Errorf("still in = %v, %v; want %v", in, err, tt.wantIn)
+				}
+			}
+		})
+	}
+}
+
+func TestOnLeaveCallback(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		name       string
+		allowLeave string
+		closed     bool
+		user       int64
+		want       string
+		wantIn     bool
+	}{
+		{"allowed", "on", false, 1, messages.LeaveDone, false},
+		{"not allowed", "off", false, 1, messages.LeaveNotAllowed, true},
+		{"not joined", "on", false, 5, messages.LeaveNotIn, false},
+		{"after close", "on", true, 1, messages.SignupClosed, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			id := newTestSessionWith(t, b, chatID, 1, 2)
+			setTestSetting(t, b, chatID, "allow_leave", tt.allowLeave)
+			if tt.closed {
+				if err := b.Store.CloseSession(id); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			b.onLeaveCallback(&tgbotapi.CallbackQuery{ID: "cb", From: &tgbotapi.User{ID: tt.user}, Data: fmt.Sprintf("leave:%d", id)})
+			answers := callbackAnswers(fake)
+			if len(answers) != 1 || answers[0] != tt.want {
+				t.Errorf("answers = %q, want %q", answers, tt.want)
+			}
+			if in, err := b.Store.IsParticipant(id, tt.user); err != nil || in != tt.wantIn {
+				t.Errorf("still in = %v, %v; want %v", in, err, tt.wantIn)
+			}
+		})
+	}
+}
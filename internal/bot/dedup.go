@@ -0,0 +1,38 @@
+package bot
+
+import "sync"
+
+// recentSet remembers the last cap keys added, forgetting the oldest first.
+type recentSet struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+	ring []string
+	next int
+}
+
+func newRecentSet(cap int) *recentSet {
+	return &recentSet{keys: make(map[string]struct{}, cap), ring: make([]string, cap)}
+}
+
+// Add records key and reports whether it was new.
+func (s *recentSet) Add(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[key]; ok {
+		return false
+	}
+	if old := s.ring[s.next]; old != "" {
+		delete(s.keys, old)
+	}
+	s.ring[s.next] = key
+	s.next = (s.next + 1) % len(s.ring)
+	s.keys[key] = struct{}{}
+	return true
+}
+
+// Remove forgets key so that adding it again reports it as new.
+func (s *recentSet) Remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, key)
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"coffeetrix24/internal/logic"
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// explainSchedule describes in plain words when invites go out and how a round works,
+// from the chat's effective settings.
+func explainSchedule(cfg ChatConfig) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(messages.ExplainSchedule, cfg.DailyTime, zoneName(cfg), fmtDuration(cfg.SignupWindow)))
+	if cfg.InviteDays != 0 && cfg.InviteDays != everyDay {
+		sb.WriteString(fmt.Sprintf(messages.ExplainDays, cfg.InviteDays))
+	}
+	if cfg.GracePeriod > 0 {
+		sb.WriteString(fmt.Sprintf(messages.ExplainGrace, fmtDuration(cfg.GracePeriod)))
+	}
+	if cfg.RemindBefore > 0 {
+		sb.WriteString(fmt.Sprintf(messages.ExplainRemind, fmtDuration(cfg.RemindBefore)))
+	}
+	if cfg.ExtendVotes > 0 {
+		sb.WriteString(fmt.Sprintf(messages.ExplainExtend, cfg.ExtendVotes, fmtDuration(cfg.ExtendBy)))
+	}
+	switch {
+	case cfg.Grouping.Balanced:
+		target := cfg.Grouping.TargetSize
+		if target <= 0 {
+			target = logic.BalancedTarget
+		}
+		sb.WriteString(fmt.Sprintf(messages.ExplainGroupsBalanced, target))
+		if cfg.Grouping.MaxSize > 0 {
+			sb.WriteString(fmt.Sprintf(messages.ExplainGroupsMax, cfg.Grouping.MaxSize))
+		}
+	case cfg.Grouping.TargetSize > 0:
+		sb.WriteString(fmt.Sprintf(messages.ExplainGroupsTarget, cfg.Grouping.TargetSize))
+		if cfg.Grouping.MaxSize > 0 {
+			sb.WriteString(fmt.Sprintf(messages.ExplainGroupsMax, cfg.Grouping.MaxSize))
+		}
+	case cfg.Grouping.MaxSize > 0:
+		sb.WriteString(fmt.Sprintf(messages.ExplainGroupsCapped, cfg.Grouping.MaxSize))
+	default:
+		sb.WriteString(messages.ExplainGroups)
+	}
+	if cfg.AvoidRepeatDays > 0 {
+		sb.WriteString(fmt.Sprintf(messages.ExplainAvoidRepeats, cfg.AvoidRepeatDays))
+	}
+	if cfg.MinGroupSize > 0 && cfg.SmallGroupPolicy != smallGroupsPublish {
+		policy := messages.ExplainSmallMerge
+		if cfg.SmallGroupPolicy == smallGroupsCancel {
+			policy = messages.ExplainSmallCancel
+		}
+		sb.WriteString(fmt.Sprintf(policy, cfg.MinGroupSize))
+	}
+	switch cfg.PairOnlyPolicy {
+	case pairOnlyCancel:
+		sb.WriteString(messages.ExplainPairOnlyCancel)
+	case pairOnlyCarry:
+		sb.WriteString(messages.ExplainPairOnlyCarry)
+	}
+	switch cfg.ResultsVisibility {
+	case visibilityPrivate:
+		sb.WriteString(messages.ExplainResultsPrivate)
+	case visibilityBoth:
+		sb.WriteString(messages.ExplainResultsBoth)
+	default:
+		sb.WriteString(messages.ExplainResultsPublic)
+	}
+	return sb.String()
+}
+
+func (b *Bot) cmdExplain(m *tgbotapi.Message) {
+	cfg, err := b.EffectiveSettings(m.Chat.ID)
+	if err != nil {
+		b.reply(m, messages.InternalError)
+		return
+	}
+	b.reply(m, explainSchedule(cfg))
+}
@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/logic"
+	"coffeetrix24/internal/messages"
+)
+
+func TestExplainSchedule(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	base := ChatConfig{DailyTime: "09:00", SignupWindow: time.Hour, ResultsVisibility: visibilityPublic}
+	schedule := fmt.Sprintf(messages.ExplainSchedule, "09:00", "UTC", "1h")
+	tests := []struct {
+		name   string
+		change func(*ChatConfig)
+		want   string
+	}{
+		{"defaults", func(*ChatConfig) {}, schedule + messages.ExplainGroups + messages.ExplainResultsPublic},
+		{"every day is not listed", func(c *ChatConfig) { c.InviteDays = everyDay }, schedule + messages.ExplainGroups + messages.ExplainResultsPublic},
+		{"timings", func(c *ChatConfig) {
+			c.Location, c.SignupWindow = moscow, 45*time.Minute
+			c.InviteDays = workingWeek
+			c.GracePeriod, c.RemindBefore = 5*time.Minute, 10*time.Minute
+			c.ExtendVotes, c.ExtendBy = 3, 15*time.Minute
+		}, fmt.Sprintf(messages.ExplainSchedule, "09:00", "Europe/Moscow", "45m") +
+			fmt.Sprintf(messages.ExplainDays, workingWeek) +
+			fmt.Sprintf(messages.ExplainGrace, "5m") +
+			fmt.Sprintf(messages.ExplainRemind, "10m") +
+			fmt.Sprintf(messages.ExplainExtend, 3, "15m") +
+			messages.ExplainGroups + messages.ExplainResultsPublic},
+		{"capped groups", func(c *ChatConfig) { c.Grouping.MaxSize = 4 },
+			schedule + fmt.Sprintf(messages.ExplainGroupsCapped, 4) + messages.ExplainResultsPublic},
+		{"target size", func(c *ChatConfig) { c.Grouping = logic.GroupConfig{TargetSize: 3, MaxSize: 4} },
+			schedule + fmt.Sprintf(messages.ExplainGroupsTarget, 3) + fmt.Sprintf(messages.ExplainGroupsMax, 4) + messages.ExplainResultsPublic},
+		{"balanced default target", func(c *ChatConfig) { c.Grouping.Balanced = true },
+			schedule + fmt.Sprintf(messages.ExplainGroupsBalanced, logic.BalancedTarget) + messages.ExplainResultsPublic},
+		{"group policies", func(c *ChatConfig) {
+			c.AvoidRepeatDays = 28
+			c.MinGroupSize, c.SmallGroupPolicy = 3, smallGroupsCancel
+			c.PairOnlyPolicy = pairOnlyCarry
+			c.ResultsVisibility = visibilityBoth
+		}, schedule + messages.ExplainGroups +
+			fmt.Sprintf(messages.ExplainAvoidRepeats, 28) +
+			fmt.Sprintf(messages.ExplainSmallCancel, 3) +
+			messages.ExplainPairOnlyCarry + messages.ExplainResultsBoth},
+		{"small groups published as they are", func(c *ChatConfig) {
+			c.MinGroupSize, c.SmallGroupPolicy = 3, smallGroupsPublish
+			c.PairOnlyPolicy = pairOnlyCancel
+			c.ResultsVisibility = visibilityPrivate
+		}, schedule + messages.ExplainGroups + messages.ExplainPairOnlyCancel + messages.ExplainResultsPrivate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			tt.change(&cfg)
+			if got := explainSchedule(cfg); got != tt.want {
+				t.Errorf("explanation =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCmdExplain(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	setTestSetting(t, b, chatID, "min_group", "3")
+	setTestSetting(t, b, chatID, "small_groups", smallGroupsMerge)
+
+	b.cmdExplain(testCommand(chatID, 42, "/explain"))
+	cfg, err := b.EffectiveSettings(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := lastReply(t, fake)
+	if want := explainSchedule(cfg); got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+	if want := fmt.Sprintf(messages.ExplainSmallMerge, 3); !strings.Contains(got, want) {
+		t.Errorf("reply %q does not mention the chat's small group policy", got)
+	}
+}
@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strconv"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// cmdExport sends admins the chat's sessions and participants as a CSV document, one row per
+// participant of each session.
+func (b *Bot) cmdExport(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	rows, err := b.Store.ExportSessions(m.Chat.ID)
+	if err != nil {
+		log.Printf("export: query failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if len(rows) == 0 {
+		b.reply(m, messages.ExportEmpty)
+		return
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"date", "slot", "group", "user_id", "username", "display_name"})
+	for _, r := range rows {
+		group := ""
+		if r.Group > 0 {
+			group = strconv.Itoa(r.Group)
+		}
+		_ = w.Write([]string{r.Date, strconv.Itoa(r.Slot), group, strconv.FormatInt(r.UserID, 10), r.Username, r.DisplayName})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Printf("export: csv failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	doc := tgbotapi.NewDocument(m.Chat.ID, tgbotapi.FileBytes{Name: fmt.Sprintf("coffee-sessions-%d.csv", m.Chat.ID), Bytes: buf.Bytes()})
+	doc.ReplyToMessageID = m.MessageID
+	if _, err := b.API.Send(doc); err != nil {
+		log.Printf("export: send failed chat=%d err=%v", m.Chat.ID, err)
+		return
+	}
+	log.Printf("export: chat=%d rows=%d by=%d", m.Chat.ID, len(rows), m.From.ID)
+}
@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"coffeetrix24/internal/db"
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxConfigFileSize bounds the document accepted by /importconfig.
+const maxConfigFileSize = 64 << 10
+
+// canManageConfig allows the bot owner as well as chat admins.
+func (b *Bot) canManageConfig(m *tgbotapi.Message) bool {
+	return b.isOwner(m) || b.requireAdmin(m)
+}
+
+// cmdExportConfig sends the chat's configuration as a JSON document.
+func (b *Bot) cmdExportConfig(m *tgbotapi.Message) {
+	if !b.canManageConfig(m) {
+		return
+	}
+	exp, err := b.Store.ExportChatConfig(m.Chat.ID)
+	if err != nil {
+		log.Printf("exportconfig: failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	data, err := json.MarshalIndent(exp, "", "  ")
+	if err != nil {
+		log.Printf("exportconfig: marshal failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	doc := tgbotapi.NewDocument(m.Chat.ID, tgbotapi.FileBytes{Name: fmt.Sprintf("coffee-config-%d.json", m.Chat.ID), Bytes: data})
+	doc.ReplyToMessageID = m.MessageID
+	if _, err := b.API.Send(doc); err != nil {
+		log.Printf("exportconfig: send failed chat=%d err=%v", m.Chat.ID, err)
+	}
+}
+
+// cmdImportConfig applies a configuration exported by /exportconfig, sent as a reply to its document.
+// It replaces all per-chat settings of the current chat.
+func (b *Bot) cmdImportConfig(m *tgbotapi.Message) {
+	if !b.canManageConfig(m) {
+		return
+	}
+	if m.ReplyToMessage == nil || m.ReplyToMessage.Document == nil {
+		b.reply(m, messages.ImportConfigUsage)
+		return
+	}
+	if m.ReplyToMessage.Document.FileSize > maxConfigFileSize {
+		b.reply(m, messages.ImportConfigInvalid)
+		return
+	}
+	data, err := b.downloadFile(m.ReplyToMessage.Document.FileID)
+	if err != nil {
+		log.Printf("importconfig: download failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	exp, err := db.ParseChatConfig(data)
+	if err != nil {
+		log.Printf("importconfig: invalid config chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.ImportConfigInvalid)
+		return
+	}
+	if err := b.Store.ImportChatConfig(context.Background(), m.Chat.ID, exp); err != nil {
+		log.Printf("importconfig: apply failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	log.Printf("importconfig: chat=%d settings=%d by=%d", m.Chat.ID, len(exp.Settings), m.From.ID)
+	b.reply(m, messages.ImportConfigDone)
+}
+
+// downloadFile fetches a file sent to the bot through the API's HTTP client, reading at most
+// maxConfigFileSize bytes.
+func (b *Bot) downloadFile(fileID string) ([]byte, error) {
+	url, err := b.API.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.API.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download: status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxConfigFileSize+1))
+}
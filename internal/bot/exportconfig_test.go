@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestExportImportConfig(t *testing.T) {
+	const from, to, admin = -100, -200, 42
+	b, fake := newTestBot(t)
+	fake.respond = asAdmin
+	addTestChat(t, b, from)
+	addTestChat(t, b, to)
+	setTestSetting(t, b, from, "window", "45m")
+	setTestSetting(t, b, from, "labels", "emoji:☕,🍩")
+	setTestSetting(t, b, from, "verify_members", "on")
+	setTestSetting(t, b, from, "max_group", "4")
+	if err := b.Store.SetFacilitator(from, 7, true); err != nil {
+		t.Fatal(err)
+	}
+	// the target's own settings are replaced, not merged
+	setTestSetting(t, b, to, "grace", "5m")
+	if err := b.Store.SetFacilitator(to, 8, true); err != nil {
+		t.Fatal(err)
+	}
+
+	b.cmdExportConfig(testCommand(from, admin, "/exportconfig"))
+	docs := fake.sent("sendDocument")
+	if len(docs) != 1 || docs[0]["document"] == "" {
+		t.Fatalf("documents = %v, want the exported config", docs)
+	}
+	fake.files = map[string][]byte{"cfg": []byte(docs[0]["document"])}
+
+	m := testCommand(to, admin, "/importconfig")
+	m.ReplyToMessage = &tgbotapi.Message{MessageID: 5, Chat: m.Chat, Document: &tgbotapi.Document{FileID: "cfg", FileSize: len(docs[0]["document"])}}
+	b.cmdImportConfig(m)
+	if got := lastReply(t, fake); got != messages.ImportConfigDone {
+		t.Fatalf("reply = %q, want %q", got, messages.ImportConfigDone)
+	}
+
+	want, err := b.Store.ExportChatConfig(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.Store.ExportChatConfig(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imported config = %+v, want %+v", got, want)
+	}
+	wantCfg, _ := b.EffectiveSettings(from)
+	gotCfg, _ := b.EffectiveSettings(to)
+	if !reflect.DeepEqual(gotCfg, wantCfg) {
+		t.Errorf("imported settings = %+v, want %+v", gotCfg, wantCfg)
+	}
+}
+
+func TestImportConfigRejects(t *testing.T) {
+	const chatID, admin = -100, 42
+	tests := []struct {
+		name string
+		doc  *tgbotapi.Document
+		data string
+		want string
+	}{
+		{"no document", nil, "", messages.ImportConfigUsage},
+		{"too large", &tgbotapi.Document{FileID: "cfg", FileSize: maxConfigFileSize + 1}, `{"version":1}`, messages.ImportConfigInvalid},
+		{"not json", &tgbotapi.Document{FileID: "cfg"}, "window=45m", messages.ImportConfigInvalid},
+		{"unknown setting", &tgbotapi.Document{FileID: "cfg"}, `{"version":1,"settings":{"chat_id":1}}`, messages.ImportConfigInvalid},
+		{"future version", &tgbotapi.Document{FileID: "cfg"}, `{"version":99,"settings":{}}`, messages.ImportConfigInvalid},
+		{"download failed", &tgbotapi.Document{FileID: "missing"}, "", messages.InternalError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			fake.respond = asAdmin
+			fake.files = map[string][]byte{"cfg": []byte(tt.data)}
+			addTestChat(t, b, chatID)
+			setTestSetting(t, b, chatID, "window", "45m")
+
+			m := testCommand(chatID, admin, "/importconfig")
+			m.ReplyToMessage = &tgbotapi.Message{MessageID: 5, Chat: m.Chat, Document: tt.doc}
+			b.cmdImportConfig(m)
+			if got := lastReply(t, fake); got != tt.want {
+				t.Errorf("reply = %q, want %q", got, tt.want)
+			}
+			if cs, err := b.Store.GetChatSettings(chatID); err != nil || cs.SignupWindow == nil {
+				t.Errorf("settings = %+v, %v; want them kept", cs, err)
+			}
+		})
+	}
+}
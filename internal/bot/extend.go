@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// extendable reports whether the session's invite should still offer the extend button.
+func (b *Bot) extendable(cfg ChatConfig, sessionID int64) bool {
+	if cfg.ExtendVotes <= 0 {
+		return false
+	}
+	extended, err := b.Store.SessionExtended(sessionID)
+	return err == nil && !extended
+}
+
+// onExtendCallback handles "extend:<sessionID>". Once ExtendVotes joined participants asked,
+// the deadline moves by ExtendBy; this happens at most once per session.
+func (b *Bot) onExtendCallback(cb *tgbotapi.CallbackQuery) {
+	var sessionID int64
+	if _, err := fmt.Sscanf(cb.Data, "extend:%d", &sessionID); err != nil {
+		return
+	}
+	answer := func(text string) { _, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, text)) }
+	if open, err := b.Store.SessionOpen(sessionID, time.Now()); err != nil || !open {
+		answer(messages.SignupClosed)
+		return
+	}
+	if in, err := b.Store.IsParticipant(sessionID, cb.From.ID); err != nil || !in {
+		answer(messages.ExtendJoinFirst)
+		return
+	}
+	chatID, _, err := b.Store.GetSessionInfo(sessionID)
+	if err != nil {
+		log.Printf("extend: session lookup failed session=%d err=%v", sessionID, err)
+		return
+	}
+	cfg, _ := b.EffectiveSettings(chatID)
+	if extended, err := b.Store.SessionExtended(sessionID); err != nil || extended || cfg.ExtendVotes <= 0 {
+		answer(messages.ExtendUnavailable)
+		return
+	}
+	votes, err := b.Store.RequestExtension(sessionID, cb.From.ID)
+	if err != nil {
+		log.Printf("extend: store failed session=%d user=%d err=%v", sessionID, cb.From.ID, err)
+		b.retryCallback(cb)
+		return
+	}
+	if votes < cfg.ExtendVotes {
+		answer(fmt.Sprintf(messages.ExtendVoted, votes, cfg.ExtendVotes))
+		return
+	}
+	deadline, applied, err := b.Store.ExtendSession(sessionID, cfg.ExtendBy)
+	if err != nil {
+		log.Printf("extend: apply failed session=%d err=%v", sessionID, err)
+		b.retryCallback(cb)
+		return
+	}
+	answer(messages.ExtendVotedDone)
+	if !applied {
+		return
+	}
+	log.Printf("extend: session=%d extended by %s to %s", sessionID, cfg.ExtendBy, deadline.Format(time.RFC3339))
+	_, _ = b.refreshInviteCount(sessionID)
+	if _, err := b.API.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(messages.ExtendApplied, fmtClock(cfg, deadline)))); err != nil {
+		log.Printf("extend: announce failed chat=%d err=%v", chatID, err)
+	}
+}
+
+// AutoExtend extends a session that is due to close once when signups are still busy: at least
+// AutoExtendJoins people joined within AutoExtendWindow of the deadline. It reports whether the
+// deadline moved, in which case the session must not be closed yet.
+func (b *Bot) AutoExtend(sessionID int64) bool {
+	chatID, _, err := b.Store.GetSessionInfo(sessionID)
+	if err != nil {
+		return false
+	}
+	cfg, _ := b.EffectiveSettings(chatID)
+	if cfg.AutoExtendJoins <= 0 {
+		return false
+	}
+	joins, err := b.Store.JoinsNearDeadline(sessionID, cfg.AutoExtendWindow)
+	if err != nil {
+		log.Printf("extend: recent joins lookup failed session=%d err=%v", sessionID, err)
+		return false
+	}
+	if joins < cfg.AutoExtendJoins {
+		return false
+	}
+	// ExtendSession applies at most once per session, so a busy round is not extended forever
+	deadline, applied, err := b.Store.ExtendSession(sessionID, cfg.ExtendBy)
+	if err != nil {
+		log.Printf("extend: auto apply failed session=%d err=%v", sessionID, err)
+		return false
+	}
+	if !applied || !deadline.After(time.Now()) {
+		return false
+	}
+	log.Printf("extend: session=%d auto-extended by %s to %s after %d recent joins", sessionID, cfg.ExtendBy, deadline.Format(time.RFC3339), joins)
+	_, _ = b.refreshInviteCount(sessionID)
+	if _, err := b.API.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(messages.AutoExtendApplied, fmtClock(cfg, deadline)))); err != nil {
+		log.Printf("extend: announce failed chat=%d err=%v", chatID, err)
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package bot
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// setTestDeadline moves the session's signup deadline.
+func setTestDeadline(t *testing.T, b *Bot, sessionID int64, deadline time.Time) {
+	t.Helper()
+	if _, err := b.Store.DB.Exec("UPDATE daily_sessions SET signup_deadline=? WHERE id=?", deadline.UTC(), sessionID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testDeadline reads the session's signup deadline.
+func testDeadline(t *testing.T, b *Bot, sessionID int64) time.Time {
+	t.Helper()
+	var deadline sql.NullTime
+	if err := b.Store.DB.Get(&deadline, "SELECT signup_deadline FROM daily_sessions WHERE id=?", sessionID); err != nil {
+		t.Fatal(err)
+	}
+	return deadline.Time.UTC()
+}
+
+func TestExtendThreshold(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	id := newTestSessionWith(t, b, chatID, 1, 2, 3, 4)
+	setTestSetting(t, b, chatID, "extend_votes", "3")
+	deadline := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	setTestDeadline(t, b, id, deadline)
+	extended := deadline.Add(defaultExtendBy)
+
+	steps := []struct {
+		name         string
+		user         int64
+		want         string
+		wantDeadline time.Time
+	}{
+		{"first vote", 1, fmt.Sprintf(messages.ExtendVoted, 1, 3), deadline},
+		{"second vote", 2, fmt.Sprintf(messages.ExtendVoted, 2, 3), deadline},
+		{"same voter again", 2, fmt.Sprintf(messages.ExtendVoted, 2, 3), deadline},
+		{"not joined", 5, messages.ExtendJoinFirst, deadline},
+		// the vote reaching the threshold extends
+		{"third vote", 3, messages.ExtendVotedDone, extended},
+		{"after the extension", 4, messages.ExtendUnavailable, extended},
+	}
+	for _, s := range steps {
+		b.onExtendCallback(&tgbotapi.CallbackQuery{ID: "cb", From: &tgbotapi.User{ID: s.user}, Data: fmt.Sprintf("extend:%d", id)})
+		answers := callbackAnswers(fake)
+		if got := answers[len(answers)-1]; got != s.want {
+			t.Errorf("%s: answer = %q, want %q", s.name, got, s.want)
+		}
+		if got := testDeadline(t, b, id); !got.Equal(s.wantDeadline) {
+			t.Errorf("%s: deadline = %s, want %s", s.name, got, s.wantDeadline)
+		}
+	}
+	cfg, _ := b.EffectiveSettings(chatID)
+	announced := 0
+	for _, m := range fake.sent("sendMessage") {
+		if m["text"] == fmt.Sprintf(messages.ExtendApplied, fmtClock(cfg, extended)) {
+			announced++
+		}
+	}
+	if announced != 1 {
+		t.Errorf("extension announced %d times, want once", announced)
+	}
+}
+
+func TestExtendClosedOrDisabled(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		name  string
+		votes string
+		// past: the deadline has gone by
+		past bool
+		want string
+	}{
+		{"closed", "1", true, messages.SignupClosed},
+		{"disabled", "0", false, messages.ExtendUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			id := newTestSessionWith(t, b, chatID, 1)
+			setTestSetting(t, b, chatID, "extend_votes", tt.votes)
+			deadline := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+			if tt.past {
+				deadline = time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+			}
+			setTestDeadline(t, b, id, deadline)
+
+			b.onExtendCallback(&tgbotapi.CallbackQuery{ID: "cb", From: &tgbotapi.User{ID: 1}, Data: fmt.Sprintf("extend:%d", id)})
+			if got := callbackAnswers(fake); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("answers = %q, want [%q]", got, tt.want)
+			}
+			if got := testDeadline(t, b, id); !got.Equal(deadline) {
+				t.Errorf("deadline = %s, want it unchanged at %s", got, deadline)
+			}
+		})
+	}
+}
+
+func TestAutoExtend(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		name       string
+		autoExtend string
+		recent     []int64 // joined just now
+		early      []int64 // joined an hour before
+		voted      bool    // already extended by a vote
+		deadline   time.Duration
+		want       bool
+		moved      bool // the deadline moved by extend_by
+	}{
+		{"off", "0", []int64{1, 2, 3}, nil, false, time.Minute, false, false},
+		{"busy", "3", []int64{1, 2, 3}, nil, false, time.Minute, true, true},
+		{"early joins do not count", "3", []int64{1, 2}, []int64{3, 4}, false, time.Minute, false, false},
+		{"already extended", "3", []int64{1, 2, 3}, nil, true, time.Minute, false, false},
+		// after downtime the extension still ends in the past, so the session closes as it would have
+		{"long overdue", "3", []int64{1, 2, 3}, nil, false, -time.Hour, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			id := newTestSessionWith(t, b, chatID, append(tt.recent, tt.early...)...)
+			setTestSetting(t, b, chatID, "auto_extend", tt.autoExtend)
+			setTestSetting(t, b, chatID, "extend_by", "10m")
+			for _, user := range tt.early {
+				if _, err := b.Store.DB.Exec("UPDATE participants SET joined_at=? WHERE session_id=? AND user_id=?",
+					time.Now().UTC().Add(-time.Hour).Format("2006-01-02 15:04:05"), id, user); err != nil {
+					t.Fatal(err)
+				}
+			}
+			deadline := time.Now().UTC().Add(tt.deadline).Truncate(time.Second)
+			setTestDeadline(t, b, id, deadline)
+			if tt.voted {
+				if _, _, err := b.Store.ExtendSession(id, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := b.AutoExtend(id); got != tt.want {
+				t.Fatalf("AutoExtend = %v, want %v", got, tt.want)
+			}
+			wantDeadline := deadline
+			if tt.moved {
+				wantDeadline = deadline.Add(10 * time.Minute)
+			}
+			if got := testDeadline(t, b, id); !got.Equal(wantDeadline) {
+				t.Errorf("deadline = %s, want %s", got, wantDeadline)
+			}
+			cfg, _ := b.EffectiveSettings(chatID)
+			announce := fmt.Sprintf(messages.AutoExtendApplied, fmtClock(cfg, wantDeadline))
+			announced := 0
+			for _, m := range fake.sent("sendMessage") {
+				if m["text"] == announce {
+					announced++
+				}
+			}
+			if want := map[bool]int{true: 1}[tt.want]; announced != want {
+				t.Errorf("announcements = %d, want %d", announced, want)
+			}
+
+			// a session is extended at most once
+			if b.AutoExtend(id) {
+				t.Error("extended a second time")
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// feedbackKeyboard is attached to results so participants can report whether they met.
+func feedbackKeyboard(sessionID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(messages.FeedbackMet, fmt.Sprintf("fb:%d:1", sessionID)),
+		tgbotapi.NewInlineKeyboardButtonData(messages.FeedbackMissed, fmt.Sprintf("fb:%d:0", sessionID)),
+	))
+}
+
+// onFeedbackCallback records "fb:<sessionID>:<1|0>" from a participant of that session.
+func (b *Bot) onFeedbackCallback(cb *tgbotapi.CallbackQuery) {
+	var sessionID int64
+	var value int
+	if _, err := fmt.Sscanf(cb.Data, "fb:%d:%d", &sessionID, &value); err != nil {
+		_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, ""))
+		return
+	}
+	in, err := b.Store.IsParticipant(sessionID, cb.From.ID)
+	if err != nil || !in {
+		_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, messages.FeedbackNotParticipant))
+		return
+	}
+	if err := b.Store.RecordFeedback(sessionID, cb.From.ID, value == 1); err != nil {
+		log.Printf("feedback: store failed session=%d user=%d err=%v", sessionID, cb.From.ID, err)
+		b.retryCallback(cb)
+		_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, messages.InternalError))
+		return
+	}
+	_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, messages.FeedbackThanks))
+}
+
+// cmdFeedback shows how often rounds in this chat actually took place over the last 30 days.
+func (b *Bot) cmdFeedback(m *tgbotapi.Message) {
+	st, err := b.Store.ChatFeedbackStats(m.Chat.ID, time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		log.Printf("feedback: stats failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if st.Answers == 0 {
+		b.reply(m, messages.FeedbackNoData)
+		return
+	}
+	b.reply(m, fmt.Sprintf(messages.FeedbackStats, st.Answers, st.MetShare()*100))
+}
+
+// diversityWindowDays is the period /diversity looks back over, about three months.
+const diversityWindowDays = 90
+
+// cmdDiversity shows how many different people participants met over the last three months,
+// i.e. how well rounds avoid pairing the same people again.
+func (b *Bot) cmdDiversity(m *tgbotapi.Message) {
+	st, err := b.Store.PartnerDiversity(m.Chat.ID, time.Now().AddDate(0, 0, -diversityWindowDays))
+	if err != nil {
+		log.Printf("diversity: stats failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if len(st.Partners) == 0 {
+		b.reply(m, messages.DiversityNoData)
+		return
+	}
+	b.reply(m, fmt.Sprintf(messages.DiversityStats, st.Average(), len(st.Partners), st.Max()))
+}
+
+// statsWindowDays is the period /stats averages the group count over.
+const statsWindowDays = 30
+
+// cmdStats sums up participation in the chat: sessions held, distinct participants and the average
+// number of groups per session over the last 30 days.
+func (b *Bot) cmdStats(m *tgbotapi.Message) {
+	st, err := b.Store.ChatStats(m.Chat.ID, time.Now().AddDate(0, 0, -statsWindowDays))
+	if err != nil {
+		log.Printf("stats: query failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if st.Sessions == 0 && st.Participants == 0 {
+		b.reply(m, messages.StatsNoData)
+		return
+	}
+	text := fmt.Sprintf(messages.Stats, st.Sessions, st.Participants)
+	if st.RecentSessions > 0 {
+		text += "\n" + fmt.Sprintf(messages.StatsGroups, st.AverageGroups(), st.RecentSessions)
+	} else {
+		text += "\n" + messages.StatsNoRecent
+	}
+	b.reply(m, text)
+}
+
+// Length of the /leaderboard list: the default and the most an argument may ask for.
+const (
+	defaultLeaderboardSize = 10
+	maxLeaderboardSize     = 30
+)
+
+// cmdLeaderboard lists the chat's most active participants by sessions joined: /leaderboard [N].
+func (b *Bot) cmdLeaderboard(m *tgbotapi.Message) {
+	limit := defaultLeaderboardSize
+	if arg := strings.TrimSpace(m.CommandArguments()); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			b.reply(m, messages.LeaderboardUsage)
+			return
+		}
+		limit = n
+	}
+	if limit > maxLeaderboardSize {
+		limit = maxLeaderboardSize
+	}
+	entries, err := b.Store.Leaderboard(m.Chat.ID, limit)
+	if err != nil {
+		log.Printf("leaderboard: query failed chat=%d err=%v", m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if len(entries) == 0 {
+		b.reply(m, messages.LeaderboardEmpty)
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(messages.LeaderboardHeader)
+	for i, e := range entries {
+		sb.WriteString("\n" + fmt.Sprintf(messages.LeaderboardLine, i+1, e.Name, e.Sessions))
+	}
+	b.reply(m, sb.String())
+}
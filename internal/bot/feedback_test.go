@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/logic"
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestOnFeedbackCallback(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	id := newTestSessionWith(t, b, chatID, 1, 2)
+	answer := func(user int64, data string) string {
+		t.Helper()
+		b.onFeedbackCallback(&tgbotapi.CallbackQuery{ID: "cb", From: &tgbotapi.User{ID: user}, Data: data})
+		answers := callbackAnswers(fake)
+		return answers[len(answers)-1]
+	}
+	met := func(user int64) (bool, bool) {
+		t.Helper()
+		var v []bool
+		if err := b.Store.DB.Select(&v, "SELECT met FROM feedback WHERE session_id=? AND user_id=?", id, user); err != nil {
+			t.Fatal(err)
+		}
+		if len(v) == 0 {
+			return false, false
+		}
+		return v[0], true
+	}
+
+	steps := []struct {
+		name    string
+		user    int64
+		data    string
+		want    string
+		wantMet bool
+		stored  bool
+	}{
+		{"met", 1, fmt.Sprintf("fb:%d:1", id), messages.FeedbackThanks, true, true},
+		{"changed answer", 1, fmt.Sprintf("fb:%d:0", id), messages.FeedbackThanks, false, true},
+		{"missed", 2, fmt.Sprintf("fb:%d:0", id), messages.FeedbackThanks, false, true},
+		{"not a participant", 3, fmt.Sprintf("fb:%d:1", id), messages.FeedbackNotParticipant, false, false},
+		{"another session", 3, fmt.Sprintf("fb:%d:1", id+1), messages.FeedbackNotParticipant, false, false},
+		{"malformed", 3, "fb:x", "", false, false},
+	}
+	for _, s := range steps {
+		if got := answer(s.user, s.data); got != s.want {
+			t.Errorf("%s: answer = %q, want %q", s.name, got, s.want)
+		}
+		if got, stored := met(s.user); got != s.wantMet || stored != s.stored {
+			t.Errorf("%s: met = %v (stored %v), want %v (stored %v)", s.name, got, stored, s.wantMet, s.stored)
+		}
+	}
+}
+
+func TestCmdFeedback(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	id := newTestSessionWith(t, b, chatID, 1, 2, 3, 4)
+
+	b.cmdFeedback(testCommand(chatID, 1, "/feedback"))
+	if got := lastReply(t, fake); got != messages.FeedbackNoData {
+		t.Errorf("without answers: reply = %q, want %q", got, messages.FeedbackNoData)
+	}
+
+	for user, m := range map[int64]bool{1: true, 2: true, 3: true, 4: false} {
+		if err := b.Store.RecordFeedback(id, user, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// answers from another chat do not count
+	other := newTestSessionWith(t, b, -200, 5)
+	if err := b.Store.RecordFeedback(other, 5, false); err != nil {
+		t.Fatal(err)
+	}
+	b.cmdFeedback(testCommand(chatID, 1, "/feedback"))
+	if got, want := lastReply(t, fake), fmt.Sprintf(messages.FeedbackStats, 4, 75.0); got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+}
+
+func TestCmdDiversity(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	b.cmdDiversity(testCommand(chatID, 42, "/diversity"))
+	if got := lastReply(t, fake); got != messages.DiversityNoData {
+		t.Errorf("without groups: %q, want %q", got, messages.DiversityNoData)
+	}
+
+	// 1 met 2 and 3, 2 and 3 met only 1
+	id := newTestSessionWith(t, b, chatID, 1, 2, 3)
+	if err := b.Store.SavePublishedGroups(id, []logic.Group{{Members: []logic.User{{ID: 1}, {ID: 2}}}}); err != nil {
+		t.Fatal(err)
+	}
+	// move it to yesterday, so today gets a session of its own
+	if _, err := b.Store.DB.Exec("UPDATE daily_sessions SET session_date=? WHERE id=?", time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02"), id); err != nil {
+		t.Fatal(err)
+	}
+	id = newTestSessionWith(t, b, chatID, 1, 3)
+	if err := b.Store.SavePublishedGroups(id, []logic.Group{{Members: []logic.User{{ID: 1}, {ID: 3}}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	b.cmdDiversity(testCommand(chatID, 42, "/diversity"))
+	if got, want := lastReply(t, fake), fmt.Sprintf(messages.DiversityStats, 4.0/3, 3, 2); got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+}
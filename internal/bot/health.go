@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// healthTelegramTTL is how long a Telegram reachability result is reused by /healthz.
+	healthTelegramTTL = time.Minute
+	// healthTimeout bounds each dependency check of /healthz.
+	healthTimeout = 5 * time.Second
+)
+
+// telegramHealth caches whether getMe succeeded recently, so probes do not hit Telegram every time.
+type telegramHealth struct {
+	mu        sync.Mutex
+	ok        bool
+	checkedAt time.Time
+}
+
+// ServeHealth runs the health check server with /healthz on addr until ctx is done.
+func (b *Bot) ServeHealth(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", b.handleHealth)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	log.Printf("health: listening on %s", addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("health: server failed err=%v", err)
+	}
+}
+
+// handleHealth answers 200 while the bot runs, the DB responds and Telegram was reachable at the
+// last check; 503 with the failing part otherwise.
+func (b *Bot) handleHealth(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-b.stopping():
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+	defer cancel()
+	if err := b.Store.DB.PingContext(ctx); err != nil {
+		log.Printf("health: db ping failed err=%v", err)
+		http.Error(w, "db unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if !b.telegramReachable() {
+		http.Error(w, "telegram unreachable", http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// telegramReachable reports the cached getMe result, refreshing it once it is older than
+// healthTelegramTTL.
+func (b *Bot) telegramReachable() bool {
+	h := &b.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.checkedAt.IsZero() && time.Since(h.checkedAt) < healthTelegramTTL {
+		return h.ok
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := b.API.GetMe()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		h.ok = err == nil
+		if err != nil {
+			log.Printf("health: getMe failed err=%v", err)
+		}
+	case <-time.After(healthTimeout):
+		h.ok = false
+		log.Printf("health: getMe timed out after %s", healthTimeout)
+	}
+	h.checkedAt = time.Now()
+	return h.ok
+}
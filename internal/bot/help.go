@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// cmdHelp lists the commands the sender may use in the chat, with the chat's schedule. In a
+// private chat it explains how to get started instead; /start in a group is left alone.
+func (b *Bot) cmdHelp(m *tgbotapi.Message) {
+	if m.Chat.IsPrivate() {
+		b.reply(m, messages.HelpPrivate)
+		return
+	}
+	if m.Command() == "start" {
+		return
+	}
+	cfg, err := b.EffectiveSettings(m.Chat.ID)
+	if err != nil {
+		log.Printf("help: settings lookup failed chat=%d err=%v", m.Chat.ID, err)
+	}
+	text := fmt.Sprintf(messages.HelpSchedule, cfg.DailyTime, zoneName(cfg), fmtDuration(cfg.SignupWindow)) + "\n\n" + messages.HelpMember
+	if m.From != nil {
+		if admin, err := b.isAdmin(m.Chat.ID, m.From.ID); err != nil {
+			log.Printf("help: admin check failed chat=%d user=%d err=%v", m.Chat.ID, m.From.ID, err)
+		} else if admin {
+			text += "\n\n" + messages.HelpAdmin
+		}
+	}
+	if b.isOwner(m) {
+		text += "\n\n" + messages.HelpOwner
+	}
+	b.reply(m, text)
+}
@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"coffeetrix24/internal/db"
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	defaultHistoryLimit = 5
+	maxHistoryLimit     = 20
+	// historyMaxLen keeps a history page well under Telegram's 4096-character message limit.
+	historyMaxLen = 3500
+)
+
+// cmdHistory shows the chat's recent rounds: /history [N].
+func (b *Bot) cmdHistory(m *tgbotapi.Message) {
+	limit := defaultHistoryLimit
+	if arg := strings.TrimSpace(m.CommandArguments()); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			b.reply(m, messages.HistoryUsage)
+			return
+		}
+		limit = n
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	text, kb := b.historyPage(m.Chat.ID, 0, limit)
+	msg := tgbotapi.NewMessage(m.Chat.ID, text)
+	if kb != nil {
+		msg.ReplyMarkup = *kb
+	}
+	if _, err := b.API.Send(msg); err != nil {
+		log.Printf("history: send failed chat=%d err=%v", m.Chat.ID, err)
+	}
+}
+
+// onHistoryCallback turns the page for callback data "hist:<offset>:<limit>", editing the message in place.
+// The page position lives only in the callback data.
+func (b *Bot) onHistoryCallback(cb *tgbotapi.CallbackQuery) {
+	_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, ""))
+	offset, limit, ok := parseHistoryData(cb.Data)
+	if !ok || cb.Message == nil {
+		return
+	}
+	text, kb := b.historyPage(cb.Message.Chat.ID, offset, limit)
+	markup := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	if kb != nil {
+		markup = *kb
+	}
+	edit := tgbotapi.NewEditMessageTextAndMarkup(cb.Message.Chat.ID, cb.Message.MessageID, text, markup)
+	if _, err := b.API.Request(edit); err != nil && !isNotModified(err) {
+		log.Printf("history: edit failed chat=%d err=%v", cb.Message.Chat.ID, err)
+	}
+}
+
+func parseHistoryData(data string) (offset, limit int, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(data, "hist:"), ":")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	offset, err1 := strconv.Atoi(parts[0])
+	limit, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || offset < 0 || limit < 1 || limit > maxHistoryLimit {
+		return 0, 0, false
+	}
+	return offset, limit, true
+}
+
+// historyPage renders one page of rounds and the navigation keyboard (nil when a single page fits).
+func (b *Bot) historyPage(chatID int64, offset, limit int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	sessions, err := b.Store.RecentSessions(chatID, limit, offset)
+	if err != nil {
+		log.Printf("history: query failed chat=%d err=%v", chatID, err)
+		return messages.InternalError, nil
+	}
+	total, _ := b.Store.CountChatSessions(chatID)
+	if len(sessions) == 0 {
+		return messages.HistoryEmpty, nil
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(messages.HistoryHeader+"\n", offset+1, offset+len(sessions), total))
+	for _, ss := range sessions {
+		var entry strings.Builder
+		entry.WriteString(fmt.Sprintf("\n"+messages.HistoryRound+"\n", ss.Date, ss.Participants))
+		groups, err := b.Store.GetSessionGroups(ss.ID)
+		if err != nil {
+			log.Printf("history: groups lookup failed session=%d err=%v", ss.ID, err)
+		}
+		if len(groups) > 0 {
+			for i, g := range groups {
+				entry.WriteString(fmt.Sprintf(messages.GroupLabel, strconv.Itoa(i+1)) + participantNames(g) + "\n")
+			}
+		} else if parts, err := b.Store.GetParticipants(ss.ID); err == nil && len(parts) > 0 {
+			// no groups were published, e.g. the round was cancelled: list who signed up
+			entry.WriteString(participantNames(parts) + "\n")
+		}
+		if sb.Len()+entry.Len() > historyMaxLen {
+			sb.WriteString("\n" + messages.HistoryTruncated)
+			break
+		}
+		sb.WriteString(entry.String())
+	}
+	var row []tgbotapi.InlineKeyboardButton
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(messages.HistoryNewer, fmt.Sprintf("hist:%d:%d", prev, limit)))
+	}
+	if offset+limit < total {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(messages.HistoryOlder, fmt.Sprintf("hist:%d:%d", offset+limit, limit)))
+	}
+	if len(row) == 0 {
+		return sb.String(), nil
+	}
+	kb := tgbotapi.NewInlineKeyboardMarkup(row)
+	return sb.String(), &kb
+}
+
+// participantNames joins the participants' names with commas.
+func participantNames(parts []db.Participant) string {
+	names := make([]string, 0, len(parts))
+	for _, p := range parts {
+		names = append(names, participantName(p))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestParseHistoryData(t *testing.T) {
+	tests := []struct {
+		data          string
+		offset, limit int
+		ok            bool
+	}{
+		{"hist:0:5", 0, 5, true},
+		{"hist:15:20", 15, 20, true},
+		{"hist:5", 0, 0, false},
+		{"hist:1:2:3", 0, 0, false},
+		{"hist::5", 0, 0, false},
+		{"hist:a:5", 0, 0, false},
+		{"hist:0:x", 0, 0, false},
+		{"hist:-1:5", 0, 0, false},
+		{"hist:0:0", 0, 0, false},
+		{"hist:0:21", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		offset, limit, ok := parseHistoryData(tt.data)
+		if offset != tt.offset || limit != tt.limit || ok != tt.ok {
+			t.Errorf("parseHistoryData(%q) = %d, %d, %v; want %d, %d, %v", tt.data, offset, limit, ok, tt.offset, tt.limit, tt.ok)
+		}
+	}
+}
+
+// addHistory creates n sessions of chatID on consecutive days, each joined by one user.
+func addHistory(t *testing.T, b *Bot, chatID int64, n int) {
+	t.Helper()
+	addTestChat(t, b, chatID)
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		id, err := b.Store.CreateOrGetTodaySession(chatID, day.AddDate(0, 0, i).Format("2006-01-02"), 0, day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Store.AddParticipant(id, int64(i+1), "", fmt.Sprintf("User %d", i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// buttonData returns the callback data of the keyboard's buttons ("" for no keyboard).
+func buttonData(kb *tgbotapi.InlineKeyboardMarkup) string {
+	if kb == nil {
+		return ""
+	}
+	var data []string
+	for _, row := range kb.InlineKeyboard {
+		for _, btn := range row {
+			data = append(data, *btn.CallbackData)
+		}
+	}
+	return strings.Join(data, " ")
+}
+
+func TestHistoryPage(t *testing.T) {
+	const chatID = -100
+	b, _ := newTestBot(t)
+	addHistory(t, b, chatID, 7)
+	tests := []struct {
+		offset, limit int
+		first, last   int
+		dates         []string
+		buttons       string
+	}{
+		{0, 3, 1, 3, []string{"2024-05-07", "2024-05-06", "2024-05-05"}, "hist:3:3"},
+		{3, 3, 4, 6, []string{"2024-05-04", "2024-05-03", "2024-05-02"}, "hist:0:3 hist:6:3"},
+		{6, 3, 7, 7, []string{"2024-05-01"}, "hist:3:3"},
+		// a page not aligned to the limit still steps back to the start
+		{1, 3, 2, 4, []string{"2024-05-06", "2024-05-05", "2024-05-04"}, "hist:0:3 hist:4:3"},
+		{0, 7, 1, 7, nil, ""},
+	}
+	for _, tt := range tests {
+		text, kb := b.historyPage(chatID, tt.offset, tt.limit)
+		if header := fmt.Sprintf(messages.HistoryHeader, tt.first, tt.last, 7); !strings.HasPrefix(text, header) {
+			t.Errorf("page %d/%d: text %q does not start with %q", tt.offset, tt.limit, text, header)
+		}
+		for _, date := range tt.dates {
+			if !strings.Contains(text, date) {
+				t.Errorf("page %d/%d: %s missing from %q", tt.offset, tt.limit, date, text)
+			}
+		}
+		if got := buttonData(kb); got != tt.buttons {
+			t.Errorf("page %d/%d: buttons = %q, want %q", tt.offset, tt.limit, got, tt.buttons)
+		}
+	}
+
+	if text, kb := b.historyPage(chatID, 10, 3); text != messages.HistoryEmpty || kb != nil {
+		t.Errorf("past the end: %q, %v; want the empty history", text, kb)
+	}
+}
+
+func TestOnHistoryCallback(t *testing.T) {
+	const chatID = -100
+	for _, data := range []string{"hist:3:3", "hist:3", "hist:x:3", "hist:0:100"} {
+		t.Run(data, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			addHistory(t, b, chatID, 7)
+			b.onHistoryCallback(&tgbotapi.CallbackQuery{
+				ID:      "cb",
+				Data:    data,
+				Message: &tgbotapi.Message{MessageID: 5, Chat: &tgbotapi.Chat{ID: chatID}},
+			})
+			if n := len(fake.sent("answerCallbackQuery")); n != 1 {
+				t.Errorf("answers = %d, want 1", n)
+			}
+			edits := fake.sent("editMessageText")
+			if _, _, ok := parseHistoryData(data); !ok {
+				// malformed data leaves the message as it is
+				if len(edits) != 0 {
+					t.Errorf("edits = %v, want none", edits)
+				}
+				return
+			}
+			want, _ := b.historyPage(chatID, 3, 3)
+			if len(edits) != 1 || edits[0]["text"] != want || edits[0]["message_id"] != "5" {
+				t.Errorf("edits = %v, want the page at offset 3", edits)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"coffeetrix24/internal/ical"
+	"coffeetrix24/internal/messages"
+	"coffeetrix24/internal/scheduler"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// icalDays is how far ahead the calendar feed lists invites.
+const icalDays = 7
+
+// icalMaxEvents caps the feed for schedules that fire very often, e.g. a cron expression every hour.
+const icalMaxEvents = 100
+
+// icalToken authorizes the calendar feed of a chat. Unlike confirmation tokens it must survive
+// restarts, so it is keyed by the bot token rather than the per-process secret.
+func (b *Bot) icalToken(chatID int64) string {
+	mac := hmac.New(sha256.New, []byte(b.API.Token))
+	fmt.Fprintf(mac, "ical:%d", chatID)
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// icalURL is the subscription URL of the chat's feed.
+func (b *Bot) icalURL(chatID int64) string {
+	return fmt.Sprintf("%s/ical/%d.ics?token=%s", strings.TrimRight(b.PublicURL, "/"), chatID, b.icalToken(chatID))
+}
+
+// cmdICal replies with the chat's calendar subscription URL.
+func (b *Bot) cmdICal(m *tgbotapi.Message) {
+	if b.PublicURL == "" {
+		b.reply(m, messages.ICalDisabled)
+		return
+	}
+	b.reply(m, fmt.Sprintf(messages.ICalURL, b.icalURL(m.Chat.ID)))
+}
+
+// ChatCalendar renders the chat's invites over the next icalDays days as an iCalendar feed.
+// Each event spans the signup window.
+func (b *Bot) ChatCalendar(chatID int64, now time.Time) (string, error) {
+	cfg, err := b.EffectiveSettings(chatID)
+	if err != nil {
+		return "", err
+	}
+	var events []ical.Event
+	for _, t := range scheduler.FiresUntil(cfg.DailyTime, now, now.AddDate(0, 0, icalDays), cfg.Location, icalMaxEvents) {
+		if b.skipReason(chatID, cfg, t) != "" {
+			continue
+		}
+		uid := fmt.Sprintf("%d-%s", chatID, t.Format("20060102"))
+		if slot := chatSlot(cfg, t); slot > 0 {
+			uid += fmt.Sprintf("-%d", slot)
+		}
+		events = append(events, ical.Event{
+			UID:     uid + "@coffeetrix24",
+			Start:   t,
+			End:     t.Add(cfg.SignupWindow),
+			Summary: messages.ICalEvent,
+		})
+	}
+	return ical.Feed(messages.ICalName, events, now), nil
+}
+
+// ServeHTTP runs the optional HTTP server with the calendar feeds until ctx is done.
+func (b *Bot) ServeHTTP(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ical/", b.handleICal)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	log.Printf("http: listening on %s", addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("http: server failed err=%v", err)
+	}
+}
+
+// handleICal serves GET /ical/<chatID>.ics?token=<token>.
+func (b *Bot) handleICal(w http.ResponseWriter, r *http.Request) {
+	raw := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/ical/"), ".ics")
+	chatID, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !hmac.Equal([]byte(r.URL.Query().Get("token")), []byte(b.icalToken(chatID))) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	feed, err := b.ChatCalendar(chatID, time.Now())
+	if err != nil {
+		log.Printf("http: ical failed chat=%d err=%v", chatID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	_, _ = w.Write([]byte(feed))
+}
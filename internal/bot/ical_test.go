@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCalendarBot sets up a chat in Moscow with invites at 09:00 and 15:00 on weekdays and
+// Monday 2024-05-13 off.
+func newTestCalendarBot(t *testing.T) *Bot {
+	t.Helper()
+	const chatID = -100
+	b, _ := newTestBot(t)
+	if _, err := time.LoadLocation("Europe/Moscow"); err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	addTestChat(t, b, chatID)
+	if err := b.Store.SetDailyTime("09:00,15:00"); err != nil {
+		t.Fatal(err)
+	}
+	setTestSetting(t, b, chatID, "timezone", "Europe/Moscow")
+	setTestSetting(t, b, chatID, "days", "weekdays")
+	setTestSetting(t, b, chatID, "window", "30m")
+	if err := b.Store.AddHoliday(chatID, "2024-05-13"); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestChatCalendar(t *testing.T) {
+	b := newTestCalendarBot(t)
+	// Friday 10:00 in Moscow: the morning invite is past, the weekend and the holiday are skipped
+	now := time.Date(2024, 5, 10, 7, 0, 0, 0, time.UTC)
+	got, err := b.ChatCalendar(-100, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "calendar.ics"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("calendar =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHandleICal(t *testing.T) {
+	b := newTestCalendarBot(t)
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"feed", "/ical/-100.ics?token=" + b.icalToken(-100), http.StatusOK},
+		{"wrong token", "/ical/-100.ics?token=" + b.icalToken(-200), http.StatusForbidden},
+		{"no token", "/ical/-100.ics", http.StatusForbidden},
+		{"bad chat", "/ical/chat.ics?token=x", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		b.handleICal(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+			continue
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "text/calendar; charset=utf-8" {
+			t.Errorf("%s: content type = %q", tt.name, ct)
+		}
+		if body := rec.Body.String(); !strings.HasPrefix(body, "BEGIN:VCALENDAR\r\n") {
+			t.Errorf("%s: body = %q, want a calendar", tt.name, body)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+
+	"coffeetrix24/internal/db"
+)
+
+// SpreadInvites is SendInvites for scheduled runs: with InviteJitter set, each chat's invite goes
+// out after its own random delay up to InviteJitter, in the background until Shutdown, so that many chats do
+// not hit the Telegram rate limits at once. The delay stays below the chat's signup window, so
+// the invite still arrives before signups would have closed had it gone out on time.
+func (b *Bot) SpreadInvites(chatIDs []int64) {
+	if b.InviteJitter <= 0 || len(chatIDs) == 0 {
+		b.SendInvites(chatIDs)
+		return
+	}
+	type planned struct {
+		chatID int64
+		delay  time.Duration
+	}
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	plan := make([]planned, 0, len(chatIDs))
+	for _, chatID := range chatIDs {
+		max := b.InviteJitter
+		if cfg, err := b.EffectiveSettings(chatID); err == nil && cfg.SignupWindow < max {
+			max = cfg.SignupWindow
+		}
+		var delay time.Duration
+		if max > 0 {
+			delay = time.Duration(r.Int63n(int64(max)))
+		}
+		plan = append(plan, planned{chatID, delay})
+	}
+	sort.Slice(plan, func(i, j int) bool { return plan[i].delay < plan[j].delay })
+	log.Printf("daily: spreading invites chats=%d jitter=%s", len(plan), b.InviteJitter)
+	done := b.track()
+	go func() {
+		defer done()
+		start := time.Now()
+		run := db.DailyRun{At: start.UTC(), Chats: len(plan)}
+		for i, p := range plan {
+			select {
+			case <-time.After(time.Until(start.Add(p.delay))):
+			case <-b.stopping():
+				// the rest is left to the catch-up invite after restart
+				log.Printf("daily: shutting down, %d spread invites not sent", len(plan)-i)
+				b.finishRun(run, start)
+				return
+			}
+			countOutcome(&run, b.sendInviteToChat(p.chatID))
+		}
+		b.finishRun(run, start)
+	}()
+}
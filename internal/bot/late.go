@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"log"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// lateTapsForNote is how many people must tap join too late before an empty round gets the note.
+const lateTapsForNote = 2
+
+// onLateTap counts a join tap after signups closed. With late_note on, once enough people tapped
+// late on a round that closed with nobody in it, the chat is told once why nothing happened.
+func (b *Bot) onLateTap(sessionID, userID int64) {
+	n, added, err := b.Store.RecordLateTap(sessionID, userID)
+	if err != nil {
+		log.Printf("late: record failed session=%d user=%d err=%v", sessionID, userID, err)
+		return
+	}
+	// the note goes out exactly when the threshold is crossed; repeated taps of one user do not count
+	if !added || n != lateTapsForNote {
+		return
+	}
+	chatID, _, err := b.Store.GetSessionInfo(sessionID)
+	if err != nil {
+		return
+	}
+	if cfg, _ := b.EffectiveSettings(chatID); !cfg.LateNote {
+		return
+	}
+	closed, err := b.Store.IsSessionClosed(sessionID)
+	if err != nil || !closed {
+		// still closing: the results message includes the note if the round is empty
+		return
+	}
+	if count, err := b.Store.CountParticipants(sessionID); err != nil || count > 0 {
+		return
+	}
+	if _, err := b.API.Send(tgbotapi.NewMessage(chatID, messages.LateTapsNote)); err != nil {
+		log.Printf("late: note send failed chat=%d session=%d err=%v", chatID, sessionID, err)
+	}
+}
+
+// lateNoteDue reports whether the empty-round message should mention people who tapped too late.
+func (b *Bot) lateNoteDue(sessionID int64, cfg ChatConfig) bool {
+	if !cfg.LateNote {
+		return false
+	}
+	n, err := b.Store.CountLateTaps(sessionID)
+	if err != nil {
+		log.Printf("late: count failed session=%d err=%v", sessionID, err)
+		return false
+	}
+	return n >= lateTapsForNote
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/messages"
+)
+
+// lateNotes counts messages carrying the late-tap note on their own.
+func lateNotes(fake *fakeTelegram) int {
+	n := 0
+	for _, p := range fake.sent("sendMessage") {
+		if p["text"] == messages.LateTapsNote {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLateTapAfterClose(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		name     string
+		lateNote bool
+		users    []int64 // joined before the close
+		want     int
+	}{
+		{"empty round", true, nil, 1},
+		{"note off", false, nil, 0},
+		{"round with participants", true, []int64{1, 2, 3}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			id := newTestSessionWith(t, b, chatID, tt.users...)
+			setTestSetting(t, b, chatID, "late_note", fmt.Sprint(tt.lateNote))
+			b.CloseAndPublish(id)
+
+			// the first user taps twice, which counts once; the note goes out with the second user
+			// and not again after
+			taps := []int64{10, 10, 11, 12}
+			for i, user := range taps {
+				b.handleUpdate(joinUpdate(fmt.Sprint("cb", i), id, user))
+			}
+			for i, a := range callbackAnswers(fake) {
+				if a != messages.SignupClosed {
+					t.Errorf("answer %d = %q, want %q", i+1, a, messages.SignupClosed)
+				}
+			}
+			if n, err := b.Store.CountLateTaps(id); err != nil || n != 3 {
+				t.Errorf("late taps = %d, %v; want 3", n, err)
+			}
+			if n := lateNotes(fake); n != tt.want {
+				t.Errorf("notes = %d, want %d", n, tt.want)
+			}
+			if ok, err := b.Store.IsParticipant(id, 10); err != nil || ok {
+				t.Errorf("late user joined = %v, %v; want false", ok, err)
+			}
+		})
+	}
+}
+
+func TestLateTapsBeforeClose(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	id := newTestSessionWith(t, b, chatID)
+	setTestSetting(t, b, chatID, "late_note", "on")
+	setTestDeadline(t, b, id, time.Now().Add(-time.Minute))
+
+	// past the deadline but not closed yet: taps are counted, the note waits for the results
+	for i, user := range []int64{10, 11} {
+		b.handleUpdate(joinUpdate(fmt.Sprint("cb", i), id, user))
+	}
+	if n := lateNotes(fake); n != 0 {
+		t.Errorf("notes before close = %d, want 0", n)
+	}
+	b.CloseAndPublish(id)
+	if got := lastReply(t, fake); !strings.HasSuffix(got, "\n"+messages.LateTapsNote) {
+		t.Errorf("empty results %q do not end with the note", got)
+	}
+}
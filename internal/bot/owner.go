@@ -0,0 +1,255 @@
+package bot
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"coffeetrix24/internal/messages"
+	"coffeetrix24/internal/scheduler"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// NotifyOwner sends an operational message to the owner, if one is configured.
+func (b *Bot) NotifyOwner(text string) {
+	if b.OwnerID == 0 {
+		return
+	}
+	if _, err := b.API.Send(tgbotapi.NewMessage(b.OwnerID, text)); err != nil {
+		log.Printf("owner: notify failed err=%v", err)
+	}
+}
+
+func (b *Bot) isOwner(m *tgbotapi.Message) bool {
+	return b.OwnerID != 0 && m.From != nil && m.From.ID == b.OwnerID
+}
+
+// confirmToken derives a short per-process token confirming a destructive action on a chat.
+func (b *Bot) confirmToken(action string, chatID int64) string {
+	mac := hmac.New(sha256.New, b.secret)
+	fmt.Fprintf(mac, "%s:%d", action, chatID)
+	return hex.EncodeToString(mac.Sum(nil))[:8]
+}
+
+// ownerChatArg parses the target chat ID of an owner command; on failure it replies with usage.
+func (b *Bot) ownerChatArg(m *tgbotapi.Message, usage string) (int64, []string, bool) {
+	args := strings.Fields(m.CommandArguments())
+	if len(args) == 0 {
+		b.reply(m, usage)
+		return 0, nil, false
+	}
+	chatID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.reply(m, usage)
+		return 0, nil, false
+	}
+	return chatID, args[1:], true
+}
+
+func (b *Bot) cmdInspect(m *tgbotapi.Message) {
+	if !b.isOwner(m) {
+		return
+	}
+	chatID, _, ok := b.ownerChatArg(m, messages.InspectUsage)
+	if !ok {
+		return
+	}
+	chat, err := b.Store.GetChat(chatID)
+	if errors.Is(err, sql.ErrNoRows) {
+		b.reply(m, messages.ChatNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("inspect: chat lookup failed chat=%d err=%v", chatID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	cfg, _ := b.EffectiveSettings(chatID)
+	sessions, err := b.Store.RecentSessions(chatID, 5, 0)
+	if err != nil {
+		log.Printf("inspect: sessions lookup failed chat=%d err=%v", chatID, err)
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(messages.InspectHeader+"\n", chat.Title, chat.ChatID, chat.JoinedAt.UTC().Format("2006-01-02")))
+	sb.WriteString(fmt.Sprintf(messages.InspectSettings+"\n", fmtDuration(cfg.SignupWindow), fmtBool(cfg.VerifyMembers)))
+	if len(sessions) == 0 {
+		sb.WriteString(messages.InspectNoSessions)
+	}
+	for _, ss := range sessions {
+		state := messages.SessionOpenLabel
+		if ss.Closed {
+			state = messages.SessionClosedLabel
+		}
+		sb.WriteString(fmt.Sprintf(messages.InspectSession+"\n", ss.Date, ss.ID, state, ss.Participants))
+	}
+	b.reply(m, sb.String())
+}
+
+// cmdPurgeChat deletes all data of a chat. The first call returns a confirmation token;
+// the purge runs only when repeated with it: /purgechat <chatID> <token>.
+func (b *Bot) cmdPurgeChat(m *tgbotapi.Message) {
+	if !b.isOwner(m) {
+		return
+	}
+	chatID, rest, ok := b.ownerChatArg(m, messages.PurgeUsage)
+	if !ok {
+		return
+	}
+	token := b.confirmToken("purgechat", chatID)
+	if len(rest) == 0 || !hmac.Equal([]byte(rest[0]), []byte(token)) {
+		b.reply(m, fmt.Sprintf(messages.PurgeConfirm, chatID, token))
+		return
+	}
+	if err := b.Store.PurgeChat(context.Background(), chatID); err != nil {
+		log.Printf("purge: failed chat=%d err=%v", chatID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if err := b.Store.Audit(m.From.ID, chatID, "purgechat", ""); err != nil {
+		log.Printf("purge: audit failed chat=%d err=%v", chatID, err)
+	}
+	log.Printf("purge: chat=%d purged by owner=%d", chatID, m.From.ID)
+	b.reply(m, fmt.Sprintf(messages.PurgeDone, chatID))
+}
+
+// cmdMergeUsers joins the history of a user's old account into the new one:
+// /mergeusers <keepID> <dropID>.
+func (b *Bot) cmdMergeUsers(m *tgbotapi.Message) {
+	if !b.isOwner(m) {
+		return
+	}
+	args := strings.Fields(m.CommandArguments())
+	if len(args) != 2 {
+		b.reply(m, messages.MergeUsersUsage)
+		return
+	}
+	keep, err1 := strconv.ParseInt(args[0], 10, 64)
+	drop, err2 := strconv.ParseInt(args[1], 10, 64)
+	if err1 != nil || err2 != nil || keep == drop {
+		b.reply(m, messages.MergeUsersUsage)
+		return
+	}
+	if err := b.Store.MergeUsers(keep, drop); err != nil {
+		log.Printf("mergeusers: failed keep=%d drop=%d err=%v", keep, drop, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if err := b.Store.Audit(m.From.ID, 0, "mergeusers", fmt.Sprintf("keep=%d drop=%d", keep, drop)); err != nil {
+		log.Printf("mergeusers: audit failed err=%v", err)
+	}
+	log.Printf("mergeusers: keep=%d drop=%d by owner=%d", keep, drop, m.From.ID)
+	b.reply(m, fmt.Sprintf(messages.MergeUsersDone, drop, keep))
+}
+
+// cmdUptime reports how long the process runs and how the last daily invite run went.
+func (b *Bot) cmdUptime(m *tgbotapi.Message) {
+	if !b.isOwner(m) {
+		return
+	}
+	text := fmt.Sprintf(messages.UptimeStarted, b.StartedAt.UTC().Format("2006-01-02 15:04 MST"), fmtDuration(time.Since(b.StartedAt).Truncate(time.Minute)))
+	run, ok, err := b.Store.GetDailyRun()
+	switch {
+	case err != nil:
+		log.Printf("uptime: daily run lookup failed err=%v", err)
+	case !ok:
+		text += "\n" + messages.UptimeNoDailyRun
+	default:
+		text += "\n" + fmt.Sprintf(messages.UptimeDailyRun, run.At.UTC().Format("2006-01-02 15:04 MST"), run.Chats, run.Sent, run.Skipped, run.Failed, run.Elapsed.Round(time.Millisecond))
+	}
+	b.reply(m, text)
+}
+
+// cmdDiag reports sessions whose invite message ID was never stored, i.e. likely failed sends.
+func (b *Bot) cmdDiag(m *tgbotapi.Message) {
+	if !b.isOwner(m) {
+		return
+	}
+	d, err := b.Store.InviteDiagnostics(10)
+	if err != nil {
+		log.Printf("diag: failed err=%v", err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(messages.DiagInvites+"\n", d.Total, d.Sent, d.MissingOpen, d.MissingClosed))
+	for _, s := range d.Recent {
+		state := messages.SessionOpenLabel
+		if s.Closed {
+			state = messages.SessionClosedLabel
+		}
+		sb.WriteString(fmt.Sprintf(messages.DiagMissing+"\n", s.Date, s.ID, s.ChatID, state))
+	}
+	b.reply(m, sb.String())
+}
+
+// cmdDay summarises every chat's sessions on a date, today (UTC) by default: /day [YYYY-MM-DD].
+func (b *Bot) cmdDay(m *tgbotapi.Message) {
+	if !b.isOwner(m) {
+		return
+	}
+	date := strings.TrimSpace(m.CommandArguments())
+	if date == "" {
+		date = time.Now().UTC().Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		b.reply(m, messages.DayUsage)
+		return
+	}
+	sessions, err := b.Store.SessionsByDate(date)
+	if err != nil {
+		log.Printf("day: sessions failed date=%s err=%v", date, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	parts, err := b.Store.ParticipantsForDate(date)
+	if err != nil {
+		log.Printf("day: participants failed date=%s err=%v", date, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	total := 0
+	var lines strings.Builder
+	for _, s := range sessions {
+		names := make([]string, len(parts[s.ID]))
+		for i, p := range parts[s.ID] {
+			names[i] = p.DisplayName
+		}
+		total += len(names)
+		lines.WriteString("\n" + fmt.Sprintf(messages.DaySession, s.ChatID, s.Slot, len(names)))
+		if len(names) > 0 {
+			lines.WriteString(": " + strings.Join(names, ", "))
+		}
+	}
+	b.reply(m, fmt.Sprintf(messages.DaySummary, date, len(sessions), total)+lines.String())
+}
+
+// cmdCloseInterval changes how often due sessions are closed until the next restart, mostly to
+// watch a test session close quickly: /closeinterval 5s, or /closeinterval default.
+func (b *Bot) cmdCloseInterval(m *tgbotapi.Message) {
+	if !b.isOwner(m) || b.SetCloseInterval == nil {
+		return
+	}
+	arg := strings.TrimSpace(m.CommandArguments())
+	d := scheduler.DefaultCloseInterval
+	if arg != "default" {
+		var err error
+		if d, err = time.ParseDuration(arg); err != nil {
+			b.reply(m, messages.CloseIntervalUsage)
+			return
+		}
+	}
+	if err := b.SetCloseInterval(d); err != nil {
+		b.reply(m, messages.CloseIntervalUsage)
+		return
+	}
+	log.Printf("closeinterval: set to %s by owner=%d", d, m.From.ID)
+	b.reply(m, fmt.Sprintf(messages.CloseIntervalDone, fmtDuration(d)))
+}
@@ -0,0 +1,41 @@
+package bot
+
+import (
+	"log"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// cmdPause handles /pause and /resume: admins stop the chat's scheduled invites for a while and
+// turn them back on. Sessions already open run to their deadline, and history is kept.
+func (b *Bot) cmdPause(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	pause := m.Command() == "pause"
+	changed, err := b.Store.SetChatPaused(m.Chat.ID, pause)
+	if err != nil {
+		log.Printf("%s: store failed chat=%d err=%v", m.Command(), m.Chat.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	switch {
+	case !changed && pause:
+		b.reply(m, messages.PauseAlready)
+		return
+	case !changed:
+		b.reply(m, messages.ResumeAlready)
+		return
+	}
+	if err := b.Store.Audit(m.From.ID, m.Chat.ID, m.Command(), ""); err != nil {
+		log.Printf("%s: audit failed chat=%d err=%v", m.Command(), m.Chat.ID, err)
+	}
+	log.Printf("%s: chat=%d by=%d", m.Command(), m.Chat.ID, m.From.ID)
+	if pause {
+		b.reply(m, messages.PauseDone)
+	} else {
+		b.reply(m, messages.ResumeDone)
+	}
+}
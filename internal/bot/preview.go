@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// openSessionToday returns today's session of the chat if it still accepts signups.
+func (b *Bot) openSessionToday(chatID int64) (int64, bool) {
+	date, slot := b.chatSession(chatID)
+	sessionID, _, err := b.Store.GetSessionByChatDate(chatID, date, slot)
+	if err != nil {
+		return 0, false
+	}
+	if open, err := b.Store.SessionOpen(sessionID, time.Now()); err != nil || !open {
+		return 0, false
+	}
+	return sessionID, true
+}
+
+// cmdPreview shows admins a tentative grouping of today's participants and stores it.
+// /reshuffle does the same with a new shuffle; /confirm publishes the stored grouping.
+func (b *Bot) cmdPreview(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	sessionID, ok := b.openSessionToday(m.Chat.ID)
+	if !ok {
+		b.reply(m, messages.NoOpenSession)
+		return
+	}
+	res, err := b.ComputeResults(sessionID)
+	if err != nil {
+		log.Printf("preview: compute failed session=%d err=%v", sessionID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if len(res.Groups) == 0 {
+		b.reply(m, messages.PreviewEmpty)
+		return
+	}
+	if err := b.Store.SavePendingGroups(sessionID, res.Groups); err != nil {
+		log.Printf("preview: store failed session=%d err=%v", sessionID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	cfg, _ := b.EffectiveSettings(m.Chat.ID)
+	b.reply(m, messages.PreviewHeader+"\n"+RenderGroups(res.Groups, cfg.Render)+messages.PreviewFooter)
+}
+
+// cmdConfirm locks the previewed grouping and publishes it right away.
+func (b *Bot) cmdConfirm(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	sessionID, ok := b.openSessionToday(m.Chat.ID)
+	if !ok {
+		b.reply(m, messages.NoOpenSession)
+		return
+	}
+	confirmed, err := b.Store.ConfirmPendingGroups(sessionID)
+	if err != nil {
+		log.Printf("confirm: store failed session=%d err=%v", sessionID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if !confirmed {
+		b.reply(m, messages.ConfirmNoPreview)
+		return
+	}
+	log.Printf("confirm: session=%d confirmed by=%d", sessionID, m.From.ID)
+	b.CloseAndPublish(sessionID)
+}
+
+// cmdClose ends signups for today's open session now and publishes its groups.
+func (b *Bot) cmdClose(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	sessionID, ok := b.openSessionToday(m.Chat.ID)
+	if !ok {
+		b.reply(m, messages.NoOpenSession)
+		return
+	}
+	log.Printf("close: session=%d closed early by=%d", sessionID, m.From.ID)
+	b.CloseAndPublish(sessionID)
+}
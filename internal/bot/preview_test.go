@@ -0,0 +1,92 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"coffeetrix24/internal/logic"
+)
+
+// groupKey renders groups as sorted member IDs so groupings compare regardless of order.
+func groupKey(groups [][]int64) string {
+	keys := make([]string, len(groups))
+	for i, g := range groups {
+		sort.Slice(g, func(a, b int) bool { return g[a] < g[b] })
+		keys[i] = fmt.Sprint(g)
+	}
+	sort.Strings(keys)
+	return fmt.Sprint(keys)
+}
+
+func pendingKey(t *testing.T, b *Bot, sessionID int64) string {
+	t.Helper()
+	groups, _, ok, err := b.Store.PendingGroups(sessionID)
+	if err != nil || !ok {
+		t.Fatalf("pending groups: ok=%v err=%v", ok, err)
+	}
+	return groupKey(idsOf(groups))
+}
+
+func idsOf(groups []logic.Group) [][]int64 {
+	res := make([][]int64, len(groups))
+	for i, g := range groups {
+		for _, m := range g.Members {
+			res[i] = append(res[i], m.ID)
+		}
+	}
+	return res
+}
+
+func TestReshuffleThenConfirm(t *testing.T) {
+	const chatID, admin = -100, 42
+	b, fake := newTestBot(t)
+	fake.respond = asAdmin
+	id := newTestSessionWith(t, b, chatID, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+
+	b.cmdPreview(testCommand(chatID, admin, "/preview"))
+	first := pendingKey(t, b, id)
+	// a reshuffle draws a new grouping; nine users make a repeat unlikely but possible
+	confirmed := first
+	for i := 0; i < 10 && confirmed == first; i++ {
+		b.cmdPreview(testCommand(chatID, admin, "/reshuffle"))
+		confirmed = pendingKey(t, b, id)
+	}
+	if confirmed == first {
+		t.Fatalf("/reshuffle kept the grouping %s", first)
+	}
+
+	b.cmdConfirm(testCommand(chatID, admin, "/confirm"))
+
+	closed, err := b.Store.IsSessionClosed(id)
+	if err != nil || !closed {
+		t.Fatalf("session closed = %v, err = %v", closed, err)
+	}
+	published, err := b.Store.GetSessionGroups(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids [][]int64
+	for _, g := range published {
+		var members []int64
+		for _, p := range g {
+			members = append(members, p.UserID)
+		}
+		ids = append(ids, members)
+	}
+	if got := groupKey(ids); got != confirmed {
+		t.Errorf("published %s, want confirmed %s", got, confirmed)
+	}
+}
+
+func TestConfirmWithoutPreview(t *testing.T) {
+	const chatID, admin = -100, 42
+	b, fake := newTestBot(t)
+	fake.respond = asAdmin
+	id := newTestSessionWith(t, b, chatID, 1, 2, 3)
+
+	b.cmdConfirm(testCommand(chatID, admin, "/confirm"))
+	if closed, _ := b.Store.IsSessionClosed(id); closed {
+		t.Error("session closed without a preview")
+	}
+}
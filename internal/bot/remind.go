@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Remind posts the reminder that signups end soon as a reply to the session's invite. Each
+// session is reminded at most once, even if the post fails.
+func (b *Bot) Remind(sessionID int64) {
+	defer b.track()()
+	marked, err := b.Store.MarkReminded(sessionID)
+	if err != nil {
+		log.Printf("remind: mark failed session=%d err=%v", sessionID, err)
+		return
+	}
+	if !marked {
+		return
+	}
+	ref, err := b.Store.GetInviteRef(sessionID)
+	if err != nil || ref.MessageID == 0 {
+		log.Printf("remind: invite lookup failed session=%d err=%v", sessionID, err)
+		return
+	}
+	deadline, ok, err := b.Store.SessionDeadline(sessionID)
+	if err != nil || !ok {
+		log.Printf("remind: deadline lookup failed session=%d err=%v", sessionID, err)
+		return
+	}
+	left := int(time.Until(deadline).Round(time.Minute) / time.Minute)
+	if left < 1 {
+		left = 1
+	}
+	msg := tgbotapi.NewMessage(ref.ChatID, fmt.Sprintf(messages.RemindLeft, left, ruPlural(left, messages.MinuteOne, messages.MinuteFew, messages.MinuteMany)))
+	msg.ReplyToMessageID = ref.MessageID
+	if _, err := b.API.Send(msg); err != nil {
+		log.Printf("remind: send failed chat=%d session=%d err=%v", ref.ChatID, sessionID, err)
+		return
+	}
+	log.Printf("remind: chat=%d session=%d minutes_left=%d", ref.ChatID, sessionID, left)
+}
@@ -0,0 +1,174 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+
+	"coffeetrix24/internal/db"
+	"coffeetrix24/internal/logic"
+	"coffeetrix24/internal/messages"
+)
+
+const (
+	labelsNumeric = "numeric"
+	labelsAlpha   = "alpha"
+	labelsEmoji   = "emoji"
+)
+
+// RenderOptions tunes how results are presented in a chat.
+type RenderOptions struct {
+	// LabelSingleGroup prints "Группа 1:" even when there is only one group.
+	LabelSingleGroup bool
+	// LabelStyle is labelsNumeric (default), labelsAlpha or labelsEmoji.
+	LabelStyle string
+	// Emoji are the labels used in order for labelsEmoji.
+	Emoji []string
+	// SummaryThreshold switches to a summary when more participants than this are grouped (0: never).
+	SummaryThreshold int
+	// HTML renders for ParseMode HTML: text is escaped and members are linked to their profiles,
+	// so Telegram notifies them.
+	HTML bool
+}
+
+// Summarized reports whether the results are too large to list in the message.
+func (o RenderOptions) Summarized(groups []logic.Group) bool {
+	return o.SummaryThreshold > 0 && groupedCount(groups) > o.SummaryThreshold
+}
+
+func groupedCount(groups []logic.Group) int {
+	n := 0
+	for _, g := range groups {
+		n += len(g.Members)
+	}
+	return n
+}
+
+// groupLabels returns one label per group. Styles that run out of distinct labels
+// for n groups fall back to numbers so labels stay unique.
+func groupLabels(n int, opts RenderOptions) []string {
+	labels := make([]string, n)
+	for i := range labels {
+		switch {
+		case opts.LabelStyle == labelsAlpha && n <= 26:
+			labels[i] = string(rune('A' + i))
+		case opts.LabelStyle == labelsEmoji && n <= len(opts.Emoji):
+			labels[i] = opts.Emoji[i]
+		default:
+			labels[i] = strconv.Itoa(i + 1)
+		}
+	}
+	return labels
+}
+
+// RenderResults formats the results message posted to the chat.
+func RenderResults(res ResultsData, opts RenderOptions) string {
+	if opts.Summarized(res.Groups) {
+		return messages.ResultsHeader + "\n" + RenderSummary(res.Groups) + "\n" + messages.ResultsRosterAttached
+	}
+	text := messages.ResultsHeader + "\n" + RenderGroups(res.Groups, opts)
+	if len(res.Facilitators) > 0 {
+		names := make([]string, len(res.Facilitators))
+		for i, p := range res.Facilitators {
+			names[i] = participantName(p)
+			if opts.HTML {
+				names[i] = mentionHTML(p)
+			}
+		}
+		text += fmt.Sprintf(messages.ResultsFacilitators, strings.Join(names, ", "))
+	}
+	return text
+}
+
+// RenderGroups lists groups one per line. A lone group is listed without its label
+// unless opts.LabelSingleGroup is set.
+func RenderGroups(groups []logic.Group, opts RenderOptions) string {
+	var sb strings.Builder
+	labeled := len(groups) > 1 || opts.LabelSingleGroup
+	labels := groupLabels(len(groups), opts)
+	for i, g := range groups {
+		if labeled {
+			label := labels[i]
+			if opts.HTML {
+				label = html.EscapeString(label)
+			}
+			sb.WriteString(fmt.Sprintf(messages.GroupLabel, label))
+		}
+		for j, u := range g.Members {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			if opts.HTML {
+				sb.WriteString(mentionLink(u.ID, u.Name))
+			} else {
+				sb.WriteString(u.Name)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// RenderSummary describes groups by size instead of listing members,
+// e.g. "15 групп по 3 человека, всего 45 участников".
+func RenderSummary(groups []logic.Group) string {
+	bySize := map[int]int{}
+	var sizes []int
+	for _, g := range groups {
+		if bySize[len(g.Members)] == 0 {
+			sizes = append(sizes, len(g.Members))
+		}
+		bySize[len(g.Members)]++
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+	parts := make([]string, 0, len(sizes)+1)
+	for _, size := range sizes {
+		n := bySize[size]
+		parts = append(parts, fmt.Sprintf(messages.SummaryGroups,
+			n, ruPlural(n, messages.GroupOne, messages.GroupFew, messages.GroupMany),
+			size, ruPlural(size, messages.PersonOne, messages.PersonFew, messages.PersonMany)))
+	}
+	total := groupedCount(groups)
+	parts = append(parts, fmt.Sprintf(messages.SummaryTotal,
+		total, ruPlural(total, messages.ParticipantOne, messages.ParticipantFew, messages.ParticipantMany)))
+	return strings.Join(parts, ", ")
+}
+
+// ruPlural picks the Russian plural form for n: 1 группа, 2 группы, 5 групп.
+func ruPlural(n int, one, few, many string) string {
+	n %= 100
+	if n >= 11 && n <= 14 {
+		return many
+	}
+	switch n % 10 {
+	case 1:
+		return one
+	case 2, 3, 4:
+		return few
+	}
+	return many
+}
+
+// withTeam appends the member's team to their name, "Иван (Маркетинг)"; no team leaves it as is.
+func withTeam(name, team string) string {
+	if team == "" {
+		return name
+	}
+	return fmt.Sprintf(messages.NameWithTeam, name, team)
+}
+
+// mentionHTML links a participant's name to their profile so Telegram notifies them.
+func mentionHTML(p db.Participant) string {
+	return mentionLink(p.UserID, participantName(p))
+}
+
+// mentionLink is name escaped for HTML and linked to the user's profile. Test-mode fake users
+// have no profile, so their name is only escaped.
+func mentionLink(userID int64, name string) string {
+	if db.IsFakeUserID(userID) {
+		return html.EscapeString(name)
+	}
+	return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>`, userID, html.EscapeString(name))
+}
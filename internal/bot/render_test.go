@@ -0,0 +1,174 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"coffeetrix24/internal/db"
+	"coffeetrix24/internal/logic"
+	"coffeetrix24/internal/messages"
+)
+
+// testGroups builds groups of named members with IDs counting from 1.
+func testGroups(sizes ...int) []logic.Group {
+	groups := make([]logic.Group, len(sizes))
+	id := int64(0)
+	for i, size := range sizes {
+		for j := 0; j < size; j++ {
+			id++
+			groups[i].Members = append(groups[i].Members, logic.User{ID: id, Name: string(rune('a' + id - 1))})
+		}
+	}
+	return groups
+}
+
+func TestRenderGroups(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups []logic.Group
+		opts   RenderOptions
+		want   string
+	}{
+		{"single group unlabeled", testGroups(3), RenderOptions{}, "a, b, c\n"},
+		{"single group labeled", testGroups(3), RenderOptions{LabelSingleGroup: true}, "Группа 1: a, b, c\n"},
+		{"several groups labeled", testGroups(2, 2), RenderOptions{}, "Группа 1: a, b\nГруппа 2: c, d\n"},
+		{"html mentions", []logic.Group{{Members: []logic.User{{ID: 7, Name: "<Bob>"}, {ID: 8, Name: "Ann & Co"}}}}, RenderOptions{HTML: true},
+			`<a href="tg://user?id=7">&lt;Bob&gt;</a>, <a href="tg://user?id=8">Ann &amp; Co</a>` + "\n"},
+		{"fake users not linked", []logic.Group{{Members: []logic.User{{ID: db.FakeUserIDBase + 1, Name: "Тест <1>"}}}}, RenderOptions{HTML: true}, "Тест &lt;1&gt;\n"},
+	}
+	for _, tt := range tests {
+		if got := RenderGroups(tt.groups, tt.opts); got != tt.want {
+			t.Errorf("%s: RenderGroups =\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGroupLabels(t *testing.T) {
+	emoji := []string{"🍩", "☕", "🥐"}
+	tests := []struct {
+		name string
+		n    int
+		opts RenderOptions
+		want string
+	}{
+		{"numeric", 3, RenderOptions{}, "[1 2 3]"},
+		{"alpha", 3, RenderOptions{LabelStyle: labelsAlpha}, "[A B C]"},
+		{"alpha full alphabet", 26, RenderOptions{LabelStyle: labelsAlpha}, "[A B C D E F G H I J K L M N O P Q R S T U V W X Y Z]"},
+		{"alpha past Z falls back", 27, RenderOptions{LabelStyle: labelsAlpha}, "[1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24 25 26 27]"},
+		{"emoji", 2, RenderOptions{LabelStyle: labelsEmoji, Emoji: emoji}, "[🍩 ☕]"},
+		{"emoji all used", 3, RenderOptions{LabelStyle: labelsEmoji, Emoji: emoji}, "[🍩 ☕ 🥐]"},
+		{"emoji run out falls back", 4, RenderOptions{LabelStyle: labelsEmoji, Emoji: emoji}, "[1 2 3 4]"},
+		{"none", 0, RenderOptions{LabelStyle: labelsAlpha}, "[]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(groupLabels(tt.n, tt.opts)); got != tt.want {
+			t.Errorf("%s: groupLabels(%d) = %s, want %s", tt.name, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{"numeric", true},
+		{"alpha", true},
+		{"emoji:🍩,☕", true},
+		{"emoji:🍩", false},
+		{"roman", false},
+	}
+	for _, tt := range tests {
+		if _, err := parseLabels(tt.in); (err == nil) != tt.ok {
+			t.Errorf("parseLabels(%q) err = %v, want ok %v", tt.in, err, tt.ok)
+		}
+	}
+}
+
+func TestRenderResultsSummaryThreshold(t *testing.T) {
+	groups := testGroups(3, 3, 2) // 8 participants
+	tests := []struct {
+		threshold  int
+		summarized bool
+	}{
+		{0, false},
+		{7, true},
+		{8, false},
+		{9, false},
+	}
+	for _, tt := range tests {
+		opts := RenderOptions{SummaryThreshold: tt.threshold}
+		if got := opts.Summarized(groups); got != tt.summarized {
+			t.Errorf("threshold %d: Summarized = %v, want %v", tt.threshold, got, tt.summarized)
+		}
+		got := RenderResults(ResultsData{Groups: groups}, opts)
+		want := messages.ResultsHeader + "\n" + RenderGroups(groups, opts)
+		if tt.summarized {
+			want = messages.ResultsHeader + "\n2 группы по 3 человека, 1 группа по 2 человека, всего 8 участников\n" + messages.ResultsRosterAttached
+		}
+		if got != want {
+			t.Errorf("threshold %d: RenderResults =\n%s\nwant\n%s", tt.threshold, got, want)
+		}
+	}
+}
+
+func TestRuPlural(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "групп"}, {1, "группа"}, {2, "группы"}, {4, "группы"}, {5, "групп"},
+		{11, "групп"}, {12, "групп"}, {14, "групп"}, {21, "группа"}, {22, "группы"},
+		{101, "группа"}, {111, "групп"},
+	}
+	for _, tt := range tests {
+		if got := ruPlural(tt.n, messages.GroupOne, messages.GroupFew, messages.GroupMany); got != tt.want {
+			t.Errorf("ruPlural(%d) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestWithTeam(t *testing.T) {
+	if got, want := withTeam("Иван", "Маркетинг"), "Иван (Маркетинг)"; got != want {
+		t.Errorf("withTeam = %q, want %q", got, want)
+	}
+	if got := withTeam("Иван", ""); got != "Иван" {
+		t.Errorf("withTeam without team = %q, want the name alone", got)
+	}
+}
+
+func TestRenderResultsTeamTags(t *testing.T) {
+	const chatID = -100
+	for _, on := range []bool{false, true} {
+		t.Run(fmt.Sprint("team_tags ", on), func(t *testing.T) {
+			b, _ := newTestBot(t)
+			id := newTestSessionWith(t, b, chatID, 1, 2, 3)
+			setTestSetting(t, b, chatID, "team_tags", fmt.Sprint(on))
+			// user 3 has no team and is listed by name alone either way
+			for user, team := range map[int64]string{1: "Маркетинг", 2: "R&D"} {
+				if err := b.Store.SetTeam(user, team); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			res, err := b.ComputeResults(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := RenderResults(res, RenderOptions{HTML: true})
+			want := map[string]bool{
+				`<a href="tg://user?id=1">User 1 (Маркетинг)</a>`: on,
+				`<a href="tg://user?id=2">User 2 (R&amp;D)</a>`:   on,
+				`<a href="tg://user?id=1">User 1</a>`:             !on,
+				`<a href="tg://user?id=2">User 2</a>`:             !on,
+				`<a href="tg://user?id=3">User 3</a>`:             true,
+			}
+			for s, present := range want {
+				if strings.Contains(text, s) != present {
+					t.Errorf("results %q: contains %q = %v, want %v", text, s, !present, present)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"coffeetrix24/internal/db"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// updateRecorder appends incoming updates to a file as JSON lines for later replay.
+// Updates carry no bot token, so the file holds only what users sent.
+type updateRecorder struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// RecordUpdates starts appending every polled update to path. Close the returned file to stop.
+func (b *Bot) RecordUpdates(path string) (io.Closer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	b.recorder = &updateRecorder{f: f}
+	return f, nil
+}
+
+func (r *updateRecorder) record(upd tgbotapi.Update) {
+	data, err := json.Marshal(upd)
+	if err != nil {
+		log.Printf("record: marshal failed update=%d err=%v", upd.UpdateID, err)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.f.Write(append(data, '\n')); err != nil {
+		log.Printf("record: write failed update=%d err=%v", upd.UpdateID, err)
+	}
+}
+
+// replayResult is what the offline API answers to every call. It is a superset of the fields
+// of User, Message and ChatMember, so each result decodes into whatever the caller expects;
+// chat member lookups see an administrator.
+const replayResult = `{"id":1,"is_bot":true,"first_name":"replay","username":"replay_bot",` +
+	`"message_id":1,"date":0,"chat":{"id":0,"type":"private"},` +
+	`"status":"administrator","user":{"id":0,"is_bot":false,"first_name":"replay"}}`
+
+// replayTransport answers Telegram API calls locally and logs them instead of sending.
+type replayTransport struct{}
+
+func (replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+	if method != "getMe" {
+		_ = req.ParseMultipartForm(1 << 20)
+		log.Printf("replay: %s %v", method, req.Form)
+	}
+	body := `{"ok":true,"result":` + replayResult + `}`
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+		Request:    req,
+	}, nil
+}
+
+// NewReplayBot builds a bot whose Telegram API calls never leave the process, for replaying
+// recorded updates against store.
+func NewReplayBot(store *db.Store) (*Bot, error) {
+	api, err := tgbotapi.NewBotAPIWithClient("replay", tgbotapi.APIEndpoint, &http.Client{Transport: replayTransport{}})
+	if err != nil {
+		return nil, err
+	}
+	return New(api, store), nil
+}
+
+// Replay feeds updates recorded by RecordUpdates through the normal handlers, in order.
+// It returns how many updates were processed.
+func (b *Bot) Replay(r io.Reader) (int, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 4<<20)
+	n, lineNo := 0, 0
+	for sc.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var upd tgbotapi.Update
+		if err := json.Unmarshal(line, &upd); err != nil {
+			return n, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if err := b.processUpdate(upd); err != nil {
+			log.Printf("replay: update=%d failed: %v", upd.UpdateID, err)
+		}
+		n++
+	}
+	return n, sc.Err()
+}
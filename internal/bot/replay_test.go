@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"coffeetrix24/internal/db"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	const chatID = -100
+	path := filepath.Join(t.TempDir(), "updates.jsonl")
+	rec, _ := newTestBot(t)
+	rec.API.Token = "123456:SECRET"
+	id := newTestSessionWith(t, rec, chatID)
+	f, err := rec.RecordUpdates(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var updates []tgbotapi.Update
+	for i, user := range []int64{1, 2, 3} {
+		updates = append(updates, joinUpdate(fmt.Sprint("join", i), id, user))
+	}
+	updates = append(updates, tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID: "leave", From: &tgbotapi.User{ID: 2, FirstName: "Ann"}, Data: fmt.Sprintf("leave:%d", id)}})
+	msg := testCommand(chatID, 4, "/team Маркетинг")
+	msg.From.UserName = "vera"
+	updates = append(updates, tgbotapi.Update{Message: msg})
+	for i := range updates {
+		updates[i].UpdateID = i + 1
+		rec.deliver(updates[i])
+	}
+	// a redelivered update is neither handled nor recorded again
+	rec.deliver(updates[0])
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != len(updates) {
+		t.Errorf("recorded %d updates, want %d", n, len(updates))
+	}
+	if strings.Contains(string(data), rec.API.Token) {
+		t.Error("recording contains the bot token")
+	}
+
+	// replay against a fresh store holding the same session
+	st, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = st.DB.Close() })
+	if err := st.EnsureSettings("09:00"); err != nil {
+		t.Fatal(err)
+	}
+	replay, err := NewReplayBot(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := newTestSessionWith(t, replay, chatID); got != id {
+		t.Fatalf("replay session = %d, want %d", got, id)
+	}
+	r, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	n, err := replay.Replay(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(updates) {
+		t.Errorf("replayed %d updates, want %d", n, len(updates))
+	}
+
+	for _, b := range []*Bot{rec, replay} {
+		parts, err := b.Store.GetParticipants(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		for _, p := range parts {
+			ids = append(ids, p.UserID)
+		}
+		if want := []int64{1, 3}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("participants = %v, want %v", ids, want)
+		}
+		teams, err := b.Store.Teams([]int64{4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if teams[4] != "Маркетинг" {
+			t.Errorf("team of user 4 = %q, want Маркетинг", teams[4])
+		}
+		if p, ok, err := b.Store.LookupUsername("vera"); err != nil || !ok || p.UserID != 4 {
+			t.Errorf("directory entry = %+v, %v, %v; want user 4", p, ok, err)
+		}
+	}
+}
+
+func TestReplayMalformed(t *testing.T) {
+	st, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = st.DB.Close() })
+	b, err := NewReplayBot(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := b.Replay(strings.NewReader(`{"update_id":1}` + "\n\n" + `{"update_id":` + "\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("err = %v, want a decode error on line 3", err)
+	}
+	if n != 1 {
+		t.Errorf("replayed %d updates before the error, want 1", n)
+	}
+}
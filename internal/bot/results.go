@@ -0,0 +1,356 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"coffeetrix24/internal/db"
+	"coffeetrix24/internal/logic"
+	"coffeetrix24/internal/messages"
+	"coffeetrix24/internal/metrics"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ResultsData is the structured outcome of a session, independent of how it is presented.
+type ResultsData struct {
+	SessionID    int64
+	ChatID       int64
+	Date         string
+	Participants []db.Participant
+	Groups       []logic.Group
+	// Facilitators joined but are not grouped; they are listed separately.
+	Facilitators []db.Participant
+}
+
+// ComputeResults loads a session's participants and splits them into groups without sending anything.
+func (b *Bot) ComputeResults(sessionID int64) (ResultsData, error) {
+	res := ResultsData{SessionID: sessionID}
+	chatID, date, err := b.Store.GetSessionInfo(sessionID)
+	if err != nil {
+		return res, err
+	}
+	res.ChatID, res.Date = chatID, date
+	parts, err := b.Store.GetParticipants(sessionID)
+	if err != nil {
+		return res, err
+	}
+	cfg, _ := b.EffectiveSettings(chatID)
+	if cfg.VerifyMembers {
+		parts = b.dropDepartedMembers(chatID, sessionID, parts)
+	}
+	res.Participants = parts
+	if len(parts) == 0 {
+		return res, nil
+	}
+	grouped := parts
+	if fac, err := b.Store.Facilitators(chatID); err != nil {
+		log.Printf("results: facilitators lookup failed chat=%d err=%v", chatID, err)
+	} else if len(fac) > 0 {
+		var rest, facs []db.Participant
+		for _, p := range parts {
+			if fac[p.UserID] {
+				facs = append(facs, p)
+			} else {
+				rest = append(rest, p)
+			}
+		}
+		// a lone participant would end up in a group of one; let the facilitators join them instead
+		if len(rest) != 1 {
+			grouped, res.Facilitators = rest, facs
+		}
+	}
+	teams := b.teamsOf(cfg, grouped)
+	users := make([]logic.User, 0, len(grouped))
+	for _, p := range grouped {
+		users = append(users, logic.User{ID: p.UserID, Name: withTeam(participantName(p), teams[p.UserID])})
+	}
+	res.Groups = b.makeGroups(chatID, cfg, users)
+	return res, nil
+}
+
+// makeGroups splits users into groups, keeping apart those who met within cfg.AvoidRepeatDays.
+// Every call shuffles anew, so /reshuffle gives a different grouping; published groups are stored
+// rather than recomputed. A failed history lookup only loses the preference.
+func (b *Bot) makeGroups(chatID int64, cfg ChatConfig, users []logic.User) []logic.Group {
+	seed := time.Now().UnixNano()
+	if b.groupSeed != nil {
+		seed = b.groupSeed()
+	}
+	r := rand.New(rand.NewSource(seed))
+	if cfg.AvoidRepeatDays <= 0 {
+		return logic.MakeGroupsWithConfigRand(users, cfg.Grouping, r)
+	}
+	recent, err := b.Store.GetRecentPairs(chatID, cfg.AvoidRepeatDays)
+	if err != nil {
+		log.Printf("results: recent pairs lookup failed chat=%d err=%v", chatID, err)
+		return logic.MakeGroupsWithConfigRand(users, cfg.Grouping, r)
+	}
+	return logic.MakeGroupsAvoidingHistory(users, cfg.Grouping, recent, r)
+}
+
+// teamsOf looks up teams of the participants when the chat shows them. A failed lookup only
+// loses the annotation.
+func (b *Bot) teamsOf(cfg ChatConfig, parts []db.Participant) map[int64]string {
+	if !cfg.TeamTags {
+		return nil
+	}
+	ids := make([]int64, len(parts))
+	for i, p := range parts {
+		ids[i] = p.UserID
+	}
+	teams, err := b.Store.Teams(ids)
+	if err != nil {
+		log.Printf("results: teams lookup failed err=%v", err)
+		return nil
+	}
+	return teams
+}
+
+// participantName picks the label shown for a participant in results.
+func participantName(p db.Participant) string {
+	name := logic.SanitizeName(p.DisplayName)
+	if name == "" && p.Username != "" {
+		name = "@" + p.Username
+	}
+	if name == "" {
+		name = fmt.Sprintf("id:%d", p.UserID)
+	}
+	return name
+}
+
+func (b *Bot) CloseAndPublish(sessionID int64) {
+	defer b.track()()
+	claimed, err := b.Store.ClaimSessionForClose(sessionID, time.Now())
+	if err != nil {
+		log.Printf("close: claim failed session=%d err=%v", sessionID, err)
+		return
+	}
+	if !claimed {
+		log.Printf("close: session=%d already closed or being closed elsewhere", sessionID)
+		return
+	}
+	// release the claim on any failure below so the closer can retry
+	release := func() {
+		if err := b.Store.ReleaseSessionClaim(sessionID); err != nil {
+			log.Printf("close: release claim failed session=%d err=%v", sessionID, err)
+		}
+	}
+	if b.TestMode {
+		b.addTestFakes(sessionID)
+	}
+	res, err := b.ComputeResults(sessionID)
+	if err != nil {
+		log.Printf("close: compute results failed session=%d err=%v", sessionID, err)
+		release()
+		return
+	}
+	// an admin-confirmed preview is published as is instead of a fresh shuffle
+	if groups, confirmed, ok, err := b.Store.PendingGroups(sessionID); err != nil {
+		log.Printf("close: pending groups lookup failed session=%d err=%v", sessionID, err)
+	} else if ok && confirmed {
+		res.Groups = groups
+	}
+	cfg, _ := b.EffectiveSettings(res.ChatID)
+	pairOnly := isLonePair(res.Groups) && cfg.PairOnlyPolicy != pairOnlyPublish
+	var pair []db.Participant
+	if pairOnly {
+		pair = groupParticipants(res.Participants, res.Groups[0])
+		res.Groups = nil
+	}
+	groups, cancelled := applySmallGroupPolicy(res.Groups, cfg)
+	res.Groups = groups
+	empty := len(res.Groups) == 0 && !cancelled && !pairOnly
+	var text string
+	var asHTML, sendDMs bool
+	switch {
+	case pairOnly && cfg.PairOnlyPolicy == pairOnlyCarry:
+		text = messages.PairOnlyCarried
+	case pairOnly:
+		text = messages.PairOnlyCancelled
+	case cancelled:
+		text = fmt.Sprintf(messages.SmallGroupsCancelled, cfg.MinGroupSize)
+	case empty:
+		text = b.emptyResultsText(res.ChatID, cfg)
+		if b.lateNoteDue(sessionID, cfg) {
+			text += "\n" + messages.LateTapsNote
+		}
+	default:
+		opts := cfg.Render
+		opts.HTML = true
+		text, asHTML = RenderResults(res, opts), true
+		sendDMs = cfg.ResultsVisibility == visibilityPrivate || cfg.ResultsVisibility == visibilityBoth
+		if cfg.ResultsVisibility == visibilityPrivate {
+			text, asHTML = messages.ResultsSentPrivately, false
+		}
+	}
+	msg := tgbotapi.NewMessage(res.ChatID, text)
+	if asHTML {
+		msg.ParseMode = tgbotapi.ModeHTML
+	}
+	if len(res.Groups) > 0 {
+		msg.ReplyMarkup = feedbackKeyboard(sessionID)
+	}
+	sent, err := b.sendRetrying(msg)
+	if err != nil {
+		log.Printf("close: telegram send failed chat=%d session=%d err=%v", res.ChatID, sessionID, err)
+		release()
+		return
+	}
+	if len(res.Groups) > 0 {
+		if err := b.Store.SetResultMessageID(sessionID, sent.MessageID); err != nil {
+			log.Printf("close: save result message failed session=%d err=%v", sessionID, err)
+		}
+		if cfg.PinResults {
+			b.pinResults(res.ChatID, sessionID, sent.MessageID)
+		}
+	}
+	// DMs go out only once the chat message is posted: a failed post releases the claim and
+	// the retry would message everyone again
+	if sendDMs {
+		unreachable := b.sendGroupDMs(res)
+		if len(unreachable) > 0 && cfg.ResultsVisibility == visibilityPrivate {
+			note := tgbotapi.NewMessage(res.ChatID, fmt.Sprintf(messages.ResultsUnreachable, strings.Join(unreachable, ", ")))
+			if _, err := b.sendRetrying(note); err != nil {
+				log.Printf("close: unreachable note failed chat=%d session=%d err=%v", res.ChatID, sessionID, err)
+			}
+		}
+	}
+	if !empty && cfg.ResultsVisibility != visibilityPrivate && cfg.Render.Summarized(res.Groups) {
+		b.sendRoster(res, cfg.Render)
+	}
+	if len(res.Groups) > 0 {
+		if err := b.Store.SavePublishedGroups(sessionID, res.Groups); err != nil {
+			log.Printf("close: save published groups failed session=%d err=%v", sessionID, err)
+		}
+	}
+	if pairOnly && cfg.PairOnlyPolicy == pairOnlyCarry {
+		if err := b.Store.CarryOver(res.ChatID, pair); err != nil {
+			log.Printf("close: carry over failed chat=%d session=%d err=%v", res.ChatID, sessionID, err)
+		}
+	}
+	_ = b.Store.CloseSession(sessionID)
+	metrics.SessionsClosed.Inc()
+	if err := b.Store.UpdateEmptyStreak(res.ChatID, empty); err != nil {
+		log.Printf("close: empty streak update failed chat=%d err=%v", res.ChatID, err)
+	}
+	b.removeInviteKeyboard(sessionID)
+	if cfg.PinInvite {
+		b.unpinInvite(sessionID)
+	}
+}
+
+// applySmallGroupPolicy handles groups below the chat's minimum size: kept as they are, merged
+// into others, or the whole round is cancelled. A merge that cannot reach the minimum cancels too.
+func applySmallGroupPolicy(groups []logic.Group, cfg ChatConfig) ([]logic.Group, bool) {
+	if cfg.MinGroupSize <= 0 || cfg.SmallGroupPolicy == smallGroupsPublish || !logic.HasSmallGroup(groups, cfg.MinGroupSize) {
+		return groups, false
+	}
+	if cfg.SmallGroupPolicy == smallGroupsMerge {
+		if merged, ok := logic.MergeSmallGroups(groups, cfg.MinGroupSize); ok {
+			return merged, false
+		}
+	}
+	return nil, true
+}
+
+// isLonePair reports whether the round came out as a single group of two.
+func isLonePair(groups []logic.Group) bool {
+	return len(groups) == 1 && len(groups[0].Members) == 2
+}
+
+// groupParticipants returns the participants who are members of the group.
+func groupParticipants(parts []db.Participant, g logic.Group) []db.Participant {
+	in := make(map[int64]bool, len(g.Members))
+	for _, u := range g.Members {
+		in[u.ID] = true
+	}
+	var res []db.Participant
+	for _, p := range parts {
+		if in[p.UserID] {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// emptyResultsText is the message for a round nobody joined. After EmptyStreakNudge
+// empty rounds in a row it suggests that admins reconsider the schedule.
+func (b *Bot) emptyResultsText(chatID int64, cfg ChatConfig) string {
+	if cfg.EmptyStreakNudge <= 0 {
+		return messages.NoParticipants
+	}
+	streak, err := b.Store.GetEmptyStreak(chatID)
+	if err != nil {
+		log.Printf("close: empty streak lookup failed chat=%d err=%v", chatID, err)
+		return messages.NoParticipants
+	}
+	// streak does not include the current round yet
+	if streak+1 >= cfg.EmptyStreakNudge {
+		return fmt.Sprintf(messages.EmptyStreakNudge, streak+1)
+	}
+	return messages.NoParticipants
+}
+
+// sendRoster attaches the full group list as a text file when the message only has a summary.
+func (b *Bot) sendRoster(res ResultsData, opts RenderOptions) {
+	roster := RenderGroups(res.Groups, opts)
+	doc := tgbotapi.NewDocument(res.ChatID, tgbotapi.FileBytes{Name: fmt.Sprintf("random-coffee-%s.txt", res.Date), Bytes: []byte(roster)})
+	if _, err := b.sendRetrying(doc); err != nil {
+		log.Printf("close: roster send failed chat=%d session=%d err=%v", res.ChatID, res.SessionID, err)
+	}
+}
+
+// sendGroupDMs sends every participant their group privately and returns names of those the bot
+// could not reach (a bot may only message users who have started it).
+func (b *Bot) sendGroupDMs(res ResultsData) []string {
+	var unreachable []string
+	for _, g := range res.Groups {
+		for _, u := range g.Members {
+			if db.IsFakeUserID(u.ID) {
+				continue
+			}
+			others := make([]string, 0, len(g.Members)-1)
+			for _, o := range g.Members {
+				if o.ID != u.ID {
+					others = append(others, o.Name)
+				}
+			}
+			dm := tgbotapi.NewMessage(u.ID, fmt.Sprintf(messages.DMGroupHeader, strings.Join(others, ", ")))
+			if _, err := b.sendRetrying(dm); err != nil {
+				log.Printf("close: dm failed session=%d user=%d err=%v", res.SessionID, u.ID, err)
+				unreachable = append(unreachable, u.Name)
+			}
+		}
+	}
+	return unreachable
+}
+
+// addTestFakes pads a test-mode session that has a single participant with fake ones.
+func (b *Bot) addTestFakes(sessionID int64) {
+	parts, err := b.Store.GetParticipants(sessionID)
+	if err != nil || len(parts) != 1 {
+		return
+	}
+	for i := 1; i <= 4; i++ {
+		_ = b.Store.AddParticipant(sessionID, db.FakeUserIDBase+int64(i), "", fmt.Sprintf("Тестовый участник %d", i))
+	}
+}
+
+// dropDepartedMembers removes participants who left or were kicked from the chat since joining.
+// Lookup failures keep the participant: a flaky API must not silently shrink the round.
+func (b *Bot) dropDepartedMembers(chatID, sessionID int64, parts []db.Participant) []db.Participant {
+	kept := parts[:0:0]
+	for _, p := range parts {
+		member, err := b.API.GetChatMember(tgbotapi.GetChatMemberConfig{ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: p.UserID}})
+		if err == nil && (member.HasLeft() || member.WasKicked()) {
+			log.Printf("close: drop departed participant chat=%d session=%d user=%d status=%s", chatID, sessionID, p.UserID, member.Status)
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
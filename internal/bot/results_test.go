@@ -0,0 +1,391 @@
+package bot
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newTestSessionWith creates an open session of today in chatID joined by users.
+func newTestSessionWith(t *testing.T, b *Bot, chatID int64, users ...int64) int64 {
+	t.Helper()
+	addTestChat(t, b, chatID)
+	now := time.Now().UTC()
+	id, err := b.Store.CreateOrGetTodaySession(chatID, now.Format("2006-01-02"), 0, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	for _, u := range users {
+		if err := b.Store.AddParticipant(id, u, fmt.Sprintf("user%d", u), fmt.Sprintf("User %d", u)); err != nil {
+			t.Fatalf("add participant: %v", err)
+		}
+	}
+	return id
+}
+
+// groupedIDs returns the sorted user IDs of every group member.
+func groupedIDs(res ResultsData) []int64 {
+	var ids []int64
+	for _, g := range res.Groups {
+		for _, m := range g.Members {
+			ids = append(ids, m.ID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestComputeResultsVerifyMembers(t *testing.T) {
+	const chatID = -100
+	// status of each participant in getChatMember; "" makes the lookup fail
+	statuses := map[string]string{"1": "member", "2": "left", "3": "kicked", "4": "", "5": "administrator"}
+	tests := []struct {
+		name   string
+		verify string
+		want   []int64
+	}{
+		{"verification off", "off", []int64{1, 2, 3, 4, 5}},
+		{"departed dropped, failed lookup kept", "on", []int64{1, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			fake.respond = func(method string, params map[string]string) *tgbotapi.APIResponse {
+				if method != "getChatMember" {
+					return nil
+				}
+				status := statuses[params["user_id"]]
+				if status == "" {
+					return apiErrorResponse(400, "Bad Request: user not found", 0)
+				}
+				return &tgbotapi.APIResponse{Ok: true, Result: []byte(`{"status":"` + status + `","user":{"id":` + params["user_id"] + `}}`)}
+			}
+			id := newTestSessionWith(t, b, chatID, 1, 2, 3, 4, 5)
+			setTestSetting(t, b, chatID, "verify_members", tt.verify)
+
+			res, err := b.ComputeResults(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := groupedIDs(res); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("grouped = %v, want %v", got, tt.want)
+			}
+			if len(res.Participants) != len(tt.want) {
+				t.Errorf("participants = %d, want %d", len(res.Participants), len(tt.want))
+			}
+			for _, g := range res.Groups {
+				if len(g.Members) < 2 {
+					t.Errorf("group of %d in %v", len(g.Members), res.Groups)
+				}
+			}
+			if calls := len(fake.sent("getChatMember")); (tt.verify == "on") != (calls > 0) {
+				t.Errorf("getChatMember calls = %d with verify_members %s", calls, tt.verify)
+			}
+		})
+	}
+}
+
+func TestComputeResultsSeeded(t *testing.T) {
+	const chatID = -100
+	members := func(res ResultsData) string {
+		var ids [][]int64
+		for _, g := range res.Groups {
+			var row []int64
+			for _, m := range g.Members {
+				row = append(row, m.ID)
+			}
+			ids = append(ids, row)
+		}
+		return fmt.Sprint(ids)
+	}
+	// both the plain shuffle and the one avoiding recent pairs draw from the seed
+	for _, avoid := range []string{"0", "28"} {
+		t.Run("avoid_repeats "+avoid, func(t *testing.T) {
+			b, _ := newTestBot(t)
+			id := newTestSessionWith(t, b, chatID, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+			setTestSetting(t, b, chatID, "avoid_repeats", avoid)
+			group := func(seed int64) string {
+				t.Helper()
+				b.groupSeed = func() int64 { return seed }
+				res, err := b.ComputeResults(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return members(res)
+			}
+			first := group(1)
+			if again := group(1); again != first {
+				t.Errorf("same seed, different groups:\n%s\n%s", first, again)
+			}
+			if other := group(2); other == first {
+				t.Errorf("seeds 1 and 2 gave the same groups %s", first)
+			}
+		})
+	}
+}
+
+func TestComputeResults(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		name         string
+		users        []int64
+		facilitators []int64
+		wantSizes    []int
+		wantFac      []int64
+	}{
+		{name: "empty", wantSizes: nil},
+		{name: "pair", users: []int64{1, 2}, wantSizes: []int{2}},
+		{name: "four", users: []int64{1, 2, 3, 4}, wantSizes: []int{2, 2}},
+		{name: "seven", users: []int64{1, 2, 3, 4, 5, 6, 7}, wantSizes: []int{3, 2, 2}},
+		{name: "facilitator set aside", users: []int64{1, 2, 3, 4}, facilitators: []int64{4}, wantSizes: []int{3}, wantFac: []int64{4}},
+		{name: "facilitator joins a lone member", users: []int64{1, 2}, facilitators: []int64{2}, wantSizes: []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBot(t)
+			id := newTestSessionWith(t, b, chatID, tt.users...)
+			for _, u := range tt.facilitators {
+				if err := b.Store.SetFacilitator(chatID, u, true); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			res, err := b.ComputeResults(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.SessionID != id || res.ChatID != chatID {
+				t.Errorf("session/chat = %d/%d, want %d/%d", res.SessionID, res.ChatID, id, chatID)
+			}
+			if len(res.Participants) != len(tt.users) {
+				t.Errorf("participants = %d, want %d", len(res.Participants), len(tt.users))
+			}
+			var sizes []int
+			names := map[int64]string{}
+			for _, g := range res.Groups {
+				sizes = append(sizes, len(g.Members))
+				for _, m := range g.Members {
+					if _, dup := names[m.ID]; dup {
+						t.Errorf("user %d grouped twice", m.ID)
+					}
+					names[m.ID] = m.Name
+				}
+			}
+			if fmt.Sprint(sizes) != fmt.Sprint(tt.wantSizes) {
+				t.Errorf("group sizes = %v, want %v", sizes, tt.wantSizes)
+			}
+			for id, name := range names {
+				if want := fmt.Sprintf("User %d", id); name != want {
+					t.Errorf("name of %d = %q, want %q", id, name, want)
+				}
+			}
+			var fac []int64
+			for _, p := range res.Facilitators {
+				fac = append(fac, p.UserID)
+			}
+			if fmt.Sprint(fac) != fmt.Sprint(tt.wantFac) {
+				t.Errorf("facilitators = %v, want %v", fac, tt.wantFac)
+			}
+		})
+	}
+}
+
+func TestEmptyStreak(t *testing.T) {
+	const chatID = -100
+	nudge := func(n int) string { return fmt.Sprintf(messages.EmptyStreakNudge, n) }
+	tests := []struct {
+		name string
+		// rounds lists the participants of each round, one day after another; nil is a skipped day
+		rounds     [][]int64
+		wantStreak int
+		wantText   string
+	}{
+		{"first empty", [][]int64{{}}, 1, messages.NoParticipants},
+		{"below the threshold", [][]int64{{}, {}}, 2, messages.NoParticipants},
+		{"consecutive empties", [][]int64{{}, {}, {}}, 3, nudge(3)},
+		{"past the threshold", [][]int64{{}, {}, {}, {}}, 4, nudge(4)},
+		// a day without a round neither breaks nor extends the streak
+		{"day without a round", [][]int64{{}, {}, nil, {}}, 3, nudge(3)},
+		{"round with people resets", [][]int64{{}, {}, {1, 2}, {}}, 1, messages.NoParticipants},
+		{"ends with people", [][]int64{{}, {}, {}, {1, 2}}, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			addTestChat(t, b, chatID)
+			day := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+			for i, users := range tt.rounds {
+				if users == nil {
+					continue
+				}
+				id, err := b.Store.CreateOrGetTodaySession(chatID, day.AddDate(0, 0, i).Format("2006-01-02"), 0, day)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, u := range users {
+					if err := b.Store.AddParticipant(id, u, "", fmt.Sprintf("User %d", u)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				b.CloseAndPublish(id)
+			}
+			streak, err := b.Store.GetEmptyStreak(chatID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if streak != tt.wantStreak {
+				t.Errorf("streak = %d, want %d", streak, tt.wantStreak)
+			}
+			if tt.wantText != "" {
+				if got := lastReply(t, fake); got != tt.wantText {
+					t.Errorf("last message = %q, want %q", got, tt.wantText)
+				}
+			}
+		})
+	}
+}
+
+func TestSmallGroupPolicy(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		users  int
+		policy string
+		// want lists the sorted group sizes; nil means the round is cancelled
+		want []int
+	}{
+		{5, smallGroupsPublish, []int{2, 3}},
+		{5, smallGroupsMerge, []int{5}},
+		{5, smallGroupsCancel, nil},
+		{4, smallGroupsPublish, []int{2, 2}},
+		{4, smallGroupsMerge, []int{4}},
+		{4, smallGroupsCancel, nil},
+		// three make a single group of three, which is big enough
+		{3, smallGroupsCancel, []int{3}},
+		{2, smallGroupsPublish, []int{2}},
+		{2, smallGroupsMerge, nil},
+		{2, smallGroupsCancel, nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d %s", tt.users, tt.policy), func(t *testing.T) {
+			b, _ := newTestBot(t)
+			var users []int64
+			for i := 1; i <= tt.users; i++ {
+				users = append(users, int64(i))
+			}
+			id := newTestSessionWith(t, b, chatID, users...)
+			setTestSetting(t, b, chatID, "min_group", "3")
+			setTestSetting(t, b, chatID, "small_groups", tt.policy)
+			cfg, _ := b.EffectiveSettings(chatID)
+
+			res, err := b.ComputeResults(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			groups, cancelled := applySmallGroupPolicy(res.Groups, cfg)
+			if cancelled != (tt.want == nil) {
+				t.Fatalf("cancelled = %v, want %v", cancelled, tt.want == nil)
+			}
+			var sizes []int
+			for _, g := range groups {
+				sizes = append(sizes, len(g.Members))
+			}
+			sort.Ints(sizes)
+			if !cancelled && fmt.Sprint(sizes) != fmt.Sprint(tt.want) {
+				t.Errorf("group sizes = %v, want %v", sizes, tt.want)
+			}
+		})
+	}
+}
+
+func TestSmallGroupsCancelledMessage(t *testing.T) {
+	const chatID = -100
+	b, fake := newTestBot(t)
+	id := newTestSessionWith(t, b, chatID, 1, 2, 3, 4, 5)
+	setTestSetting(t, b, chatID, "min_group", "3")
+	setTestSetting(t, b, chatID, "small_groups", smallGroupsCancel)
+
+	b.CloseAndPublish(id)
+	if got, want := lastReply(t, fake), fmt.Sprintf(messages.SmallGroupsCancelled, 3); got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+	if groups, err := b.Store.GetSessionGroups(id); err != nil || len(groups) != 0 {
+		t.Errorf("published groups = %v, %v; want none", groups, err)
+	}
+}
+
+func TestPairOnlyPolicy(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		policy    string
+		users     []int64
+		want      string // "" for published results
+		published int
+		carried   []int64
+	}{
+		{pairOnlyPublish, []int64{1, 2}, "", 2, nil},
+		{pairOnlyCancel, []int64{1, 2}, messages.PairOnlyCancelled, 0, nil},
+		{pairOnlyCarry, []int64{1, 2}, messages.PairOnlyCarried, 0, []int64{1, 2}},
+		// a round of three is not a lone pair
+		{pairOnlyCancel, []int64{1, 2, 3}, "", 3, nil},
+		{pairOnlyCarry, []int64{1, 2, 3}, "", 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d", tt.policy, len(tt.users)), func(t *testing.T) {
+			b, fake := newTestBot(t)
+			id := newTestSessionWith(t, b, chatID, tt.users...)
+			setTestSetting(t, b, chatID, "pair_only", tt.policy)
+
+			b.CloseAndPublish(id)
+			got := lastReply(t, fake)
+			if tt.want != "" && got != tt.want {
+				t.Errorf("message = %q, want %q", got, tt.want)
+			}
+			if tt.want == "" && !strings.Contains(got, "User 1") {
+				t.Errorf("message = %q, want published results", got)
+			}
+			groups, err := b.Store.GetSessionGroups(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := 0
+			for _, g := range groups {
+				n += len(g)
+			}
+			if n != tt.published {
+				t.Errorf("published members = %d, want %d", n, tt.published)
+			}
+
+			// the next session starts with the carried pair signed up
+			if _, err := b.Store.DB.Exec("UPDATE daily_sessions SET session_date=? WHERE id=?", time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02"), id); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.sendInviteToChat(chatID); got != inviteSent {
+				t.Fatalf("next invite outcome = %d, want %d", got, inviteSent)
+			}
+			date, slot := b.chatSession(chatID)
+			next, err := b.Store.CreateOrGetTodaySession(chatID, date, slot, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts, err := b.Store.GetParticipants(next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int64
+			for _, p := range parts {
+				ids = append(ids, p.UserID)
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			if !reflect.DeepEqual(ids, tt.carried) {
+				t.Errorf("next session participants = %v, want %v", ids, tt.carried)
+			}
+		})
+	}
+}
@@ -0,0 +1,549 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"coffeetrix24/internal/logic"
+	"coffeetrix24/internal/messages"
+	"coffeetrix24/internal/scheduler"
+)
+
+const (
+	visibilityPublic  = "public"
+	visibilityPrivate = "private"
+	visibilityBoth    = "both"
+
+	// what to do with groups smaller than MinGroupSize
+	smallGroupsPublish = "publish"
+	smallGroupsMerge   = "merge"
+	smallGroupsCancel  = "cancel"
+
+	// what to do when only two people joined and the round is a single pair
+	pairOnlyPublish = "publish"
+	pairOnlyCancel  = "cancel"
+	pairOnlyCarry   = "carry"
+)
+
+const (
+	defaultSignupWindow = 30 * time.Minute
+	defaultLapsedAfter  = 5
+	// defaultEmptyStreakNudge is the number of empty rounds in a row before suggesting a pause.
+	defaultEmptyStreakNudge = 3
+	// defaultExtendBy is how much a participant-requested extension adds to the deadline.
+	defaultExtendBy = 15 * time.Minute
+	// defaultAutoExtendWindow is how long before the deadline joins count towards an auto-extension.
+	defaultAutoExtendWindow = 5 * time.Minute
+	// defaultAvoidRepeatDays is how far back grouping looks for people who already met.
+	defaultAvoidRepeatDays = 28
+)
+
+// ChatConfig is the effective configuration of a chat: global defaults with per-chat overrides applied.
+type ChatConfig struct {
+	// DailyTime is the invite time, or several comma-separated ones, each opening its own session slot.
+	DailyTime string
+	// Location is the chat's timezone: DailyTime and session dates are local to it (UTC by default).
+	Location       *time.Location
+	InviteDays     weekdayMask
+	SignupWindow   time.Duration
+	InviteCooldown time.Duration
+	VerifyMembers  bool
+	InviteMedia    string
+	// InviteEmoji decorates the join button and acknowledgement ("" for none).
+	InviteEmoji    string
+	PinInvite      bool
+	PinResults     bool
+	GracePeriod    time.Duration
+	LapsedMentions int
+	LapsedAfter    int
+	// NoShowThreshold excludes members meeting less than this percent of the time from mentions (0 disables).
+	NoShowThreshold int
+	Render          RenderOptions
+	// ResultsVisibility is one of visibilityPublic, visibilityPrivate, visibilityBoth.
+	ResultsVisibility string
+	EmptyStreakNudge  int
+	// ExtendVotes enables the extend button: that many participants asking extend signups once by ExtendBy.
+	ExtendVotes int
+	ExtendBy    time.Duration
+	// RemindBefore posts a reminder this long before the signup deadline (0 disables).
+	RemindBefore time.Duration
+	// AutoExtendJoins extends signups once by ExtendBy when that many people joined within
+	// AutoExtendWindow before the deadline (0 disables).
+	AutoExtendJoins  int
+	AutoExtendWindow time.Duration
+	// AllowLeave lets participants sign off with /leave; LeaveAck is the reply when they do.
+	AllowLeave bool
+	LeaveAck   string
+	// MinGroupSize and SmallGroupPolicy control publishing of groups that came out too small.
+	MinGroupSize     int
+	SmallGroupPolicy string
+	// PairOnlyPolicy is one of pairOnlyPublish, pairOnlyCancel, pairOnlyCarry.
+	PairOnlyPolicy string
+	// TeamTags annotates members in results with their team, where known.
+	TeamTags bool
+	// LateNote explains an empty round to the chat when several people tapped join too late.
+	LateNote bool
+	Grouping logic.GroupConfig
+	// AvoidRepeatDays keeps apart people grouped together within this many days (0 disables).
+	AvoidRepeatDays int
+	// Overridden lists settings set for this chat rather than inherited from global defaults.
+	Overridden map[string]bool
+}
+
+// EffectiveSettings resolves the configuration used for a chat.
+// On error the returned config still carries the global defaults.
+func (b *Bot) EffectiveSettings(chatID int64) (ChatConfig, error) {
+	cfg := ChatConfig{
+		Location:          time.UTC,
+		SignupWindow:      b.SignupWindow,
+		InviteCooldown:    b.InviteCooldown,
+		LapsedAfter:       defaultLapsedAfter,
+		ResultsVisibility: visibilityPublic,
+		EmptyStreakNudge:  defaultEmptyStreakNudge,
+		ExtendBy:          defaultExtendBy,
+		AutoExtendWindow:  defaultAutoExtendWindow,
+		AllowLeave:        true,
+		LeaveAck:          messages.LeaveDone,
+		SmallGroupPolicy:  smallGroupsPublish,
+		PairOnlyPolicy:    pairOnlyPublish,
+		AvoidRepeatDays:   defaultAvoidRepeatDays,
+		Overridden:        map[string]bool{},
+	}
+	if cfg.SignupWindow == 0 {
+		cfg.SignupWindow = defaultSignupWindow
+	}
+	daily, err := b.Store.GetDailyTime()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.DailyTime = daily
+	cs, err := b.Store.GetChatSettings(chatID)
+	if err != nil {
+		return cfg, err
+	}
+	if cs.Timezone != nil {
+		// validated when set; a zone missing from this build's tz database falls back to UTC
+		if loc, err := time.LoadLocation(*cs.Timezone); err == nil {
+			cfg.Location = loc
+			cfg.Overridden["timezone"] = true
+		}
+	}
+	if cs.InviteDays != nil {
+		cfg.InviteDays = weekdayMask(*cs.InviteDays)
+		cfg.Overridden["days"] = true
+	}
+	if cs.SignupWindow != nil {
+		cfg.SignupWindow = *cs.SignupWindow
+		cfg.Overridden["window"] = true
+	}
+	if cs.VerifyMembers != nil {
+		cfg.VerifyMembers = *cs.VerifyMembers
+		cfg.Overridden["verify_members"] = true
+	}
+	if cs.InviteEmoji != nil {
+		cfg.InviteEmoji = *cs.InviteEmoji
+		cfg.Overridden["emoji"] = true
+	}
+	if cs.PinInvite != nil {
+		cfg.PinInvite = *cs.PinInvite
+		cfg.Overridden["pin_invite"] = true
+	}
+	if cs.PinResults != nil {
+		cfg.PinResults = *cs.PinResults
+		cfg.Overridden["pin_results"] = true
+	}
+	if cs.InviteMedia != nil {
+		cfg.InviteMedia = *cs.InviteMedia
+		cfg.Overridden["invite_media"] = true
+	}
+	if cs.GracePeriod != nil {
+		cfg.GracePeriod = *cs.GracePeriod
+		cfg.Overridden["grace"] = true
+	}
+	if cs.InviteCooldown != nil {
+		cfg.InviteCooldown = *cs.InviteCooldown
+		cfg.Overridden["cooldown"] = true
+	}
+	if cs.LapsedMentions != nil {
+		cfg.LapsedMentions = *cs.LapsedMentions
+		cfg.Overridden["lapsed_mentions"] = true
+	}
+	if cs.NoShowThreshold != nil {
+		cfg.NoShowThreshold = *cs.NoShowThreshold
+		cfg.Overridden["noshow"] = true
+	}
+	if cs.LapsedAfter != nil {
+		cfg.LapsedAfter = *cs.LapsedAfter
+		cfg.Overridden["lapsed_after"] = true
+	}
+	if cs.LabelSingleGroup != nil {
+		cfg.Render.LabelSingleGroup = *cs.LabelSingleGroup
+		cfg.Overridden["label_single"] = true
+	}
+	if cs.ResultsVisibility != nil {
+		cfg.ResultsVisibility = *cs.ResultsVisibility
+		cfg.Overridden["results"] = true
+	}
+	if cs.GroupLabels != nil {
+		cfg.Render.LabelStyle, cfg.Render.Emoji = splitLabelSetting(*cs.GroupLabels)
+		cfg.Overridden["labels"] = true
+	}
+	if cs.SummaryThreshold != nil {
+		cfg.Render.SummaryThreshold = *cs.SummaryThreshold
+		cfg.Overridden["summary_over"] = true
+	}
+	if cs.MaxGroupSize != nil {
+		cfg.Grouping.MaxSize = *cs.MaxGroupSize
+		cfg.Overridden["max_group"] = true
+	}
+	if cs.GroupSize != nil {
+		cfg.Grouping.TargetSize = *cs.GroupSize
+		cfg.Overridden["group_size"] = true
+	}
+	if cs.BalanceGroups != nil {
+		cfg.Grouping.Balanced = *cs.BalanceGroups
+		cfg.Overridden["balance"] = true
+	}
+	if cs.AvoidRepeatDays != nil {
+		cfg.AvoidRepeatDays = *cs.AvoidRepeatDays
+		cfg.Overridden["avoid_repeats"] = true
+	}
+	if cs.MinGroupSize != nil {
+		cfg.MinGroupSize = *cs.MinGroupSize
+		cfg.Overridden["min_group"] = true
+	}
+	if cs.SmallGroupPolicy != nil {
+		cfg.SmallGroupPolicy = *cs.SmallGroupPolicy
+		cfg.Overridden["small_groups"] = true
+	}
+	if cs.PairOnlyPolicy != nil {
+		cfg.PairOnlyPolicy = *cs.PairOnlyPolicy
+		cfg.Overridden["pair_only"] = true
+	}
+	if cs.TeamTags != nil {
+		cfg.TeamTags = *cs.TeamTags
+		cfg.Overridden["team_tags"] = true
+	}
+	if cs.LateNote != nil {
+		cfg.LateNote = *cs.LateNote
+		cfg.Overridden["late_note"] = true
+	}
+	if cs.ExtendVotes != nil {
+		cfg.ExtendVotes = *cs.ExtendVotes
+		cfg.Overridden["extend_votes"] = true
+	}
+	if cs.ExtendBy != nil {
+		cfg.ExtendBy = *cs.ExtendBy
+		cfg.Overridden["extend_by"] = true
+	}
+	if cs.RemindBefore != nil {
+		cfg.RemindBefore = *cs.RemindBefore
+		cfg.Overridden["remind_before"] = true
+	}
+	if cs.AutoExtendJoins != nil {
+		cfg.AutoExtendJoins = *cs.AutoExtendJoins
+		cfg.Overridden["auto_extend"] = true
+	}
+	if cs.AutoExtendWindow != nil {
+		cfg.AutoExtendWindow = *cs.AutoExtendWindow
+		cfg.Overridden["auto_extend_window"] = true
+	}
+	if cs.AllowLeave != nil {
+		cfg.AllowLeave = *cs.AllowLeave
+		cfg.Overridden["allow_leave"] = true
+	}
+	if cs.LeaveAck != nil {
+		cfg.LeaveAck = *cs.LeaveAck
+		cfg.Overridden["leave_ack"] = true
+	}
+	if cs.EmptyStreakNudge != nil {
+		cfg.EmptyStreakNudge = *cs.EmptyStreakNudge
+		cfg.Overridden["empty_nudge"] = true
+	}
+	return cfg, nil
+}
+
+// settingDef describes a per-chat setting changeable with /set <key> <value>.
+type settingDef struct {
+	column string
+	// parse converts user input into the value stored in chat_settings.
+	parse func(string) (interface{}, error)
+}
+
+// ParseSettingsTemplate parses "key=value;key=value" with /set keys and values into
+// chat_settings column values. Entries are separated by ";" since label lists contain commas.
+func ParseSettingsTemplate(spec string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, raw, ok := strings.Cut(entry, "=")
+		def, known := settingDefs[strings.TrimSpace(key)]
+		if !ok || !known {
+			return nil, fmt.Errorf("unknown setting in %q", entry)
+		}
+		v, err := def.parse(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+		values[def.column] = v
+	}
+	lo, _ := values["min_group_size"].(int)
+	hi, _ := values["max_group_size"].(int)
+	if lo > 0 && hi > 0 && lo > hi {
+		return nil, fmt.Errorf("min_group %d exceeds max_group %d", lo, hi)
+	}
+	return values, nil
+}
+
+// groupBounds returns the min and max group sizes the chat would have after storing value
+// under key (nil resets to the default); ok is false when min would exceed max. Zero means unset.
+func groupBounds(cfg ChatConfig, key string, value interface{}) (lo, hi int, ok bool) {
+	lo, hi = cfg.MinGroupSize, cfg.Grouping.MaxSize
+	n, _ := value.(int)
+	switch key {
+	case "min_group":
+		lo = n
+	case "max_group":
+		hi = n
+	}
+	return lo, hi, lo == 0 || hi == 0 || lo <= hi
+}
+
+var settingDefs = map[string]settingDef{
+	"window":         {column: "signup_window_sec", parse: parseWindow},
+	"verify_members": {column: "verify_members", parse: parseBool},
+	"grace":          {column: "grace_period_sec", parse: parseGrace},
+	"cooldown":       {column: "invite_cooldown_sec", parse: parseCooldown},
+
+	"lapsed_mentions": {column: "lapsed_mention_limit", parse: intRange(0, 20)},
+	"lapsed_after":    {column: "lapsed_after_sessions", parse: intRange(1, 100)},
+	"noshow":          {column: "noshow_threshold", parse: intRange(0, 100)},
+	"label_single":    {column: "label_single_group", parse: parseBool},
+	"results":         {column: "results_visibility", parse: oneOf(visibilityPublic, visibilityPrivate, visibilityBoth)},
+	"labels":          {column: "group_labels", parse: parseLabels},
+	"empty_nudge":     {column: "empty_streak_nudge", parse: intRange(0, 100)},
+	"pin_invite":      {column: "pin_invite", parse: parseBool},
+	"pin_results":     {column: "pin_results", parse: parseBool},
+	"emoji":           {column: "invite_emoji", parse: parseEmoji},
+	"extend_votes":    {column: "extend_votes", parse: intRange(0, 50)},
+	"extend_by":       {column: "extend_by_sec", parse: parseGrace},
+	"max_group":       {column: "max_group_size", parse: intRange(2, 20)},
+	"group_size":      {column: "group_size", parse: intRange(2, 20)},
+	"balance":         {column: "balance_groups", parse: parseBool},
+	"min_group":       {column: "min_group_size", parse: intRange(0, 10)},
+	"small_groups":    {column: "small_group_policy", parse: oneOf(smallGroupsPublish, smallGroupsMerge, smallGroupsCancel)},
+	"pair_only":       {column: "pair_only_policy", parse: oneOf(pairOnlyPublish, pairOnlyCancel, pairOnlyCarry)},
+	"team_tags":       {column: "team_tags", parse: parseBool},
+	"late_note":       {column: "late_note", parse: parseBool},
+	"allow_leave":     {column: "allow_leave", parse: parseBool},
+	"summary_over":    {column: "results_summary_threshold", parse: intRange(0, 100000)},
+
+	"auto_extend":        {column: "auto_extend_joins", parse: intRange(0, 100)},
+	"auto_extend_window": {column: "auto_extend_window_sec", parse: parseGrace},
+	"avoid_repeats":      {column: "avoid_repeat_days", parse: intRange(0, 365)},
+	"remind_before":      {column: "remind_before_sec", parse: parseGrace},
+	"timezone":           {column: "timezone", parse: parseTimezone},
+	"days":               {column: "invite_days", parse: parseWeekdays},
+}
+
+var (
+	errBadValue = errors.New("bad value")
+	errBadEmoji = errors.New("not a single emoji")
+)
+
+func parseBool(v string) (interface{}, error) {
+	switch strings.ToLower(v) {
+	case "on", "yes", "true", "1", "да", "вкл":
+		return true, nil
+	case "off", "no", "false", "0", "нет", "выкл":
+		return false, nil
+	}
+	return nil, errBadValue
+}
+
+func parseWindow(v string) (interface{}, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil || d < time.Minute || d > 24*time.Hour {
+		return nil, errBadValue
+	}
+	return int64(d / time.Second), nil
+}
+
+func parseGrace(v string) (interface{}, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 || d > 2*time.Hour {
+		return nil, errBadValue
+	}
+	return int64(d / time.Second), nil
+}
+
+// parseCooldown accepts 0 (no cooldown) up to a day.
+func parseCooldown(v string) (interface{}, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 || d > 24*time.Hour {
+		return nil, errBadValue
+	}
+	return int64(d / time.Second), nil
+}
+
+// parseTimezone accepts an IANA zone name such as Europe/Moscow.
+func parseTimezone(v string) (interface{}, error) {
+	if v == "" || strings.EqualFold(v, "local") {
+		return nil, errBadValue
+	}
+	loc, err := time.LoadLocation(v)
+	if err != nil {
+		return nil, errBadValue
+	}
+	return loc.String(), nil
+}
+
+// intRange parses an integer within [lo, hi].
+func intRange(lo, hi int) func(string) (interface{}, error) {
+	return func(v string) (interface{}, error) {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < lo || n > hi {
+			return nil, errBadValue
+		}
+		return n, nil
+	}
+}
+
+// parseLabels accepts "numeric", "alpha" or "emoji:<e1>,<e2>,..." with at least two emoji.
+func parseLabels(v string) (interface{}, error) {
+	style, emoji := splitLabelSetting(v)
+	switch style {
+	case labelsNumeric, labelsAlpha:
+		return style, nil
+	case labelsEmoji:
+		if len(emoji) < 2 {
+			return nil, errBadValue
+		}
+		return labelsEmoji + ":" + strings.Join(emoji, ","), nil
+	}
+	return nil, errBadValue
+}
+
+func splitLabelSetting(v string) (style string, emoji []string) {
+	style, list, _ := strings.Cut(v, ":")
+	for _, e := range strings.Split(list, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			emoji = append(emoji, e)
+		}
+	}
+	return strings.ToLower(style), emoji
+}
+
+// parseEmoji accepts exactly one emoji.
+func parseEmoji(v string) (interface{}, error) {
+	if !logic.IsSingleEmoji(v) {
+		return nil, errBadEmoji
+	}
+	return v, nil
+}
+
+// oneOf accepts only the listed values.
+func oneOf(values ...string) func(string) (interface{}, error) {
+	return func(v string) (interface{}, error) {
+		v = strings.ToLower(v)
+		for _, ok := range values {
+			if v == ok {
+				return v, nil
+			}
+		}
+		return nil, errBadValue
+	}
+}
+
+// fmtDuration prints a duration without zero components ("30m" instead of "30m0s").
+func fmtDuration(d time.Duration) string {
+	s := d.String()
+	if len(s) > 2 && s[len(s)-2:] == "0s" && d >= time.Minute {
+		s = s[:len(s)-2]
+	}
+	if len(s) > 2 && s[len(s)-2:] == "0m" && d >= time.Hour {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// zoneName names the chat's timezone for messages: "UTC" or an IANA name.
+func zoneName(cfg ChatConfig) string {
+	if cfg.Location == nil {
+		return time.UTC.String()
+	}
+	return cfg.Location.String()
+}
+
+// fmtClock prints t as wall time in the chat's timezone, e.g. "09:30 Europe/Moscow".
+func fmtClock(cfg ChatConfig, t time.Time) string {
+	loc := cfg.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format("15:04") + " " + zoneName(cfg)
+}
+
+// chatDate is the session date key of t in the chat's timezone.
+func chatDate(cfg ChatConfig, t time.Time) string {
+	loc := cfg.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format("2006-01-02")
+}
+
+// chatSlot is the session slot of t in the chat's timezone: which of the daily times it follows.
+func chatSlot(cfg ChatConfig, t time.Time) int {
+	loc := cfg.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return scheduler.SlotAt(cfg.DailyTime, t, loc)
+}
+
+// fmtGroupSize describes the grouping settings, e.g. "2–3" or "4 (поровну), не меньше 3".
+func fmtGroupSize(cfg ChatConfig) string {
+	g := cfg.Grouping
+	s := messages.ConfigGroupDefault
+	switch {
+	case g.TargetSize > 0:
+		s = strconv.Itoa(g.TargetSize)
+	case g.Balanced:
+		s = strconv.Itoa(logic.BalancedTarget)
+	}
+	if g.Balanced {
+		s += messages.ConfigGroupBalanced
+	}
+	if cfg.MinGroupSize > 0 {
+		s += fmt.Sprintf(messages.ConfigGroupMin, cfg.MinGroupSize)
+	}
+	if g.MaxSize > 0 {
+		s += fmt.Sprintf(messages.ConfigGroupMax, g.MaxSize)
+	}
+	return s
+}
+
+// fmtLabels shows the group label style the way /set labels takes it.
+func fmtLabels(o RenderOptions) string {
+	switch o.LabelStyle {
+	case labelsAlpha:
+		return labelsAlpha
+	case labelsEmoji:
+		return labelsEmoji + ":" + strings.Join(o.Emoji, ",")
+	}
+	return labelsNumeric
+}
+
+func fmtBool(v bool) string {
+	if v {
+		return "вкл"
+	}
+	return "выкл"
+}
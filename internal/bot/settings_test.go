@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"coffeetrix24/internal/logic"
+	"coffeetrix24/internal/messages"
+)
+
+func TestSetGroupBounds(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		name    string
+		current map[string]string
+		set     string
+		want    string
+	}{
+		{"min below max", map[string]string{"max_group": "4"}, "min_group 3", messages.SetDone},
+		{"min equal to max", map[string]string{"max_group": "4"}, "min_group 4", messages.SetDone},
+		{"min above max", map[string]string{"max_group": "4"}, "min_group 5", fmt.Sprintf(messages.SetGroupBounds, 5, 4)},
+		{"max below min", map[string]string{"min_group": "3"}, "max_group 2", fmt.Sprintf(messages.SetGroupBounds, 3, 2)},
+		{"min without max", nil, "min_group 5", messages.SetDone},
+		{"max reset to no cap", map[string]string{"min_group": "3", "max_group": "3"}, "max_group default", messages.SetDone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			fake.respond = asAdmin
+			addTestChat(t, b, chatID)
+			for key, raw := range tt.current {
+				setTestSetting(t, b, chatID, key, raw)
+			}
+
+			b.cmdSet(testCommand(chatID, 42, "/set "+tt.set))
+			if got := lastReply(t, fake); got != tt.want {
+				t.Errorf("reply = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSettingsTemplate(t *testing.T) {
+	got, err := ParseSettingsTemplate(" window=45m; days=weekdays;labels=emoji:☕,🍩 ;max_group=4;; pin_invite=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"signup_window_sec": int64(45 * 60),
+		"invite_days":       int(workingWeek),
+		"group_labels":      "emoji:☕,🍩",
+		"max_group_size":    4,
+		"pin_invite":        true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("template = %#v, want %#v", got, want)
+	}
+
+	for _, spec := range []string{"window", "colour=red", "window=forever", "days=someday;window=45m"} {
+		if _, err := ParseSettingsTemplate(spec); err == nil {
+			t.Errorf("ParseSettingsTemplate(%q): no error", spec)
+		}
+	}
+	if got, err := ParseSettingsTemplate(""); err != nil || len(got) != 0 {
+		t.Errorf("empty template = %v, %v; want no values", got, err)
+	}
+}
+
+func TestParseSettingsTemplateGroupBounds(t *testing.T) {
+	tests := []struct {
+		spec string
+		ok   bool
+	}{
+		{"min_group=3;max_group=4", true},
+		{"min_group=4;max_group=3", false},
+		{"min_group=0;max_group=2", true},
+		{"min_group=5", true},
+	}
+	for _, tt := range tests {
+		if _, err := ParseSettingsTemplate(tt.spec); (err == nil) != tt.ok {
+			t.Errorf("ParseSettingsTemplate(%q) err = %v, want ok %v", tt.spec, err, tt.ok)
+		}
+	}
+}
+
+func TestFmtGroupSize(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ChatConfig
+		want string
+	}{
+		{"default", ChatConfig{}, "2–3"},
+		{"target size", ChatConfig{Grouping: logic.GroupConfig{TargetSize: 4}}, "4"},
+		{"balanced default target", ChatConfig{Grouping: logic.GroupConfig{Balanced: true}}, "3 (поровну)"},
+		{"bounds", ChatConfig{MinGroupSize: 3, Grouping: logic.GroupConfig{TargetSize: 4, MaxSize: 6}}, "4, не меньше 3, не больше 6"},
+	}
+	for _, tt := range tests {
+		if got := fmtGroupSize(tt.cfg); got != tt.want {
+			t.Errorf("%s: fmtGroupSize = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFmtLabels(t *testing.T) {
+	tests := []struct {
+		opts RenderOptions
+		want string
+	}{
+		{RenderOptions{}, "numeric"},
+		{RenderOptions{LabelStyle: labelsAlpha}, "alpha"},
+		{RenderOptions{LabelStyle: labelsEmoji, Emoji: []string{"🍩", "☕"}}, "emoji:🍩,☕"},
+	}
+	for _, tt := range tests {
+		if got := fmtLabels(tt.opts); got != tt.want {
+			t.Errorf("fmtLabels(%+v) = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"coffeetrix24/internal/db"
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// setupStep is one screen of the onboarding wizard; the answer is stored like /set <key> <value>.
+type setupStep struct {
+	key     string
+	prompt  string
+	options [][2]string // label, value
+}
+
+var setupSteps = []setupStep{
+	{key: "window", prompt: messages.SetupWindowPrompt, options: [][2]string{
+		{"15 мин", "15m"}, {"30 мин", "30m"}, {"45 мин", "45m"}, {"1 час", "1h"},
+	}},
+	{key: "results", prompt: messages.SetupResultsPrompt, options: [][2]string{
+		{messages.SetupResultsPublic, visibilityPublic}, {messages.SetupResultsPrivate, visibilityPrivate}, {messages.SetupResultsBoth, visibilityBoth},
+	}},
+}
+
+func setupStartKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(messages.SetupButton, "setup:start"),
+	))
+}
+
+// onSetupCallback drives the wizard. Callback data is "setup:start" or "setup:<step>:<value>",
+// so the wizard position travels with the button and nothing is kept in memory.
+func (b *Bot) onSetupCallback(cb *tgbotapi.CallbackQuery) {
+	if cb.Message == nil {
+		return
+	}
+	chat := cb.Message.Chat
+	if admin, err := b.isAdmin(chat.ID, cb.From.ID); !admin {
+		text := messages.AdminOnly
+		if err != nil {
+			log.Printf("admin: get chat member failed chat=%d user=%d err=%v", chat.ID, cb.From.ID, err)
+			text = messages.AdminCheckFailed
+		}
+		_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, text))
+		return
+	}
+	next := 0
+	if rest := strings.TrimPrefix(cb.Data, "setup:"); rest != "start" {
+		idxStr, value, _ := strings.Cut(rest, ":")
+		idx, err := strconv.Atoi(idxStr)
+		if err != nil || idx < 0 || idx >= len(setupSteps) {
+			_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, ""))
+			return
+		}
+		step := setupSteps[idx]
+		def := settingDefs[step.key]
+		var patch db.ChatSettingsPatch
+		v, err := def.parse(value)
+		if err == nil {
+			err = patch.SetColumn(def.column, v)
+		}
+		if err == nil {
+			err = b.Store.UpdateChatSettings(chat.ID, patch)
+		}
+		if err != nil {
+			log.Printf("setup: store failed chat=%d key=%s err=%v", chat.ID, step.key, err)
+			_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, messages.InternalError))
+			return
+		}
+		next = idx + 1
+	}
+	_, _ = b.API.Request(tgbotapi.NewCallback(cb.ID, ""))
+	if next >= len(setupSteps) {
+		edit := tgbotapi.NewEditMessageText(chat.ID, cb.Message.MessageID, messages.SetupDone)
+		if _, err := b.API.Request(edit); err != nil {
+			log.Printf("setup: edit failed chat=%d err=%v", chat.ID, err)
+		}
+		return
+	}
+	step := setupSteps[next]
+	var row []tgbotapi.InlineKeyboardButton
+	for _, o := range step.options {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(o[0], fmt.Sprintf("setup:%d:%s", next, o[1])))
+	}
+	text := fmt.Sprintf(messages.SetupStepHeader, next+1, len(setupSteps)) + "\n" + step.prompt
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chat.ID, cb.Message.MessageID, text, tgbotapi.NewInlineKeyboardMarkup(row))
+	if _, err := b.API.Request(edit); err != nil {
+		log.Printf("setup: edit failed chat=%d err=%v", chat.ID, err)
+	}
+}
@@ -0,0 +1,131 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func setupCallback(chatID, userID int64, data string) *tgbotapi.CallbackQuery {
+	return &tgbotapi.CallbackQuery{
+		ID:      "cb",
+		From:    &tgbotapi.User{ID: userID},
+		Data:    data,
+		Message: &tgbotapi.Message{MessageID: 5, Chat: &tgbotapi.Chat{ID: chatID, Type: "supergroup"}},
+	}
+}
+
+// lastEdit returns the text of the latest editMessageText and the callback data of its buttons.
+func lastEdit(t *testing.T, fake *fakeTelegram) (string, []string) {
+	t.Helper()
+	edits := fake.sent("editMessageText")
+	if len(edits) == 0 {
+		t.Fatal("message not edited")
+	}
+	e := edits[len(edits)-1]
+	var data []string
+	if raw := e["reply_markup"]; raw != "" {
+		var kb tgbotapi.InlineKeyboardMarkup
+		if err := json.Unmarshal([]byte(raw), &kb); err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range kb.InlineKeyboard {
+			for _, btn := range row {
+				data = append(data, *btn.CallbackData)
+			}
+		}
+	}
+	return e["text"], data
+}
+
+func TestSetupWizard(t *testing.T) {
+	const chatID, admin = -100, 42
+	b, fake := newTestBot(t)
+	fake.respond = asAdmin
+	addTestChat(t, b, chatID)
+	// press presses the button whose value ends its callback data
+	press := func(buttons []string, value string) {
+		t.Helper()
+		for _, data := range buttons {
+			if strings.HasSuffix(data, ":"+value) {
+				b.onSetupCallback(setupCallback(chatID, admin, data))
+				return
+			}
+		}
+		t.Fatalf("no %q button in %v", value, buttons)
+	}
+
+	b.onSetupCallback(setupCallback(chatID, admin, "setup:start"))
+	text, buttons := lastEdit(t, fake)
+	if want := fmt.Sprintf(messages.SetupStepHeader, 1, 2) + "\n" + messages.SetupWindowPrompt; text != want {
+		t.Errorf("step 1 = %q, want %q", text, want)
+	}
+	if want := []string{"setup:0:15m", "setup:0:30m", "setup:0:45m", "setup:0:1h"}; fmt.Sprint(buttons) != fmt.Sprint(want) {
+		t.Errorf("step 1 buttons = %v, want %v", buttons, want)
+	}
+
+	press(buttons, "45m")
+	text, buttons = lastEdit(t, fake)
+	if want := fmt.Sprintf(messages.SetupStepHeader, 2, 2) + "\n" + messages.SetupResultsPrompt; text != want {
+		t.Errorf("step 2 = %q, want %q", text, want)
+	}
+	if cfg, _ := b.EffectiveSettings(chatID); cfg.SignupWindow != 45*time.Minute {
+		t.Errorf("window after step 1 = %s, want 45m", cfg.SignupWindow)
+	}
+
+	press(buttons, visibilityPrivate)
+	if text, buttons = lastEdit(t, fake); text != messages.SetupDone || len(buttons) != 0 {
+		t.Errorf("last screen = %q %v, want %q without buttons", text, buttons, messages.SetupDone)
+	}
+	cfg, _ := b.EffectiveSettings(chatID)
+	if cfg.SignupWindow != 45*time.Minute || cfg.ResultsVisibility != visibilityPrivate {
+		t.Errorf("settings = window %s, results %s; want 45m, %s", cfg.SignupWindow, cfg.ResultsVisibility, visibilityPrivate)
+	}
+	for i, a := range callbackAnswers(fake) {
+		if a != "" {
+			t.Errorf("answer %d = %q, want a silent one", i+1, a)
+		}
+	}
+}
+
+func TestSetupWizardRejects(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		name   string
+		admin  bool
+		data   string
+		answer string
+	}{
+		{"non-admin", false, "setup:start", messages.AdminOnly},
+		{"non-admin answer", false, "setup:0:45m", messages.AdminOnly},
+		{"unknown step", true, "setup:9:45m", ""},
+		{"malformed step", true, "setup:x", ""},
+		{"bad value", true, "setup:0:forever", messages.InternalError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			if tt.admin {
+				fake.respond = asAdmin
+			}
+			addTestChat(t, b, chatID)
+
+			b.onSetupCallback(setupCallback(chatID, 42, tt.data))
+			if got := callbackAnswers(fake); len(got) != 1 || got[0] != tt.answer {
+				t.Errorf("answers = %q, want [%q]", got, tt.answer)
+			}
+			if edits := fake.sent("editMessageText"); len(edits) != 0 {
+				t.Errorf("edits = %v, want none", edits)
+			}
+			if cs, err := b.Store.GetChatSettings(chatID); err != nil || cs.SignupWindow != nil {
+				t.Errorf("window = %v, %v; want unset", cs.SignupWindow, err)
+			}
+		})
+	}
+}
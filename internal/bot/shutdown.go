@@ -0,0 +1,42 @@
+package bot
+
+import (
+	"context"
+	"log"
+
+	"coffeetrix24/internal/metrics"
+)
+
+// track registers a piece of work that Shutdown waits for; call the returned func when it is done.
+func (b *Bot) track() func() {
+	b.inflight.Add(1)
+	metrics.InFlight.Add(1)
+	return func() {
+		metrics.InFlight.Add(-1)
+		b.inflight.Done()
+	}
+}
+
+// stopping is closed by Shutdown; background work that waits, such as spread invites, gives up.
+func (b *Bot) stopping() <-chan struct{} {
+	return b.stop
+}
+
+// Shutdown stops background work from starting new sends and waits until in-flight invites,
+// results, reminders and updates are finished, or until ctx is done. Call it after the update
+// loop and the scheduler have stopped and before closing the store.
+func (b *Bot) Shutdown(ctx context.Context) error {
+	b.stopOnce.Do(func() { close(b.stop) })
+	done := make(chan struct{})
+	go func() {
+		b.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Println("shutdown: in-flight work finished")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
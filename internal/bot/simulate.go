@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"coffeetrix24/internal/db"
+)
+
+// simulateWindow is the signup window of a simulated session; it is closed right away anyway.
+const simulateWindow = time.Minute
+
+// Simulate runs one full session in chatID without waiting for the scheduler: it opens today's
+// session, adds n synthetic participants and publishes the results to the chat. Meant for the
+// sandbox chat, since it uses up that chat's session for today.
+func (b *Bot) Simulate(chatID int64, n int) error {
+	if n < 1 || n > 999 {
+		return errors.New("simulate: participant count must be between 1 and 999")
+	}
+	if err := b.Store.UpsertChat(chatID, ""); err != nil {
+		return err
+	}
+	date, slot := b.chatSession(chatID)
+	sessionID, err := b.Store.CreateOrGetTodaySession(chatID, date, slot, time.Now().Add(simulateWindow))
+	if err != nil {
+		return err
+	}
+	if open, err := b.Store.SessionOpen(sessionID, time.Now()); err != nil || !open {
+		return fmt.Errorf("simulate: today's session %d in chat %d is already closed", sessionID, chatID)
+	}
+	for i := 1; i <= n; i++ {
+		if err := b.Store.AddParticipant(sessionID, db.FakeUserIDBase+int64(i), "", fmt.Sprintf("Тестовый участник %d", i)); err != nil {
+			return err
+		}
+	}
+	log.Printf("simulate: chat=%d session=%d participants=%d, publishing", chatID, sessionID, n)
+	b.CloseAndPublish(sessionID)
+	closed, err := b.Store.IsSessionClosed(sessionID)
+	if err != nil {
+		return err
+	}
+	if !closed {
+		return fmt.Errorf("simulate: session %d was not published, see log", sessionID)
+	}
+	return nil
+}
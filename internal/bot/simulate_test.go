@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"coffeetrix24/internal/db"
+)
+
+func TestSimulate(t *testing.T) {
+	const chatID, n = -100, 7
+	b, fake := newTestBot(t)
+	if err := b.Simulate(chatID, n); err != nil {
+		t.Fatal(err)
+	}
+
+	date, slot := b.chatSession(chatID)
+	sessions, err := b.Store.SessionsByDate(date)
+	if err != nil || len(sessions) != 1 || sessions[0].ChatID != chatID || sessions[0].Slot != slot {
+		t.Fatalf("sessions = %+v, %v; want today's session in chat %d", sessions, err, chatID)
+	}
+	groups, err := b.Store.GetSessionGroups(sessions[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[int64]bool{}
+	for _, g := range groups {
+		for _, p := range g {
+			if p.UserID <= db.FakeUserIDBase || p.UserID > db.FakeUserIDBase+n || seen[p.UserID] {
+				t.Errorf("unexpected member %d", p.UserID)
+			}
+			seen[p.UserID] = true
+		}
+	}
+	if len(seen) != n {
+		t.Errorf("grouped %d participants, want %d", len(seen), n)
+	}
+	if got := lastReply(t, fake); !strings.Contains(got, "Тестовый участник 1") {
+		t.Errorf("results %q do not list the synthetic participants", got)
+	}
+
+	// today's session is used up
+	if err := b.Simulate(chatID, n); err == nil {
+		t.Error("second simulation: no error")
+	}
+}
+
+func TestSimulateRejectsCount(t *testing.T) {
+	b, fake := newTestBot(t)
+	for _, n := range []int{0, -1, 1000} {
+		if err := b.Simulate(-100, n); err == nil {
+			t.Errorf("Simulate(%d): no error", n)
+		}
+	}
+	if len(fake.sent("sendMessage")) != 0 {
+		t.Error("rejected simulation sent a message")
+	}
+}
@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// cmdSkip opts the sender out of today's rounds in the chat: they leave today's open session if
+// they joined it, and neither the join button nor carry-over signs them up until /unskip.
+func (b *Bot) cmdSkip(m *tgbotapi.Message) {
+	cfg, err := b.EffectiveSettings(m.Chat.ID)
+	if err != nil {
+		log.Printf("skip: settings lookup failed chat=%d err=%v", m.Chat.ID, err)
+	}
+	date := chatDate(cfg, time.Now())
+	skipped, err := b.Store.IsSkipped(m.Chat.ID, m.From.ID, date)
+	if err != nil {
+		log.Printf("skip: lookup failed chat=%d user=%d err=%v", m.Chat.ID, m.From.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if skipped {
+		b.reply(m, messages.SkipAlready)
+		return
+	}
+	sessionID, open := b.openSessionToday(m.Chat.ID)
+	if open {
+		if in, err := b.Store.IsParticipant(sessionID, m.From.ID); err == nil && in && !cfg.AllowLeave {
+			b.reply(m, messages.LeaveNotAllowed)
+			return
+		}
+	}
+	if err := b.Store.AddSkip(m.Chat.ID, m.From.ID, date); err != nil {
+		log.Printf("skip: store failed chat=%d user=%d err=%v", m.Chat.ID, m.From.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if open {
+		if removed, err := b.Store.RemoveParticipant(sessionID, m.From.ID); err != nil {
+			log.Printf("skip: leave failed chat=%d session=%d user=%d err=%v", m.Chat.ID, sessionID, m.From.ID, err)
+		} else if removed {
+			_, _ = b.refreshInviteCount(sessionID)
+		}
+	}
+	log.Printf("skip: chat=%d user=%d date=%s", m.Chat.ID, m.From.ID, date)
+	b.reply(m, messages.SkipDone)
+}
+
+// cmdUnskip takes back today's /skip, as long as signups for the current round are still open or
+// it has not started yet.
+func (b *Bot) cmdUnskip(m *tgbotapi.Message) {
+	date, slot := b.chatSession(m.Chat.ID)
+	if sessionID, _, err := b.Store.GetSessionByChatDate(m.Chat.ID, date, slot); err == nil && sessionID != 0 {
+		if open, err := b.Store.SessionOpen(sessionID, time.Now()); err == nil && !open {
+			b.reply(m, messages.UnskipTooLate)
+			return
+		}
+	}
+	removed, err := b.Store.RemoveSkip(m.Chat.ID, m.From.ID, date)
+	if err != nil {
+		log.Printf("unskip: store failed chat=%d user=%d err=%v", m.Chat.ID, m.From.ID, err)
+		b.reply(m, messages.InternalError)
+		return
+	}
+	if !removed {
+		b.reply(m, messages.UnskipNotSkipped)
+		return
+	}
+	log.Printf("unskip: chat=%d user=%d date=%s", m.Chat.ID, m.From.ID, date)
+	b.reply(m, messages.UnskipDone)
+}
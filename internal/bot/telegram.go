@@ -0,0 +1,169 @@
+package bot
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"coffeetrix24/internal/messages"
+	"coffeetrix24/internal/metrics"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// inviteEditWindow is how long after sending we still try to edit an invite. Telegram refuses
+// edits of messages older than 48 hours; the margin avoids racing that limit.
+const inviteEditWindow = 47 * time.Hour
+
+func apiError(err error) (*tgbotapi.Error, bool) {
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) {
+		return tgErr, true
+	}
+	return nil, false
+}
+
+// Retry policy of sendRetrying for transient Telegram failures.
+const (
+	sendAttempts = 4
+	// sendBackoff is the first wait after a 5xx; it doubles with every attempt.
+	sendBackoff = time.Second
+	// maxRetryAfter is the longest flood-control wait honoured; longer ones fail right away.
+	maxRetryAfter = time.Minute
+)
+
+// sendRetrying sends c like API.Send but retries transient failures: on 429 it waits as long as
+// Telegram asks in retry_after, on a 5xx it backs off exponentially. Other errors, including
+// network ones where the message may have gone out, are returned at once.
+func (b *Bot) sendRetrying(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	backoff := sendBackoff
+	for attempt := 1; ; attempt++ {
+		msg, err := b.API.Send(c)
+		wait, ok := retryWait(err, backoff)
+		if !ok || attempt == sendAttempts {
+			if err != nil {
+				metrics.SendErrors.Inc()
+			}
+			return msg, err
+		}
+		log.Printf("telegram: send failed attempt=%d, retrying in %s: %v", attempt, wait, err)
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}
+
+// retryWait tells how long to wait before resending after err, and whether to resend at all.
+func retryWait(err error, backoff time.Duration) (time.Duration, bool) {
+	tgErr, ok := apiError(err)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case tgErr.RetryAfter > 0:
+		wait := time.Duration(tgErr.RetryAfter) * time.Second
+		return wait, wait <= maxRetryAfter
+	case tgErr.Code == 429 || tgErr.Code >= 500:
+		return backoff, true
+	}
+	return 0, false
+}
+
+// isUneditable reports Telegram errors meaning the message cannot be edited anymore.
+func isUneditable(err error) bool {
+	tgErr, ok := apiError(err)
+	if !ok {
+		return false
+	}
+	msg := strings.ToLower(tgErr.Message)
+	return strings.Contains(msg, "message can't be edited") || strings.Contains(msg, "message to edit not found")
+}
+
+// isNotModified reports the harmless error returned when an edit changes nothing.
+func isNotModified(err error) bool {
+	tgErr, ok := apiError(err)
+	return ok && strings.Contains(strings.ToLower(tgErr.Message), "message is not modified")
+}
+
+// editInvite applies an edit built by fn to the session's invite message. Invites past the
+// edit window, or ones Telegram already refused to edit, are skipped; a refusal marks the
+// session so later edits are not attempted. It reports whether the edit was applied.
+func (b *Bot) editInvite(sessionID int64, fn func(chatID int64, msgID int) tgbotapi.Chattable) bool {
+	ref, err := b.Store.GetInviteRef(sessionID)
+	if err != nil || ref.MessageID == 0 || !ref.Editable {
+		return false
+	}
+	if time.Since(ref.SentAt) > inviteEditWindow {
+		log.Printf("invite: session=%d invite too old to edit, skipping", sessionID)
+		_ = b.Store.MarkInviteUneditable(sessionID)
+		return false
+	}
+	_, err = b.API.Request(fn(ref.ChatID, ref.MessageID))
+	switch {
+	case err == nil || isNotModified(err):
+		return true
+	case isUneditable(err):
+		log.Printf("invite: session=%d telegram refused edit, disabling further edits: %v", sessionID, err)
+		_ = b.Store.MarkInviteUneditable(sessionID)
+	default:
+		log.Printf("invite: edit failed session=%d err=%v", sessionID, err)
+	}
+	return false
+}
+
+// pinInvite pins a freshly sent invite without notifying members. Missing pin rights
+// are logged only: the invite itself has already been delivered.
+func (b *Bot) pinInvite(chatID int64, msgID int) {
+	_, err := b.API.Request(tgbotapi.PinChatMessageConfig{ChatID: chatID, MessageID: msgID, DisableNotification: true})
+	if err != nil {
+		log.Printf("invite: pin failed chat=%d msg=%d err=%v", chatID, msgID, err)
+	}
+}
+
+// unpinInvite unpins a closed session's invite. Unlike edits this works on messages of any age.
+func (b *Bot) unpinInvite(sessionID int64) {
+	ref, err := b.Store.GetInviteRef(sessionID)
+	if err != nil || ref.MessageID == 0 {
+		return
+	}
+	_, err = b.API.Request(tgbotapi.UnpinChatMessageConfig{ChatID: ref.ChatID, MessageID: ref.MessageID})
+	if err != nil {
+		log.Printf("invite: unpin failed session=%d err=%v", sessionID, err)
+	}
+}
+
+// pinResults pins a session's results message silently and unpins the chat's previous results.
+// Missing pin rights are logged only: the results have already been posted.
+func (b *Bot) pinResults(chatID, sessionID int64, msgID int) {
+	if prev, ok, err := b.Store.PreviousResultMessageID(chatID, sessionID); err != nil {
+		log.Printf("close: previous results lookup failed chat=%d session=%d err=%v", chatID, sessionID, err)
+	} else if ok {
+		if _, err := b.API.Request(tgbotapi.UnpinChatMessageConfig{ChatID: chatID, MessageID: prev}); err != nil {
+			log.Printf("close: unpin previous results failed chat=%d msg=%d err=%v", chatID, prev, err)
+		}
+	}
+	if _, err := b.API.Request(tgbotapi.PinChatMessageConfig{ChatID: chatID, MessageID: msgID, DisableNotification: true}); err != nil {
+		log.Printf("close: pin results failed chat=%d msg=%d err=%v", chatID, msgID, err)
+	}
+}
+
+// removeInviteKeyboard drops the join buttons from a closed session's invite. An invite that can
+// no longer be edited keeps its buttons, so a reply to it tells members signups are closed.
+func (b *Bot) removeInviteKeyboard(sessionID int64) {
+	removed := b.editInvite(sessionID, func(chatID int64, msgID int) tgbotapi.Chattable {
+		empty := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+		return tgbotapi.NewEditMessageReplyMarkup(chatID, msgID, empty)
+	})
+	if removed {
+		return
+	}
+	ref, err := b.Store.GetInviteRef(sessionID)
+	if err != nil || ref.MessageID == 0 || ref.Editable {
+		return
+	}
+	msg := tgbotapi.NewMessage(ref.ChatID, messages.InviteClosedNotice)
+	msg.ReplyToMessageID = ref.MessageID
+	if _, err := b.sendRetrying(msg); err != nil {
+		log.Printf("close: closed notice failed session=%d err=%v", sessionID, err)
+	}
+}
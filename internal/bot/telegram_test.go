@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestRetryWait(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		wait time.Duration
+		ok   bool
+	}{
+		{"success", nil, 0, false},
+		{"network error", errors.New("connection reset"), 0, false},
+		{"bad request", &tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}, 0, false},
+		{"flood control", &tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 5}}, 5 * time.Second, true},
+		{"flood control too long", &tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 3600}}, time.Hour, false},
+		{"429 without retry_after", &tgbotapi.Error{Code: 429}, 2 * time.Second, true},
+		{"server error", &tgbotapi.Error{Code: 502}, 2 * time.Second, true},
+	}
+	for _, tt := range tests {
+		wait, ok := retryWait(tt.err, 2*time.Second)
+		if wait != tt.wait || ok != tt.ok {
+			t.Errorf("%s: retryWait = %s, %v; want %s, %v", tt.name, wait, ok, tt.wait, tt.ok)
+		}
+	}
+}
+
+func TestSendRetrying(t *testing.T) {
+	tests := []struct {
+		name string
+		// failures answer the first sends in turn; later ones succeed
+		failures []*tgbotapi.APIResponse
+		wantErr  bool
+		calls    int
+	}{
+		{"first try", nil, false, 1},
+		{"429 twice then success", []*tgbotapi.APIResponse{
+			apiErrorResponse(429, "Too Many Requests: retry after 1", 1),
+			apiErrorResponse(429, "Too Many Requests: retry after 1", 1),
+		}, false, 3},
+		{"not retried", []*tgbotapi.APIResponse{
+			apiErrorResponse(403, "Forbidden: bot was kicked from the group chat", 0),
+		}, true, 1},
+		{"retry_after too long", []*tgbotapi.APIResponse{
+			apiErrorResponse(429, "Too Many Requests: retry after 3600", 3600),
+		}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			n := 0
+			fake.respond = func(method string, params map[string]string) *tgbotapi.APIResponse {
+				if method != "sendMessage" {
+					return nil
+				}
+				n++
+				if n <= len(tt.failures) {
+					return tt.failures[n-1]
+				}
+				return nil
+			}
+
+			msg, err := b.sendRetrying(tgbotapi.NewMessage(-100, "hi"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && msg.MessageID == 0 {
+				t.Error("no message returned")
+			}
+			if got := len(fake.sent("sendMessage")); got != tt.calls {
+				t.Errorf("sendMessage calls = %d, want %d", got, tt.calls)
+			}
+		})
+	}
+}
+
+func TestRemoveInviteKeyboard(t *testing.T) {
+	const chatID, inviteID = -100, 77
+	refused := apiErrorResponse(400, "Bad Request: message can't be edited", 0)
+	tests := []struct {
+		name string
+		age  time.Duration
+		// marked: an earlier edit was already refused
+		marked    bool
+		edit      *tgbotapi.APIResponse
+		wantEdits int
+		// wantNotice: the closed notice is posted in reply to the invite
+		wantNotice bool
+	}{
+		{"edited", 0, false, nil, 1, false},
+		{"too old to edit", 48 * time.Hour, false, nil, 0, true},
+		{"refused by telegram", 0, false, refused, 1, true},
+		{"refused before", 0, true, nil, 0, true},
+		// the edit may work next time, so the buttons are not declared dead
+		{"failed", 0, false, apiErrorResponse(400, "Bad Request: chat not found", 0), 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			fake.respond = func(method string, _ map[string]string) *tgbotapi.APIResponse {
+				if method == "editMessageReplyMarkup" {
+					return tt.edit
+				}
+				return nil
+			}
+			id := newTestSessionWith(t, b, chatID)
+			if err := b.Store.SetInviteMessageID(id, inviteID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := b.Store.DB.Exec("UPDATE daily_sessions SET created_at=? WHERE id=?", time.Now().UTC().Add(-tt.age), id); err != nil {
+				t.Fatal(err)
+			}
+			if tt.marked {
+				if err := b.Store.MarkInviteUneditable(id); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			b.removeInviteKeyboard(id)
+			if n := len(fake.sent("editMessageReplyMarkup")); n != tt.wantEdits {
+				t.Errorf("edits = %d, want %d", n, tt.wantEdits)
+			}
+			sent := fake.sent("sendMessage")
+			if !tt.wantNotice {
+				if len(sent) != 0 {
+					t.Errorf("messages = %v, want none", sent)
+				}
+				return
+			}
+			if len(sent) != 1 || sent[0]["text"] != messages.InviteClosedNotice || sent[0]["reply_to_message_id"] != "77" {
+				t.Errorf("messages = %v, want the closed notice replying to the invite", sent)
+			}
+			// the session stays marked, so the next close does not try the edit again
+			if ref, err := b.Store.GetInviteRef(id); err != nil || ref.Editable {
+				t.Errorf("invite editable = %v, %v; want marked uneditable", ref.Editable, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package bot
+
+import (
+	"log"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// testInviteData is the callback of the join button on a test invite; it joins nothing.
+const testInviteData = "testinvite"
+
+// cmdTestInvite sends the admin a private copy of the chat's invite as it would be posted,
+// without creating a session or mentioning anyone.
+func (b *Bot) cmdTestInvite(m *tgbotapi.Message) {
+	if !b.requireAdmin(m) {
+		return
+	}
+	cfg, err := b.EffectiveSettings(m.Chat.ID)
+	if err != nil {
+		log.Printf("testinvite: settings lookup failed chat=%d err=%v", m.Chat.ID, err)
+	}
+	cfg.LapsedMentions = 0
+	btn := tgbotapi.NewInlineKeyboardButtonData(joinLabel(cfg), testInviteData)
+	kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(btn))
+	if _, err := b.sendInvite(m.From.ID, kb, b.inviteText(m.Chat.ID, cfg), cfg); err != nil {
+		log.Printf("testinvite: dm failed chat=%d user=%d err=%v", m.Chat.ID, m.From.ID, err)
+		b.reply(m, messages.TestInviteDMFailed)
+		return
+	}
+	if m.Chat.ID != m.From.ID {
+		b.reply(m, messages.TestInviteSent)
+	}
+}
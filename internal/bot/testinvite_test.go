@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/messages"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestCmdTestInvite(t *testing.T) {
+	const chatID, admin = -100, 42
+	b, fake := newTestBot(t)
+	fake.respond = asAdmin
+	addTestChat(t, b, chatID)
+	setTestSetting(t, b, chatID, "emoji", "☕")
+	// a lapsed member the real invite would mention
+	setTestSetting(t, b, chatID, "lapsed_mentions", "3")
+	setTestSetting(t, b, chatID, "lapsed_after", "1")
+	for i, users := range [][]int64{{1}, {}} {
+		id, err := b.Store.CreateOrGetTodaySession(chatID, time.Date(2024, 5, 6+i, 0, 0, 0, 0, time.UTC).Format("2006-01-02"), 0, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range users {
+			if err := b.Store.AddParticipant(id, u, "ann", "Ann"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	cfg, _ := b.EffectiveSettings(chatID)
+	if b.inviteText(chatID, cfg) == inviteBaseHTML {
+		t.Fatal("the real invite mentions nobody")
+	}
+	sessions, _ := b.Store.CountChatSessions(chatID)
+
+	b.cmdTestInvite(testCommand(chatID, admin, "/testinvite"))
+	sent := fake.sent("sendMessage")
+	if len(sent) != 2 {
+		t.Fatalf("messages = %v, want the invite and a reply", sent)
+	}
+	dm := sent[0]
+	if dm["chat_id"] != "42" || dm["text"] != inviteBaseHTML || dm["parse_mode"] != tgbotapi.ModeHTML {
+		t.Errorf("invite = %v, want the plain invite in a DM", dm)
+	}
+	if label := joinLabel(cfg); !strings.Contains(dm["reply_markup"], label) || !strings.Contains(dm["reply_markup"], testInviteData) {
+		t.Errorf("keyboard = %s, want %q with the test callback", dm["reply_markup"], label)
+	}
+	if sent[1]["chat_id"] != "-100" || sent[1]["text"] != messages.TestInviteSent {
+		t.Errorf("reply = %v, want %q in the chat", sent[1], messages.TestInviteSent)
+	}
+	if n, _ := b.Store.CountChatSessions(chatID); n != sessions {
+		t.Errorf("sessions = %d, want %d: a test invite opens none", n, sessions)
+	}
+
+	b.handleUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{ID: "cb", From: &tgbotapi.User{ID: admin}, Data: testInviteData}})
+	if got := callbackAnswers(fake); len(got) != 1 || got[0] != messages.TestInviteTapped {
+		t.Errorf("tap answers = %q, want %q", got, messages.TestInviteTapped)
+	}
+}
+
+func TestCmdTestInviteFails(t *testing.T) {
+	const chatID = -100
+	tests := []struct {
+		name  string
+		admin bool
+		want  string
+		dms   int
+	}{
+		{"dm blocked", true, messages.TestInviteDMFailed, 1},
+		{"non-admin", false, messages.AdminOnly, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			fake.respond = func(method string, params map[string]string) *tgbotapi.APIResponse {
+				if method == "sendMessage" && params["chat_id"] == "42" {
+					return apiErrorResponse(403, "Forbidden: bot can't initiate conversation with a user", 0)
+				}
+				if tt.admin {
+					return asAdmin(method, params)
+				}
+				return nil
+			}
+			addTestChat(t, b, chatID)
+
+			b.cmdTestInvite(testCommand(chatID, 42, "/testinvite"))
+			if got := lastReply(t, fake); got != tt.want {
+				t.Errorf("reply = %q, want %q", got, tt.want)
+			}
+			dms := 0
+			for _, m := range fake.sent("sendMessage") {
+				if m["chat_id"] == "42" {
+					dms++
+				}
+			}
+			if dms != tt.dms {
+				t.Errorf("DM attempts = %d, want %d", dms, tt.dms)
+			}
+		})
+	}
+}
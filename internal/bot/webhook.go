@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// StartWebhook registers webhookURL with Telegram and serves the updates it posts on addr until
+// ctx is done. Updates go through the same deliver as polling, so offsets, retries and handling
+// are identical; a failed update is answered with 500 for Telegram to send it again. Since the
+// webhook is not authenticated otherwise, its URL path should contain a secret.
+func (b *Bot) StartWebhook(ctx context.Context, webhookURL, addr string) error {
+	wh, err := tgbotapi.NewWebhook(webhookURL)
+	if err != nil {
+		return err
+	}
+	// one connection keeps updates in order, as with polling
+	wh.MaxConnections = 1
+	if _, err := b.API.Request(wh); err != nil {
+		return err
+	}
+	b.loadUpdateOffset()
+	mux := http.NewServeMux()
+	mux.HandleFunc(wh.URL.Path, b.handleWebhook)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	log.Printf("updates: webhook listening on %s offset=%d", addr, b.feed.offset)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// handleWebhook takes a single update posted by Telegram.
+func (b *Bot) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	upd, err := b.API.HandleUpdate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !b.deliver(*upd) {
+		http.Error(w, "update failed, retry", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
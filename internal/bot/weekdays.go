@@ -0,0 +1,85 @@
+package bot
+
+import (
+	"strings"
+	"time"
+)
+
+// weekdayMask is a set of weekdays, bit time.Sunday being the lowest. 0 means every day.
+type weekdayMask int
+
+const (
+	everyDay     weekdayMask = 1<<7 - 1
+	workingWeek  weekdayMask = everyDay &^ (1<<time.Saturday | 1<<time.Sunday)
+	weekdayCount             = 7
+)
+
+// weekdayNames are accepted in /set days, indexed by time.Weekday; the Russian ones are also used for display.
+var weekdayNames = [2][weekdayCount]string{
+	{"sun", "mon", "tue", "wed", "thu", "fri", "sat"},
+	{"вс", "пн", "вт", "ср", "чт", "пт", "сб"},
+}
+
+// allows reports whether invites go out on d.
+func (m weekdayMask) allows(d time.Weekday) bool {
+	return m == 0 || m&(1<<d) != 0
+}
+
+// String lists the days from Monday on, e.g. "пн, ср, пт".
+func (m weekdayMask) String() string {
+	if m == 0 || m == everyDay {
+		return "ежедневно"
+	}
+	var days []string
+	for i := 1; i <= weekdayCount; i++ {
+		d := time.Weekday(i % weekdayCount)
+		if m.allows(d) {
+			days = append(days, weekdayNames[1][d])
+		}
+	}
+	return strings.Join(days, ", ")
+}
+
+// parseWeekdays accepts "all", "weekdays", or a comma-separated list of days and ranges such as
+// "mon-fri" or "пн,ср,пт".
+func parseWeekdays(v string) (interface{}, error) {
+	switch strings.ToLower(v) {
+	case "all", "каждый", "ежедневно":
+		return int(everyDay), nil
+	case "weekdays", "будни":
+		return int(workingWeek), nil
+	}
+	var m weekdayMask
+	for _, part := range strings.Split(strings.ToLower(v), ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, ok := weekdayByName(from)
+		if !ok {
+			return nil, errBadValue
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdayByName(to); !ok {
+				return nil, errBadValue
+			}
+		}
+		for d := first; ; d = (d + 1) % weekdayCount {
+			m |= 1 << d
+			if d == last {
+				break
+			}
+		}
+	}
+	return int(m), nil
+}
+
+func weekdayByName(name string) (time.Weekday, bool) {
+	name = strings.TrimSpace(name)
+	for _, names := range weekdayNames {
+		for d, n := range names {
+			if n == name {
+				return time.Weekday(d), true
+			}
+		}
+	}
+	return 0, false
+}
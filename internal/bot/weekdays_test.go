@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWeekdays(t *testing.T) {
+	tests := []struct {
+		in   string
+		want weekdayMask
+		ok   bool
+	}{
+		{"all", everyDay, true},
+		{"weekdays", workingWeek, true},
+		{"будни", workingWeek, true},
+		{"mon-fri", workingWeek, true},
+		{"пн,ср,пт", 1<<time.Monday | 1<<time.Wednesday | 1<<time.Friday, true},
+		{"sat-sun", 1<<time.Saturday | 1<<time.Sunday, true},
+		{"fri-mon", 1<<time.Friday | 1<<time.Saturday | 1<<time.Sunday | 1<<time.Monday, true},
+		{"funday", 0, false},
+		{"mon-", 0, false},
+	}
+	for _, tt := range tests {
+		v, err := parseWeekdays(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseWeekdays(%q) err = %v", tt.in, err)
+			continue
+		}
+		if tt.ok && weekdayMask(v.(int)) != tt.want {
+			t.Errorf("parseWeekdays(%q) = %v, want %v", tt.in, weekdayMask(v.(int)), tt.want)
+		}
+	}
+}
+
+func TestSkipReasonWeekday(t *testing.T) {
+	const chatID = -100
+	// 2024-05-10 is a Friday
+	friday := time.Date(2024, 5, 10, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		days     string
+		timezone string
+		at       time.Time
+		want     string
+	}{
+		{"weekdays on friday", "weekdays", "", friday, ""},
+		{"weekdays on saturday", "weekdays", "", friday.AddDate(0, 0, 1), skipWeekday},
+		{"weekdays on sunday", "weekdays", "", friday.AddDate(0, 0, 2), skipWeekday},
+		{"every day on saturday", "", "", friday.AddDate(0, 0, 1), ""},
+		// 22:00 UTC on Friday is already Saturday in Moscow
+		{"saturday in chat timezone", "weekdays", "Europe/Moscow", friday.Add(13 * time.Hour), skipWeekday},
+		{"friday in chat timezone", "weekdays", "America/New_York", friday.Add(15 * time.Hour), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBot(t)
+			addTestChat(t, b, chatID)
+			if tt.days != "" {
+				setTestSetting(t, b, chatID, "days", tt.days)
+			}
+			if tt.timezone != "" {
+				setTestSetting(t, b, chatID, "timezone", tt.timezone)
+			}
+			cfg, err := b.EffectiveSettings(chatID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := b.skipReason(chatID, cfg, tt.at); got != tt.want {
+				t.Errorf("skipReason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,21 +1,137 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
 	Token        string
 	DatabasePath string
+	// InviteCooldown is the minimum time between two invites in the same chat.
+	InviteCooldown time.Duration
+	// OwnerID is the Telegram user allowed to run owner-only commands (0 disables them).
+	OwnerID int64
+	// OwnerBypass authorizes the owner for admin commands in every chat.
+	OwnerBypass bool
+	// SessionMaxAge is how long past its deadline a session may still be published; older
+	// ones are abandoned silently (0 disables the guard).
+	SessionMaxAge time.Duration
+	// DefaultChatSettings seeds the settings of newly added chats, in /set syntax:
+	// "window=45m;results=both".
+	DefaultChatSettings string
+	// SandboxChatID restricts invites and session handling to a single test chat (0: all chats).
+	SandboxChatID int64
+	// AnonymizeAfter is how long participant names are kept before being anonymized (0: kept forever).
+	AnonymizeAfter time.Duration
+	// ScheduleMinNotice is how soon after a daily time change an invite may go out at the new time.
+	ScheduleMinNotice time.Duration
+	// CatchUpWindow is how late after the daily time a missed invite is still sent at startup (0 disables).
+	CatchUpWindow time.Duration
+	// SignupWindow is how long signups stay open after an invite, for chats without their own
+	// window (0 keeps the built-in 30 minutes).
+	SignupWindow time.Duration
+	// InviteJitter spreads scheduled invites over a random per-chat delay up to this (0 disables).
+	InviteJitter time.Duration
+	// RecordUpdates is a file every incoming update is appended to for -replay ("" disables recording).
+	RecordUpdates string
+	// HTTPAddr is the listen address of the optional HTTP server with calendar feeds ("" disables it).
+	HTTPAddr string
+	// PublicURL is how that server is reached from outside, e.g. "https://coffee.example.com".
+	PublicURL string
+	// WebhookURL switches update delivery from long polling to a webhook at this URL ("" polls).
+	WebhookURL string
+	// WebhookAddr is the listen address of the webhook server.
+	WebhookAddr string
+	// MetricsAddr is the listen address of the Prometheus metrics endpoint ("" disables it).
+	MetricsAddr string
+	// HealthAddr is the listen address of the /healthz endpoint ("" disables it).
+	HealthAddr string
 }
 
+// Bounds of SIGNUP_WINDOW.
+const (
+	minSignupWindow = time.Minute
+	maxSignupWindow = 24 * time.Hour
+)
+
 func FromEnv() Config {
 	cfg := Config{
-		Token:        os.Getenv("TELEGRAM_BOT_TOKEN"),
-		DatabasePath: os.Getenv("DATABASE_PATH"),
+		Token:          os.Getenv("TELEGRAM_BOT_TOKEN"),
+		DatabasePath:   os.Getenv("DATABASE_PATH"),
+		InviteCooldown: durationEnv("INVITE_COOLDOWN", 10*time.Minute),
+		OwnerID:        int64Env("OWNER_ID"),
+		OwnerBypass:    boolEnv("OWNER_BYPASS", true),
+		SessionMaxAge:  durationEnv("SESSION_MAX_AGE", 48*time.Hour),
+
+		DefaultChatSettings: os.Getenv("DEFAULT_CHAT_SETTINGS"),
+		SandboxChatID:       int64Env("SANDBOX_CHAT_ID"),
+		AnonymizeAfter:      time.Duration(int64Env("ANONYMIZE_AFTER_DAYS")) * 24 * time.Hour,
+		ScheduleMinNotice:   durationEnv("SCHEDULE_MIN_NOTICE", 10*time.Minute),
+		CatchUpWindow:       durationEnv("CATCHUP_WINDOW", 2*time.Hour),
+		InviteJitter:        durationEnv("INVITE_JITTER", 0),
+		SignupWindow:        durationEnv("SIGNUP_WINDOW", 0),
+		RecordUpdates:       os.Getenv("RECORD_UPDATES"),
+		HTTPAddr:            os.Getenv("HTTP_ADDR"),
+		PublicURL:           os.Getenv("PUBLIC_URL"),
+		WebhookURL:          os.Getenv("WEBHOOK_URL"),
+		WebhookAddr:         os.Getenv("WEBHOOK_ADDR"),
+		MetricsAddr:         os.Getenv("METRICS_ADDR"),
+		HealthAddr:          os.Getenv("HEALTH_ADDR"),
+	}
+	// same bounds as /set window
+	if cfg.SignupWindow != 0 && (cfg.SignupWindow < minSignupWindow || cfg.SignupWindow > maxSignupWindow) {
+		log.Printf("config: SIGNUP_WINDOW=%s out of range %s-%s, using the default", cfg.SignupWindow, minSignupWindow, maxSignupWindow)
+		cfg.SignupWindow = 0
+	}
+	if cfg.WebhookAddr == "" {
+		cfg.WebhookAddr = ":8443"
 	}
 	if cfg.DatabasePath == "" {
 		cfg.DatabasePath = "./data/coffeetrix.db"
 	}
 	return cfg
 }
+
+// durationEnv reads a time.Duration (e.g. "10m") from env, falling back to def when unset or invalid.
+func durationEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("config: invalid %s=%q, using %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+// boolEnv reads a boolean ("true", "0", ...) from env, falling back to def when unset or invalid.
+func boolEnv(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("config: invalid %s=%q, using %t", key, v, def)
+		return def
+	}
+	return b
+}
+
+func int64Env(key string) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		log.Printf("config: invalid %s=%q, ignoring", key, v)
+		return 0
+	}
+	return n
+}
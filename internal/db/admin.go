@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"coffeetrix24/internal/logic"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PurgeChat deletes everything stored for a chat in one transaction.
+// Tables keyed by chat or session must be listed here when added.
+func (s *Store) PurgeChat(ctx context.Context, chatID int64) error {
+	return s.WithTx(ctx, func(tx *sqlx.Tx) error {
+		stmts := []string{
+			"DELETE FROM feedback WHERE session_id IN (SELECT id FROM daily_sessions WHERE chat_id=?)",
+			"DELETE FROM late_taps WHERE session_id IN (SELECT id FROM daily_sessions WHERE chat_id=?)",
+			"DELETE FROM extension_requests WHERE session_id IN (SELECT id FROM daily_sessions WHERE chat_id=?)",
+			"DELETE FROM published_groups WHERE session_id IN (SELECT id FROM daily_sessions WHERE chat_id=?)",
+			"DELETE FROM pending_groups WHERE session_id IN (SELECT id FROM daily_sessions WHERE chat_id=?)",
+			"DELETE FROM participants WHERE session_id IN (SELECT id FROM daily_sessions WHERE chat_id=?)",
+			"DELETE FROM daily_sessions WHERE chat_id=?",
+			"DELETE FROM chat_settings WHERE chat_id=?",
+			"DELETE FROM facilitators WHERE chat_id=?",
+			"DELETE FROM carried_participants WHERE chat_id=?",
+			"DELETE FROM holidays WHERE chat_id=?",
+			"DELETE FROM skips WHERE chat_id=?",
+			"DELETE FROM chats WHERE chat_id=?",
+		}
+		for _, q := range stmts {
+			if _, err := tx.Exec(q, chatID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// userTables are the tables holding per-user rows. Tables with a user_id column must be listed here when added.
+var userTables = []string{"participants", "feedback", "published_groups", "extension_requests", "late_taps", "facilitators", "carried_participants", "user_directory", "skips"}
+
+// MergeUsers moves all rows of the drop user to the keep user in one transaction, so a person who
+// switched accounts is counted as one. Where both IDs have a row for the same session or chat (both
+// joined the same round), the keep user's row wins and the drop user's is deleted; directory
+// fields the keep user lacks are taken from the drop user's entry. Pending groups, which hold
+// user IDs inside their JSON, are rewritten too.
+func (s *Store) MergeUsers(keep, drop int64) error {
+	if keep == drop {
+		return errors.New("merge: same user")
+	}
+	return s.WithTx(context.Background(), func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(`UPDATE user_directory SET
+			username=COALESCE(username, (SELECT username FROM user_directory WHERE user_id=?)),
+			display_name=COALESCE(display_name, (SELECT display_name FROM user_directory WHERE user_id=?)),
+			team=COALESCE(team, (SELECT team FROM user_directory WHERE user_id=?))
+			WHERE user_id=?`, drop, drop, drop, keep); err != nil {
+			return err
+		}
+		if err := mergePendingGroups(tx, keep, drop); err != nil {
+			return err
+		}
+		for _, t := range userTables {
+			if _, err := tx.Exec("UPDATE OR IGNORE "+t+" SET user_id=? WHERE user_id=?", keep, drop); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("DELETE FROM "+t+" WHERE user_id=?", drop); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// mergePendingGroups replaces drop with keep in every stored pending grouping, removing drop
+// where keep is already grouped in the same session.
+func mergePendingGroups(tx *sqlx.Tx, keep, drop int64) error {
+	var rows []struct {
+		SessionID int64  `db:"session_id"`
+		Data      string `db:"groups_json"`
+	}
+	if err := tx.Select(&rows, "SELECT session_id, groups_json FROM pending_groups"); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		var groups []logic.Group
+		if err := json.Unmarshal([]byte(r.Data), &groups); err != nil {
+			return fmt.Errorf("pending groups of session %d: %w", r.SessionID, err)
+		}
+		hasKeep, hasDrop := false, false
+		for _, g := range groups {
+			for _, m := range g.Members {
+				hasKeep = hasKeep || m.ID == keep
+				hasDrop = hasDrop || m.ID == drop
+			}
+		}
+		if !hasDrop {
+			continue
+		}
+		merged := groups[:0]
+		for _, g := range groups {
+			members := g.Members[:0]
+			for _, m := range g.Members {
+				if m.ID == drop {
+					if hasKeep {
+						continue
+					}
+					m.ID = keep
+				}
+				members = append(members, m)
+			}
+			if len(members) > 0 {
+				merged = append(merged, logic.Group{Members: members})
+			}
+		}
+		data, err := json.Marshal(merged)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE pending_groups SET groups_json=? WHERE session_id=?", string(data), r.SessionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateChatID moves everything stored for a chat to its new ID after Telegram upgraded the group
+// to a supergroup. Tables keyed by chat must be listed here when added. The old chat's settings and
+// history win over rows already created for the new ID. moved is false when nothing is stored for
+// the old ID, e.g. the migration was already applied.
+func (s *Store) MigrateChatID(oldID, newID int64) (moved bool, err error) {
+	err = s.WithTx(context.Background(), func(tx *sqlx.Tx) error {
+		var n int
+		if err := tx.Get(&n, "SELECT COUNT(1) FROM chats WHERE chat_id=?", oldID); err != nil || n == 0 {
+			return err
+		}
+		stmts := []string{
+			// one row per chat: the old chat's row replaces whatever the new ID got
+			"DELETE FROM chats WHERE chat_id=:new",
+			"UPDATE chats SET chat_id=:new, active=1 WHERE chat_id=:old",
+			"DELETE FROM chat_settings WHERE chat_id=:new",
+			"UPDATE chat_settings SET chat_id=:new WHERE chat_id=:old",
+			// rows that may collide with the new ID's own keep the new ID's
+			"UPDATE OR IGNORE daily_sessions SET chat_id=:new WHERE chat_id=:old",
+			"UPDATE OR IGNORE facilitators SET chat_id=:new WHERE chat_id=:old",
+			"DELETE FROM facilitators WHERE chat_id=:old",
+			"UPDATE OR IGNORE carried_participants SET chat_id=:new WHERE chat_id=:old",
+			"DELETE FROM carried_participants WHERE chat_id=:old",
+			"UPDATE OR IGNORE holidays SET chat_id=:new WHERE chat_id=:old",
+			"DELETE FROM holidays WHERE chat_id=:old",
+			"UPDATE OR IGNORE skips SET chat_id=:new WHERE chat_id=:old",
+			"DELETE FROM skips WHERE chat_id=:old",
+			"UPDATE audit_log SET chat_id=:new WHERE chat_id=:old",
+		}
+		args := map[string]interface{}{"old": oldID, "new": newID}
+		for _, q := range stmts {
+			if _, err := tx.NamedExec(q, args); err != nil {
+				return err
+			}
+		}
+		moved = true
+		return nil
+	})
+	return moved, err
+}
+
+// Audit records an owner/admin action.
+func (s *Store) Audit(actorID, chatID int64, action, details string) error {
+	_, err := s.DB.Exec("INSERT INTO audit_log (actor_id, chat_id, action, details) VALUES (?, ?, ?, ?)", actorID, chatID, action, details)
+	return err
+}
@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/logic"
+)
+
+// fillChat stores a row for chatID in every chat- and session-keyed table and returns the
+// chat's session IDs.
+func fillChat(t *testing.T, st *Store, chatID, user int64) []int64 {
+	t.Helper()
+	id := newTestSession(t, st, chatID, "2024-05-06")
+	other := newTestSession(t, st, chatID, "2024-05-07")
+	groups := []logic.Group{{Members: []logic.User{{ID: user}, {ID: user + 1}}}}
+	steps := []func() error{
+		func() error { return st.AddParticipant(id, user, "u", "User") },
+		func() error { return st.AddParticipant(id, user+1, "v", "User 2") },
+		func() error { return st.RecordFeedback(id, user, true) },
+		func() error { _, _, err := st.RecordLateTap(id, user); return err },
+		func() error { _, err := st.RequestExtension(other, user); return err },
+		func() error { return st.SavePublishedGroups(id, groups) },
+		func() error { return st.SavePendingGroups(other, groups) },
+		func() error {
+			window := 10 * time.Minute
+			return st.UpdateChatSettings(chatID, ChatSettingsPatch{ChatSettings: ChatSettings{SignupWindow: &window}})
+		},
+		func() error { return st.SetFacilitator(chatID, user, true) },
+		func() error { return st.CarryOver(chatID, []Participant{{UserID: user}}) },
+		func() error { return st.AddHoliday(chatID, "2024-05-09") },
+		func() error { return st.AddSkip(chatID, user, "2024-05-08") },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("fill chat %d step %d: %v", chatID, i, err)
+		}
+	}
+	return []int64{id, other}
+}
+
+// chatRows counts the rows of every table that belong to chatID, directly or through one of
+// its sessions.
+func chatRows(t *testing.T, st *Store, chatID int64, sessions []int64) map[string]int {
+	t.Helper()
+	var tables []string
+	if err := st.DB.Select(&tables, "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'"); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for _, table := range tables {
+		var cols []string
+		if err := st.DB.Select(&cols, "SELECT name FROM pragma_table_info(?)", table); err != nil {
+			t.Fatal(err)
+		}
+		for _, col := range cols {
+			var n int
+			var err error
+			switch {
+			case col == "chat_id":
+				err = st.DB.Get(&n, "SELECT COUNT(1) FROM "+table+" WHERE chat_id=?", chatID)
+			case col == "session_id":
+				err = st.DB.Get(&n, "SELECT COUNT(1) FROM "+table+" WHERE session_id IN (?, ?)", sessions[0], sessions[1])
+			default:
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts[table] += n
+		}
+	}
+	return counts
+}
+
+func TestPurgeChat(t *testing.T) {
+	st := newTestStore(t)
+	purged := fillChat(t, st, -100, 1)
+	kept := fillChat(t, st, -200, 11)
+	before := chatRows(t, st, -200, kept)
+
+	if err := st.PurgeChat(context.Background(), -100); err != nil {
+		t.Fatal(err)
+	}
+
+	for table, n := range chatRows(t, st, -100, purged) {
+		if n != 0 {
+			t.Errorf("%s: %d rows of the purged chat left", table, n)
+		}
+	}
+	after := chatRows(t, st, -200, kept)
+	if fmt.Sprint(after) != fmt.Sprint(before) {
+		t.Errorf("other chat changed:\nbefore %v\nafter  %v", before, after)
+	}
+	for _, table := range []string{"chats", "daily_sessions", "participants", "feedback", "skips", "holidays"} {
+		if after[table] == 0 {
+			t.Errorf("%s: fixture has no rows of the other chat", table)
+		}
+	}
+}
+
+func TestMergeUsersCollision(t *testing.T) {
+	const keep, drop, other = 1, 2, 3
+	st := newTestStore(t)
+	both := newTestSession(t, st, -100, "2024-05-06")
+	dropOnly := newTestSession(t, st, -100, "2024-05-07")
+	steps := []func() error{
+		// both accounts joined, answered and were grouped in the same round
+		func() error { return st.AddParticipant(both, keep, "keep", "Keep") },
+		func() error { return st.AddParticipant(both, drop, "drop", "Drop") },
+		func() error { return st.AddParticipant(both, other, "other", "Other") },
+		func() error { return st.RecordFeedback(both, keep, true) },
+		func() error { return st.RecordFeedback(both, drop, false) },
+		func() error {
+			return st.SavePublishedGroups(both, []logic.Group{{Members: []logic.User{{ID: keep}, {ID: other}}}, {Members: []logic.User{{ID: drop}}}})
+		},
+		func() error {
+			return st.SavePendingGroups(both, []logic.Group{{Members: []logic.User{{ID: keep}, {ID: drop}}}, {Members: []logic.User{{ID: other}}}})
+		},
+		// only the old account took part in the next round
+		func() error { return st.AddParticipant(dropOnly, drop, "drop", "Drop") },
+		func() error { return st.AddParticipant(dropOnly, other, "other", "Other") },
+		func() error {
+			return st.SavePendingGroups(dropOnly, []logic.Group{{Members: []logic.User{{ID: drop, Name: "Drop"}, {ID: other, Name: "Other"}}}})
+		},
+		func() error { return st.RememberUser(keep, "", "Keep") },
+		func() error { return st.RememberUser(drop, "drop", "Drop") },
+		func() error { return st.SetTeam(drop, "Платформа") },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	if err := st.MergeUsers(keep, drop); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range userTables {
+		var n int
+		if err := st.DB.Get(&n, "SELECT COUNT(1) FROM "+table+" WHERE user_id=?", drop); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("%s: %d rows left for the dropped user", table, n)
+		}
+	}
+	participants := func(session int64) []int64 {
+		t.Helper()
+		parts, err := st.GetParticipants(session)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		for _, p := range parts {
+			ids = append(ids, p.UserID)
+		}
+		return ids
+	}
+	if got, want := participants(both), []int64{keep, other}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shared round participants = %v, want %v", got, want)
+	}
+	if got, want := participants(dropOnly), []int64{keep, other}; !reflect.DeepEqual(got, want) {
+		t.Errorf("old account's round participants = %v, want %v", got, want)
+	}
+	var met bool
+	if err := st.DB.Get(&met, "SELECT met FROM feedback WHERE session_id=? AND user_id=?", both, keep); err != nil || !met {
+		t.Errorf("feedback = %v, %v; want the kept user's answer", met, err)
+	}
+	groups, err := st.GetSessionGroups(both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0]) != 2 || groups[0][0].UserID != keep {
+		t.Errorf("published groups = %+v, want the kept user's group", groups)
+	}
+	pendingIDs := func(session int64) [][]int64 {
+		t.Helper()
+		gs, _, ok, err := st.PendingGroups(session)
+		if err != nil || !ok {
+			t.Fatalf("pending groups of %d: ok=%v err=%v", session, ok, err)
+		}
+		var ids [][]int64
+		for _, g := range gs {
+			var row []int64
+			for _, m := range g.Members {
+				row = append(row, m.ID)
+			}
+			ids = append(ids, row)
+		}
+		return ids
+	}
+	if got, want := pendingIDs(both), [][]int64{{keep}, {other}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shared round pending groups = %v, want %v", got, want)
+	}
+	if got, want := pendingIDs(dropOnly), [][]int64{{keep, other}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("old account's pending groups = %v, want %v", got, want)
+	}
+	p, ok, err := st.LookupUsername("drop")
+	if err != nil || !ok || p.UserID != keep || p.DisplayName != "Keep" {
+		t.Errorf("directory lookup = %+v, %v, %v; want the kept user with the old username", p, ok, err)
+	}
+	if teams, err := st.Teams([]int64{keep}); err != nil || teams[keep] != "Платформа" {
+		t.Errorf("team = %q, %v; want the old account's team", teams[keep], err)
+	}
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CarryOver remembers participants of a cancelled round so they join the chat's next session.
+func (s *Store) CarryOver(chatID int64, parts []Participant) error {
+	return s.WithTx(context.Background(), func(tx *sqlx.Tx) error {
+		for _, p := range parts {
+			if _, err := tx.Exec(`INSERT INTO carried_participants (chat_id, user_id, username, display_name) VALUES (?, ?, ?, ?)
+				ON CONFLICT(chat_id, user_id) DO UPDATE SET username=excluded.username, display_name=excluded.display_name`,
+				chatID, p.UserID, p.Username, p.DisplayName); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TakeCarriedOver adds the chat's carried-over participants to the session and forgets them.
+// Those who opted out of the session's date are forgotten without being added. It returns how many
+// were added.
+func (s *Store) TakeCarriedOver(chatID, sessionID int64, date string) (int, error) {
+	var n int
+	err := s.WithTx(context.Background(), func(tx *sqlx.Tx) error {
+		res, err := tx.Exec(`INSERT OR IGNORE INTO participants (session_id, user_id, username, display_name)
+			SELECT ?, user_id, username, display_name FROM carried_participants
+			WHERE chat_id=? AND user_id NOT IN (SELECT user_id FROM skips WHERE chat_id=? AND skip_date=?)`,
+			sessionID, chatID, chatID, date)
+		if err != nil {
+			return err
+		}
+		added, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		n = int(added)
+		_, err = tx.Exec("DELETE FROM carried_participants WHERE chat_id=?", chatID)
+		return err
+	})
+	return n, err
+}
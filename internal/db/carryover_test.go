@@ -0,0 +1,51 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCarryOver(t *testing.T) {
+	const chatID, date = -100, "2024-05-07"
+	st := newTestStore(t)
+	pair := []Participant{{UserID: 1, Username: "anna", DisplayName: "Anna"}, {UserID: 2, DisplayName: "Boris"}}
+	if err := st.CarryOver(chatID, pair); err != nil {
+		t.Fatal(err)
+	}
+	// a later carry over of the same user keeps one row with the fresh name
+	if err := st.CarryOver(chatID, []Participant{{UserID: 2, DisplayName: "Boris K"}, {UserID: 3, DisplayName: "Vera"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.CarryOver(-200, []Participant{{UserID: 4, DisplayName: "Elsewhere"}}); err != nil {
+		t.Fatal(err)
+	}
+	// 3 opted out of the date
+	if err := st.AddSkip(chatID, 3, date); err != nil {
+		t.Fatal(err)
+	}
+
+	session := newTestSession(t, st, chatID, date)
+	n, err := st.TakeCarriedOver(chatID, session, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("added = %d, want 2", n)
+	}
+	parts, err := st.GetParticipants(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Participant{{UserID: 1, Username: "anna", DisplayName: "Anna"}, {UserID: 2, DisplayName: "Boris K"}}
+	if !reflect.DeepEqual(parts, want) {
+		t.Errorf("participants = %+v, want %+v", parts, want)
+	}
+
+	// everyone of the chat is forgotten, the skipped user too; other chats keep theirs
+	if n, err := st.TakeCarriedOver(chatID, newTestSession(t, st, chatID, "2024-05-08"), "2024-05-08"); err != nil || n != 0 {
+		t.Errorf("second take added %d, %v; want 0", n, err)
+	}
+	if n, err := st.TakeCarriedOver(-200, newTestSession(t, st, -200, date), date); err != nil || n != 1 {
+		t.Errorf("other chat added %d, %v; want 1", n, err)
+	}
+}
@@ -0,0 +1,280 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ChatSettings holds per-chat overrides. Nil fields mean "use the global default".
+// Each field is stored in the chat_settings column named by its db tag; durations in seconds.
+type ChatSettings struct {
+	SignupWindow  *time.Duration `db:"signup_window_sec"`
+	VerifyMembers *bool          `db:"verify_members"`
+	// InviteMedia is "photo:<file_id>" or "sticker:<file_id>" sent with the invite.
+	InviteMedia *string `db:"invite_media"`
+	// GracePeriod extends the signup deadline for late joins without changing the announced one.
+	GracePeriod *time.Duration `db:"grace_period_sec"`
+	// LapsedMentions is how many lapsed participants to mention in each invite (0 disables).
+	LapsedMentions *int `db:"lapsed_mention_limit"`
+	// LapsedAfter is how many recent sessions a user must have missed to count as lapsed.
+	LapsedAfter *int `db:"lapsed_after_sessions"`
+	// LabelSingleGroup keeps the "Группа 1" label when all participants fit into one group.
+	LabelSingleGroup *bool `db:"label_single_group"`
+	// ResultsVisibility is where results go: "public", "private" (DMs) or "both".
+	ResultsVisibility *string `db:"results_visibility"`
+	// GroupLabels is "numeric", "alpha" or "emoji:<e1>,<e2>,...".
+	GroupLabels *string `db:"group_labels"`
+	// EmptyStreakNudge is how many empty sessions in a row trigger the pause suggestion (0 disables).
+	EmptyStreakNudge *int `db:"empty_streak_nudge"`
+	// SummaryThreshold is the participant count above which results are summarised (0: never).
+	SummaryThreshold *int `db:"results_summary_threshold"`
+	// PinInvite pins the invite while signups are open.
+	PinInvite *bool `db:"pin_invite"`
+	// NoShowThreshold is the show-up rate, in percent, below which members are no longer mentioned (0 disables).
+	NoShowThreshold *int `db:"noshow_threshold"`
+	// ExtendVotes is how many participants must ask to extend signups before it happens (0 disables).
+	ExtendVotes *int `db:"extend_votes"`
+	// ExtendBy is how much a requested extension adds to the deadline.
+	ExtendBy *time.Duration `db:"extend_by_sec"`
+	// MinGroupSize is the smallest group worth publishing; SmallGroupPolicy decides what happens to smaller ones.
+	MinGroupSize *int `db:"min_group_size"`
+	// SmallGroupPolicy is "publish", "merge" or "cancel" for groups below MinGroupSize.
+	SmallGroupPolicy *string `db:"small_group_policy"`
+	// MaxGroupSize caps group size; extra people form another group (nil: default 2–3 grouping).
+	MaxGroupSize *int `db:"max_group_size"`
+	// InviteEmoji is a single emoji added to the join button and the join acknowledgement.
+	InviteEmoji *string `db:"invite_emoji"`
+	// PairOnlyPolicy is "publish", "cancel" or "carry" for a round only two people joined.
+	PairOnlyPolicy *string `db:"pair_only_policy"`
+	// TeamTags appends each member's team from the user directory to their name in results.
+	TeamTags *bool `db:"team_tags"`
+	// LateNote tells the chat when several people tapped join too late for a round nobody joined.
+	LateNote *bool `db:"late_note"`
+	// AutoExtendJoins extends signups once when this many people joined within AutoExtendWindow
+	// before the deadline (0 disables).
+	AutoExtendJoins  *int           `db:"auto_extend_joins"`
+	AutoExtendWindow *time.Duration `db:"auto_extend_window_sec"`
+	// AllowLeave lets participants sign off with /leave; LeaveAck replaces the default reply to it.
+	AllowLeave *bool   `db:"allow_leave"`
+	LeaveAck   *string `db:"leave_ack"`
+	// AvoidRepeatDays keeps apart people grouped together within this many days (0 disables).
+	AvoidRepeatDays *int `db:"avoid_repeat_days"`
+	// GroupSize is the preferred group size; the remainder is spread over the groups (nil: default 2–3 grouping).
+	GroupSize *int `db:"group_size"`
+	// BalanceGroups picks the group count nearest to GroupSize and deals members out evenly.
+	BalanceGroups *bool `db:"balance_groups"`
+	// RemindBefore posts a reminder this long before the signup deadline (0 disables).
+	RemindBefore *time.Duration `db:"remind_before_sec"`
+	// PinResults pins the results message until the next results replace it.
+	PinResults *bool `db:"pin_results"`
+	// Timezone is the IANA zone the daily time and session dates are local to (nil: UTC).
+	Timezone *string `db:"timezone"`
+	// InviteDays is a bit mask of weekdays with invites, bit 0 being Sunday (nil: every day).
+	InviteDays *int `db:"invite_days"`
+	// InviteCooldown is the least time between two invites in the chat, whatever the slot (0 disables).
+	InviteCooldown *time.Duration `db:"invite_cooldown_sec"`
+}
+
+// chatSettingField ties a ChatSettings field to its chat_settings column.
+type chatSettingField struct {
+	column string
+	index  int
+	typ    reflect.Type // the pointed-to type: time.Duration, int, bool or string
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// chatSettingFields lists every ChatSettings field, so reads and writes follow the struct
+// instead of separate column lists.
+var chatSettingFields = func() []chatSettingField {
+	t := reflect.TypeOf(ChatSettings{})
+	fields := make([]chatSettingField, t.NumField())
+	for i := range fields {
+		f := t.Field(i)
+		fields[i] = chatSettingField{column: f.Tag.Get("db"), index: i, typ: f.Type.Elem()}
+	}
+	return fields
+}()
+
+// chatSettingColumns whitelists columns that may be written: one per ChatSettings field.
+var chatSettingColumns = func() map[string]chatSettingField {
+	m := make(map[string]chatSettingField, len(chatSettingFields))
+	for _, f := range chatSettingFields {
+		m[f.column] = f
+	}
+	return m
+}()
+
+func isChatSettingColumn(column string) bool {
+	_, ok := chatSettingColumns[column]
+	return ok
+}
+
+// scanTarget returns a value to scan the field's column into.
+func (f chatSettingField) scanTarget() interface{} {
+	switch f.typ.Kind() {
+	case reflect.Bool:
+		return new(sql.NullBool)
+	case reflect.String:
+		return new(sql.NullString)
+	}
+	return new(sql.NullInt64)
+}
+
+// value converts a scanned column to the field's pointer, nil for NULL.
+func (f chatSettingField) value(scanned interface{}) reflect.Value {
+	v := reflect.New(f.typ)
+	switch c := scanned.(type) {
+	case *sql.NullBool:
+		if !c.Valid {
+			return reflect.Zero(v.Type())
+		}
+		v.Elem().SetBool(c.Bool)
+	case *sql.NullString:
+		if !c.Valid {
+			return reflect.Zero(v.Type())
+		}
+		v.Elem().SetString(c.String)
+	case *sql.NullInt64:
+		if !c.Valid {
+			return reflect.Zero(v.Type())
+		}
+		n := c.Int64
+		if f.typ == durationType {
+			n *= int64(time.Second)
+		}
+		v.Elem().SetInt(n)
+	}
+	return v
+}
+
+// GetChatSettings returns overrides for a chat; a chat without a chat_settings row has none.
+func (s *Store) GetChatSettings(chatID int64) (ChatSettings, error) {
+	var cs ChatSettings
+	cols := make([]string, len(chatSettingFields))
+	dest := make([]interface{}, len(chatSettingFields))
+	for i, f := range chatSettingFields {
+		cols[i], dest[i] = f.column, f.scanTarget()
+	}
+	err := s.DB.QueryRowx("SELECT "+strings.Join(cols, ", ")+" FROM chat_settings WHERE chat_id=?", chatID).Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cs, nil
+	}
+	if err != nil {
+		return cs, err
+	}
+	v := reflect.ValueOf(&cs).Elem()
+	for i, f := range chatSettingFields {
+		v.Field(f.index).Set(f.value(dest[i]))
+	}
+	return cs, nil
+}
+
+// ChatSettingsPatch is a partial update of chat overrides: the non-nil fields of the embedded
+// ChatSettings are written and the columns in Reset go back to NULL, the global default.
+// Everything else is left untouched.
+type ChatSettingsPatch struct {
+	ChatSettings
+	Reset []string
+}
+
+// SetColumn sets the patch field stored in column to value as returned by the setting parsers
+// (seconds for durations), or resets the column when value is nil.
+func (p *ChatSettingsPatch) SetColumn(column string, value interface{}) error {
+	f, ok := chatSettingColumns[column]
+	if !ok {
+		return fmt.Errorf("unknown chat setting column %q", column)
+	}
+	if value == nil {
+		p.Reset = append(p.Reset, column)
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	switch {
+	case f.typ == durationType && v.CanInt():
+		v = reflect.ValueOf(time.Duration(v.Int()) * time.Second)
+	case f.typ.Kind() == reflect.Int && v.CanInt():
+		v = reflect.ValueOf(int(v.Int()))
+	}
+	if v.Type() != f.typ {
+		return fmt.Errorf("chat setting column %q: %T is not %s", column, value, f.typ)
+	}
+	ptr := reflect.New(f.typ)
+	ptr.Elem().Set(v)
+	reflect.ValueOf(&p.ChatSettings).Elem().Field(f.index).Set(ptr)
+	return nil
+}
+
+// UpdateChatSettings writes the fields set in patch in a single upsert, leaving other overrides untouched.
+func (s *Store) UpdateChatSettings(chatID int64, patch ChatSettingsPatch) error {
+	var cols []string
+	var vals []interface{}
+	v := reflect.ValueOf(patch.ChatSettings)
+	for _, f := range chatSettingFields {
+		fv := v.Field(f.index)
+		if fv.IsNil() {
+			continue
+		}
+		val := fv.Elem().Interface()
+		if d, ok := val.(time.Duration); ok {
+			val = int64(d / time.Second)
+		}
+		cols = append(cols, f.column)
+		vals = append(vals, val)
+	}
+	for _, c := range patch.Reset {
+		f, ok := chatSettingColumns[c]
+		if !ok {
+			return fmt.Errorf("unknown chat setting column %q", c)
+		}
+		if !v.Field(f.index).IsNil() {
+			return fmt.Errorf("chat setting column %q both set and reset", c)
+		}
+		cols = append(cols, c)
+		vals = append(vals, nil)
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+	return s.upsertChatSettings(chatID, cols, vals)
+}
+
+// SeedChatSettings creates the chat's settings row from values (column → value) unless the chat
+// already has one, so a re-added chat keeps its configuration.
+func (s *Store) SeedChatSettings(chatID int64, values map[string]interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+	cols := make([]string, 0, len(values))
+	args := []interface{}{chatID}
+	for c, v := range values {
+		if !isChatSettingColumn(c) {
+			return fmt.Errorf("unknown chat setting column %q", c)
+		}
+		cols = append(cols, c)
+		args = append(args, v)
+	}
+	q := fmt.Sprintf("INSERT INTO chat_settings (chat_id, %s) VALUES (?%s) ON CONFLICT(chat_id) DO NOTHING",
+		strings.Join(cols, ", "), strings.Repeat(", ?", len(cols)))
+	_, err := s.DB.Exec(q, args...)
+	return err
+}
+
+// upsertChatSettings sets the given columns of the chat's row, creating the row if needed.
+func (s *Store) upsertChatSettings(chatID int64, cols []string, vals []interface{}) error {
+	sets := make([]string, len(cols))
+	for i, c := range cols {
+		if !isChatSettingColumn(c) {
+			return fmt.Errorf("unknown chat setting column %q", c)
+		}
+		sets[i] = c + "=excluded." + c
+	}
+	q := fmt.Sprintf("INSERT INTO chat_settings (chat_id, %s) VALUES (?%s) ON CONFLICT(chat_id) DO UPDATE SET %s",
+		strings.Join(cols, ", "), strings.Repeat(", ?", len(cols)), strings.Join(sets, ", "))
+	_, err := s.DB.Exec(q, append([]interface{}{chatID}, vals...)...)
+	return err
+}
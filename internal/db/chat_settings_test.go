@@ -0,0 +1,130 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUpdateChatSettingsPartial(t *testing.T) {
+	const chatID = -100
+	st := newTestStore(t)
+	window, grace := 45*time.Minute, 5*time.Minute
+	labels, size := "alpha", 4
+	verify := true
+
+	var want ChatSettings
+	steps := []struct {
+		name  string
+		patch ChatSettingsPatch
+		apply func(*ChatSettings)
+	}{
+		{"window", ChatSettingsPatch{ChatSettings: ChatSettings{SignupWindow: &window}}, func(cs *ChatSettings) { cs.SignupWindow = &window }},
+		{"grace", ChatSettingsPatch{ChatSettings: ChatSettings{GracePeriod: &grace}}, func(cs *ChatSettings) { cs.GracePeriod = &grace }},
+		{"labels and size", ChatSettingsPatch{ChatSettings: ChatSettings{GroupLabels: &labels, GroupSize: &size}}, func(cs *ChatSettings) {
+			cs.GroupLabels, cs.GroupSize = &labels, &size
+		}},
+		{"verify", ChatSettingsPatch{ChatSettings: ChatSettings{VerifyMembers: &verify}}, func(cs *ChatSettings) { cs.VerifyMembers = &verify }},
+		{"empty patch", ChatSettingsPatch{}, func(*ChatSettings) {}},
+		{"reset grace", ChatSettingsPatch{Reset: []string{"grace_period_sec"}}, func(cs *ChatSettings) { cs.GracePeriod = nil }},
+		{"reset and set", ChatSettingsPatch{ChatSettings: ChatSettings{GracePeriod: &grace}, Reset: []string{"group_size", "group_labels"}}, func(cs *ChatSettings) {
+			cs.GracePeriod, cs.GroupSize, cs.GroupLabels = &grace, nil, nil
+		}},
+	}
+	for _, s := range steps {
+		if err := st.UpdateChatSettings(chatID, s.patch); err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		s.apply(&want)
+		got, err := st.GetChatSettings(chatID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: settings = %+v, want %+v", s.name, got, want)
+		}
+	}
+}
+
+func TestUpdateChatSettingsRejects(t *testing.T) {
+	st := newTestStore(t)
+	window := time.Hour
+	tests := []struct {
+		name  string
+		patch ChatSettingsPatch
+	}{
+		{"unknown reset column", ChatSettingsPatch{Reset: []string{"chat_id"}}},
+		{"set and reset", ChatSettingsPatch{ChatSettings: ChatSettings{SignupWindow: &window}, Reset: []string{"signup_window_sec"}}},
+	}
+	for _, tt := range tests {
+		if err := st.UpdateChatSettings(-100, tt.patch); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+	if cs, err := st.GetChatSettings(-100); err != nil || !reflect.DeepEqual(cs, ChatSettings{}) {
+		t.Errorf("settings = %+v, %v; want none stored", cs, err)
+	}
+}
+
+// TestChatSettingsRoundTrip sets every field, so each db tag must name a real column.
+func TestChatSettingsRoundTrip(t *testing.T) {
+	st := newTestStore(t)
+	var want ChatSettings
+	v := reflect.ValueOf(&want).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		ptr := reflect.New(v.Field(i).Type().Elem())
+		switch e := ptr.Elem(); e.Kind() {
+		case reflect.Bool:
+			e.SetBool(true)
+		case reflect.String:
+			e.SetString(v.Type().Field(i).Name)
+		default:
+			e.SetInt(int64(i+1) * int64(time.Second))
+		}
+		v.Field(i).Set(ptr)
+	}
+	if err := st.UpdateChatSettings(-100, ChatSettingsPatch{ChatSettings: want}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := st.GetChatSettings(-100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("settings = %+v, want %+v", got, want)
+	}
+}
+
+func TestChatSettingsPatchSetColumn(t *testing.T) {
+	window, size, verify, media := 10*time.Minute, 4, true, "photo:x"
+	tests := []struct {
+		column string
+		value  interface{}
+		want   ChatSettingsPatch
+	}{
+		{"signup_window_sec", int64(600), ChatSettingsPatch{ChatSettings: ChatSettings{SignupWindow: &window}}},
+		{"group_size", 4, ChatSettingsPatch{ChatSettings: ChatSettings{GroupSize: &size}}},
+		{"group_size", int64(4), ChatSettingsPatch{ChatSettings: ChatSettings{GroupSize: &size}}},
+		{"verify_members", true, ChatSettingsPatch{ChatSettings: ChatSettings{VerifyMembers: &verify}}},
+		{"invite_media", "photo:x", ChatSettingsPatch{ChatSettings: ChatSettings{InviteMedia: &media}}},
+		{"invite_media", nil, ChatSettingsPatch{Reset: []string{"invite_media"}}},
+	}
+	for _, tt := range tests {
+		var p ChatSettingsPatch
+		if err := p.SetColumn(tt.column, tt.value); err != nil {
+			t.Errorf("SetColumn(%s, %v): %v", tt.column, tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(p, tt.want) {
+			t.Errorf("SetColumn(%s, %v) = %+v, want %+v", tt.column, tt.value, p, tt.want)
+		}
+	}
+
+	var p ChatSettingsPatch
+	if err := p.SetColumn("no_such_column", 1); err == nil {
+		t.Error("unknown column: no error")
+	}
+	if err := p.SetColumn("verify_members", "yes"); err == nil {
+		t.Error("wrong type: no error")
+	}
+}
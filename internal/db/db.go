@@ -6,6 +6,7 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -17,6 +18,9 @@ var schemaFS embed.FS
 
 type Store struct {
 	DB *sqlx.DB
+	// SandboxChatID, when set, limits chat iteration (daily invites, closing and abandoning
+	// sessions) to that one chat so a deployment can be tried without touching real chats.
+	SandboxChatID int64
 }
 
 func Open(path string) (*Store, error) {
@@ -48,8 +52,107 @@ func (s *Store) migrate() error {
 	if err != nil {
 		return err
 	}
-	_, err = s.DB.Exec(string(ddl))
-	return err
+	if _, err = s.DB.Exec(string(ddl)); err != nil {
+		return err
+	}
+	for _, m := range columnMigrations {
+		var n int
+		if err := s.DB.Get(&n, "SELECT COUNT(1) FROM pragma_table_info(?) WHERE name=?", m.table, m.column); err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := s.DB.Exec(m.ddl); err != nil {
+			return fmt.Errorf("migrate %s.%s: %w", m.table, m.column, err)
+		}
+	}
+	return s.migrateSessionSlots(string(ddl))
+}
+
+// migrateSessionSlots rebuilds daily_sessions of databases created before session slots, whose
+// UNIQUE(chat_id, session_date) allows a single session per day. SQLite cannot change a table
+// constraint in place, so the table is recreated from schema.sql and its rows copied over.
+func (s *Store) migrateSessionSlots(ddl string) error {
+	var n int
+	if err := s.DB.Get(&n, `SELECT COUNT(1) FROM pragma_index_list('daily_sessions') il, pragma_index_info(il.name) ii
+		WHERE il."unique"=1 AND ii.name='slot'`); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	var cols []string
+	if err := s.DB.Select(&cols, "SELECT name FROM pragma_table_info('daily_sessions')"); err != nil {
+		return err
+	}
+	list := strings.Join(cols, ", ")
+	return s.WithTx(context.Background(), func(tx *sqlx.Tx) error {
+		for _, stmt := range []string{
+			"ALTER TABLE daily_sessions RENAME TO daily_sessions_old",
+			ddl,
+			"INSERT INTO daily_sessions (" + list + ") SELECT " + list + " FROM daily_sessions_old",
+			"DROP TABLE daily_sessions_old",
+		} {
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("migrate daily_sessions slots: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// columnMigrations adds columns introduced after the first release to existing databases.
+// Fresh databases get them from schema.sql directly.
+var columnMigrations = []struct {
+	table, column, ddl string
+}{
+	{"daily_sessions", "closing", "ALTER TABLE daily_sessions ADD COLUMN closing INTEGER NOT NULL DEFAULT 0"},
+	{"daily_sessions", "invite_uneditable", "ALTER TABLE daily_sessions ADD COLUMN invite_uneditable INTEGER NOT NULL DEFAULT 0"},
+	{"chats", "empty_streak", "ALTER TABLE chats ADD COLUMN empty_streak INTEGER NOT NULL DEFAULT 0"},
+	{"daily_sessions", "abandoned", "ALTER TABLE daily_sessions ADD COLUMN abandoned INTEGER NOT NULL DEFAULT 0"},
+	{"daily_sessions", "extended", "ALTER TABLE daily_sessions ADD COLUMN extended INTEGER NOT NULL DEFAULT 0"},
+	{"chats", "active", "ALTER TABLE chats ADD COLUMN active INTEGER NOT NULL DEFAULT 1"},
+	{"daily_sessions", "reminded", "ALTER TABLE daily_sessions ADD COLUMN reminded INTEGER NOT NULL DEFAULT 0"},
+	{"daily_sessions", "result_message_id", "ALTER TABLE daily_sessions ADD COLUMN result_message_id INTEGER"},
+	{"daily_sessions", "slot", "ALTER TABLE daily_sessions ADD COLUMN slot INTEGER NOT NULL DEFAULT 0"},
+	{"participants", "anonymized", "ALTER TABLE participants ADD COLUMN anonymized INTEGER NOT NULL DEFAULT 0"},
+	{"chat_settings", "verify_members", "ALTER TABLE chat_settings ADD COLUMN verify_members INTEGER"},
+	{"chat_settings", "invite_media", "ALTER TABLE chat_settings ADD COLUMN invite_media TEXT"},
+	{"chat_settings", "grace_period_sec", "ALTER TABLE chat_settings ADD COLUMN grace_period_sec INTEGER"},
+	{"chat_settings", "lapsed_mention_limit", "ALTER TABLE chat_settings ADD COLUMN lapsed_mention_limit INTEGER"},
+	{"chat_settings", "lapsed_after_sessions", "ALTER TABLE chat_settings ADD COLUMN lapsed_after_sessions INTEGER"},
+	{"chat_settings", "label_single_group", "ALTER TABLE chat_settings ADD COLUMN label_single_group INTEGER"},
+	{"chat_settings", "results_visibility", "ALTER TABLE chat_settings ADD COLUMN results_visibility TEXT"},
+	{"chat_settings", "group_labels", "ALTER TABLE chat_settings ADD COLUMN group_labels TEXT"},
+	{"chat_settings", "empty_streak_nudge", "ALTER TABLE chat_settings ADD COLUMN empty_streak_nudge INTEGER"},
+	{"chat_settings", "results_summary_threshold", "ALTER TABLE chat_settings ADD COLUMN results_summary_threshold INTEGER"},
+	{"chat_settings", "pin_invite", "ALTER TABLE chat_settings ADD COLUMN pin_invite INTEGER"},
+	{"chat_settings", "noshow_threshold", "ALTER TABLE chat_settings ADD COLUMN noshow_threshold INTEGER"},
+	{"chat_settings", "extend_votes", "ALTER TABLE chat_settings ADD COLUMN extend_votes INTEGER"},
+	{"chat_settings", "extend_by_sec", "ALTER TABLE chat_settings ADD COLUMN extend_by_sec INTEGER"},
+	{"chat_settings", "min_group_size", "ALTER TABLE chat_settings ADD COLUMN min_group_size INTEGER"},
+	{"chat_settings", "small_group_policy", "ALTER TABLE chat_settings ADD COLUMN small_group_policy TEXT"},
+	{"chat_settings", "max_group_size", "ALTER TABLE chat_settings ADD COLUMN max_group_size INTEGER"},
+	{"chat_settings", "invite_emoji", "ALTER TABLE chat_settings ADD COLUMN invite_emoji TEXT"},
+	{"chat_settings", "pair_only_policy", "ALTER TABLE chat_settings ADD COLUMN pair_only_policy TEXT"},
+	{"chat_settings", "team_tags", "ALTER TABLE chat_settings ADD COLUMN team_tags INTEGER"},
+	{"chat_settings", "late_note", "ALTER TABLE chat_settings ADD COLUMN late_note INTEGER"},
+	{"chat_settings", "auto_extend_joins", "ALTER TABLE chat_settings ADD COLUMN auto_extend_joins INTEGER"},
+	{"chat_settings", "auto_extend_window_sec", "ALTER TABLE chat_settings ADD COLUMN auto_extend_window_sec INTEGER"},
+	{"chat_settings", "allow_leave", "ALTER TABLE chat_settings ADD COLUMN allow_leave INTEGER"},
+	{"chat_settings", "leave_ack", "ALTER TABLE chat_settings ADD COLUMN leave_ack TEXT"},
+	{"chat_settings", "avoid_repeat_days", "ALTER TABLE chat_settings ADD COLUMN avoid_repeat_days INTEGER"},
+	{"chat_settings", "group_size", "ALTER TABLE chat_settings ADD COLUMN group_size INTEGER"},
+	{"chat_settings", "balance_groups", "ALTER TABLE chat_settings ADD COLUMN balance_groups INTEGER"},
+	{"chat_settings", "remind_before_sec", "ALTER TABLE chat_settings ADD COLUMN remind_before_sec INTEGER"},
+	{"chat_settings", "pin_results", "ALTER TABLE chat_settings ADD COLUMN pin_results INTEGER"},
+	{"chat_settings", "timezone", "ALTER TABLE chat_settings ADD COLUMN timezone TEXT"},
+	{"chat_settings", "invite_days", "ALTER TABLE chat_settings ADD COLUMN invite_days INTEGER"},
+	{"chat_settings", "invite_cooldown_sec", "ALTER TABLE chat_settings ADD COLUMN invite_cooldown_sec INTEGER"},
+	{"user_directory", "team", "ALTER TABLE user_directory ADD COLUMN team TEXT"},
+	{"chats", "paused", "ALTER TABLE chats ADD COLUMN paused INTEGER NOT NULL DEFAULT 0"},
+	{"daily_sessions", "closing_at", "ALTER TABLE daily_sessions ADD COLUMN closing_at TIMESTAMP"},
 }
 
 func (s *Store) UpsertToken(token string) error {
@@ -85,18 +188,143 @@ func (s *Store) SetDailyTime(t string) error {
 	return err
 }
 
+// UpsertChat records the chat the bot is in, reactivating it if the bot was removed before.
 func (s *Store) UpsertChat(chatID int64, title string) error {
-	_, err := s.DB.Exec("INSERT INTO chats (chat_id, title) VALUES (?, ?) ON CONFLICT(chat_id) DO UPDATE SET title=excluded.title", chatID, title)
+	_, err := s.DB.Exec("INSERT INTO chats (chat_id, title) VALUES (?, ?) ON CONFLICT(chat_id) DO UPDATE SET title=excluded.title, active=1", chatID, title)
+	return err
+}
+
+// DeactivateChat stops invites to a chat the bot was removed from, keeping its history, and
+// abandons its open sessions. It returns the IDs of the abandoned sessions.
+func (s *Store) DeactivateChat(ctx context.Context, chatID int64) ([]int64, error) {
+	var ids []int64
+	err := s.WithTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec("UPDATE chats SET active=0 WHERE chat_id=?", chatID); err != nil {
+			return err
+		}
+		if err := tx.Select(&ids, "SELECT id FROM daily_sessions WHERE chat_id=? AND closed=0 AND closing=0", chatID); err != nil {
+			return err
+		}
+		_, err := tx.Exec("UPDATE daily_sessions SET closed=1, abandoned=1 WHERE chat_id=? AND closed=0 AND closing=0", chatID)
+		return err
+	})
+	return ids, err
+}
+
+// SetChatPaused pauses or resumes invites to the chat and reports whether that changed anything.
+func (s *Store) SetChatPaused(chatID int64, paused bool) (bool, error) {
+	res, err := s.DB.Exec("UPDATE chats SET paused=? WHERE chat_id=? AND paused<>?", paused, chatID, paused)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ChatPaused reports whether invites to the chat are paused with /pause.
+func (s *Store) ChatPaused(chatID int64) (bool, error) {
+	var paused bool
+	err := s.DB.Get(&paused, "SELECT COALESCE(MAX(paused), 0) FROM chats WHERE chat_id=?", chatID)
+	return paused, err
+}
+
+// ChatTimezones maps active chats that have their own timezone to its IANA name, honouring
+// SandboxChatID.
+func (s *Store) ChatTimezones() (map[int64]string, error) {
+	rows, err := s.DB.Queryx(`SELECT c.chat_id, cs.timezone FROM chats c JOIN chat_settings cs ON cs.chat_id=c.chat_id
+		WHERE c.active=1 AND cs.timezone IS NOT NULL AND cs.timezone<>'' AND (?=0 OR c.chat_id=?)`, s.SandboxChatID, s.SandboxChatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	zones := map[int64]string{}
+	for rows.Next() {
+		var chatID int64
+		var name string
+		if err := rows.Scan(&chatID, &name); err != nil {
+			return nil, err
+		}
+		zones[chatID] = name
+	}
+	return zones, rows.Err()
+}
+
+// ChatIDs lists the active chats to send invites to, honouring SandboxChatID.
+func (s *Store) ChatIDs() ([]int64, error) {
+	var ids []int64
+	err := s.DB.Select(&ids, "SELECT chat_id FROM chats WHERE active=1 AND (?=0 OR chat_id=?) ORDER BY chat_id", s.SandboxChatID, s.SandboxChatID)
+	return ids, err
+}
+
+type Chat struct {
+	ChatID   int64
+	Title    string
+	JoinedAt time.Time
+}
+
+func (s *Store) GetChat(chatID int64) (Chat, error) {
+	var c Chat
+	err := s.DB.QueryRowx("SELECT chat_id, COALESCE(title,''), joined_at FROM chats WHERE chat_id=?", chatID).Scan(&c.ChatID, &c.Title, &c.JoinedAt)
+	return c, err
+}
+
+// GetEmptyStreak returns how many sessions in a row nobody joined in the chat.
+func (s *Store) GetEmptyStreak(chatID int64) (int, error) {
+	var n int
+	err := s.DB.Get(&n, "SELECT empty_streak FROM chats WHERE chat_id=?", chatID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// UpdateEmptyStreak extends the chat's empty streak after an empty session and resets it otherwise.
+func (s *Store) UpdateEmptyStreak(chatID int64, empty bool) error {
+	_, err := s.DB.Exec("UPDATE chats SET empty_streak = CASE WHEN ? THEN empty_streak+1 ELSE 0 END WHERE chat_id=?", empty, chatID)
 	return err
 }
 
-func (s *Store) CreateOrGetTodaySession(chatID int64, date string, deadline time.Time) (int64, error) {
+// SessionSummary is a short view of a session used by owner tooling.
+type SessionSummary struct {
+	ID           int64
+	Date         string
+	Closed       bool
+	Participants int
+}
+
+// RecentSessions returns the latest sessions of a chat, newest first, skipping offset rows.
+func (s *Store) RecentSessions(chatID int64, limit, offset int) ([]SessionSummary, error) {
+	rows, err := s.DB.Queryx(`SELECT s.id, s.session_date, s.closed, (SELECT COUNT(1) FROM participants p WHERE p.session_id=s.id)
+		FROM daily_sessions s WHERE s.chat_id=? ORDER BY s.session_date DESC, s.id DESC LIMIT ? OFFSET ?`, chatID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []SessionSummary
+	for rows.Next() {
+		var ss SessionSummary
+		if err := rows.Scan(&ss.ID, &ss.Date, &ss.Closed, &ss.Participants); err != nil {
+			return nil, err
+		}
+		res = append(res, ss)
+	}
+	return res, rows.Err()
+}
+
+// CountChatSessions returns how many sessions a chat has had.
+func (s *Store) CountChatSessions(chatID int64) (int, error) {
+	var n int
+	err := s.DB.Get(&n, "SELECT COUNT(1) FROM daily_sessions WHERE chat_id=?", chatID)
+	return n, err
+}
+
+func (s *Store) CreateOrGetTodaySession(chatID int64, date string, slot int, deadline time.Time) (int64, error) {
 	deadlineUTC := deadline.UTC()
 	// Retry loop for SQLITE_BUSY / locked situations.
 	const maxAttempts = 5
 	var lastErr error
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		_, err := s.DB.Exec("INSERT OR IGNORE INTO daily_sessions (chat_id, session_date, signup_deadline) VALUES (?, ?, ?)", chatID, date, deadlineUTC)
+		_, err := s.DB.Exec("INSERT OR IGNORE INTO daily_sessions (chat_id, session_date, slot, signup_deadline) VALUES (?, ?, ?, ?)", chatID, date, slot, deadlineUTC)
 		if err != nil {
 			if isLockedError(err) {
 				lastErr = err
@@ -106,15 +334,15 @@ func (s *Store) CreateOrGetTodaySession(chatID int64, date string, deadline time
 			return 0, fmt.Errorf("insert or ignore daily_session failed (chat=%d date=%s): %w", chatID, date, err)
 		}
 		// Update deadline (best-effort)
-		_, _ = s.DB.Exec("UPDATE daily_sessions SET signup_deadline=? WHERE chat_id=? AND session_date=? AND (signup_deadline IS NULL OR signup_deadline < ?)", deadlineUTC, chatID, date, deadlineUTC)
+		_, _ = s.DB.Exec("UPDATE daily_sessions SET signup_deadline=? WHERE chat_id=? AND session_date=? AND slot=? AND (signup_deadline IS NULL OR signup_deadline < ?)", deadlineUTC, chatID, date, slot, deadlineUTC)
 		var id int64
-		getErr := s.DB.Get(&id, "SELECT id FROM daily_sessions WHERE chat_id=? AND session_date=?", chatID, date)
+		getErr := s.DB.Get(&id, "SELECT id FROM daily_sessions WHERE chat_id=? AND session_date=? AND slot=?", chatID, date, slot)
 		if getErr == nil {
 			return id, nil
 		}
 		if errors.Is(getErr, sql.ErrNoRows) {
 			// Rare race; retry insert explicitly
-			res, insErr := s.DB.Exec("INSERT INTO daily_sessions (chat_id, session_date, signup_deadline) VALUES (?, ?, ?)", chatID, date, deadlineUTC)
+			res, insErr := s.DB.Exec("INSERT INTO daily_sessions (chat_id, session_date, slot, signup_deadline) VALUES (?, ?, ?, ?)", chatID, date, slot, deadlineUTC)
 			if insErr == nil {
 				id2, _ := res.LastInsertId()
 				return id2, nil
@@ -168,35 +396,114 @@ func (s *Store) SetInviteMessageID(sessionID int64, msgID int) error {
 	return err
 }
 
-// GetSessionByChatDate returns session id and invite_message_id if a session exists for given chat/date.
-func (s *Store) GetSessionByChatDate(chatID int64, date string) (id int64, inviteMsgID sql.NullInt64, err error) {
-	err = s.DB.QueryRowx("SELECT id, invite_message_id FROM daily_sessions WHERE chat_id=? AND session_date=?", chatID, date).Scan(&id, &inviteMsgID)
+// SetResultMessageID remembers the message the session's results were published in.
+func (s *Store) SetResultMessageID(sessionID int64, msgID int) error {
+	_, err := s.DB.Exec("UPDATE daily_sessions SET result_message_id=? WHERE id=?", msgID, sessionID)
+	return err
+}
+
+// PreviousResultMessageID returns the results message of the chat's latest session published
+// before sessionID; ok is false when there is none.
+func (s *Store) PreviousResultMessageID(chatID, sessionID int64) (msgID int, ok bool, err error) {
+	err = s.DB.Get(&msgID, `SELECT result_message_id FROM daily_sessions
+		WHERE chat_id=? AND id<? AND result_message_id IS NOT NULL ORDER BY id DESC LIMIT 1`, chatID, sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return msgID, err == nil, err
+}
+
+// InviteRef locates a session's invite message for later edits.
+type InviteRef struct {
+	ChatID    int64
+	MessageID int // 0 when no invite was sent
+	Editable  bool
+	SentAt    time.Time
+}
+
+func (s *Store) GetInviteRef(sessionID int64) (InviteRef, error) {
+	var ref InviteRef
+	var msgID sql.NullInt64
+	var uneditable int
+	err := s.DB.QueryRowx("SELECT chat_id, invite_message_id, invite_uneditable, created_at FROM daily_sessions WHERE id=?", sessionID).
+		Scan(&ref.ChatID, &msgID, &uneditable, &ref.SentAt)
+	ref.MessageID = int(msgID.Int64)
+	ref.Editable = uneditable == 0
+	return ref, err
+}
+
+// MarkInviteUneditable stops further edit attempts of a session's invite.
+func (s *Store) MarkInviteUneditable(sessionID int64) error {
+	_, err := s.DB.Exec("UPDATE daily_sessions SET invite_uneditable=1 WHERE id=?", sessionID)
+	return err
+}
+
+// GetSessionByChatDate returns session id and invite_message_id if a session exists for given chat/date/slot.
+func (s *Store) GetSessionByChatDate(chatID int64, date string, slot int) (id int64, inviteMsgID sql.NullInt64, err error) {
+	err = s.DB.QueryRowx("SELECT id, invite_message_id FROM daily_sessions WHERE chat_id=? AND session_date=? AND slot=?", chatID, date, slot).Scan(&id, &inviteMsgID)
 	return
 }
 
+// LastInviteAt returns when the most recent invite in the chat went out, whatever its date or slot.
+// ok is false when the chat never received an invite.
+func (s *Store) LastInviteAt(chatID int64) (at time.Time, ok bool, err error) {
+	err = s.DB.Get(&at, "SELECT created_at FROM daily_sessions WHERE chat_id=? AND invite_message_id IS NOT NULL ORDER BY created_at DESC LIMIT 1", chatID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return at, true, nil
+}
+
+// AddParticipant is idempotent: adding a user already in the session is a no-op.
 func (s *Store) AddParticipant(sessionID int64, userID int64, username, display string) error {
-	_, err := s.DB.Exec("INSERT INTO participants (session_id, user_id, username, display_name) VALUES (?, ?, ?, ?)", sessionID, userID, username, display)
+	_, err := s.DB.Exec("INSERT INTO participants (session_id, user_id, username, display_name) VALUES (?, ?, ?, ?) ON CONFLICT(session_id, user_id) DO NOTHING", sessionID, userID, username, display)
 	return err
 }
 
+// RemoveParticipant drops a user from a session; it reports whether they were in it.
+func (s *Store) RemoveParticipant(sessionID, userID int64) (bool, error) {
+	r, err := s.DB.Exec("DELETE FROM participants WHERE session_id=? AND user_id=?", sessionID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := r.RowsAffected()
+	return n > 0, err
+}
+
+func (s *Store) CountParticipants(sessionID int64) (int, error) {
+	var n int
+	err := s.DB.Get(&n, "SELECT COUNT(1) FROM participants WHERE session_id=?", sessionID)
+	return n, err
+}
+
 func (s *Store) IsParticipant(sessionID int64, userID int64) (bool, error) {
 	var cnt int
 	err := s.DB.Get(&cnt, "SELECT COUNT(1) FROM participants WHERE session_id=? AND user_id=?", sessionID, userID)
 	return cnt > 0, err
 }
 
+// GetOpenSessionsToClose returns open sessions whose deadline plus the chat's grace period has passed.
 func (s *Store) GetOpenSessionsToClose(now time.Time) ([]int64, error) {
-	rows, err := s.DB.Queryx("SELECT id FROM daily_sessions WHERE closed=0 AND signup_deadline <= ?", now.UTC())
+	rows, err := s.DB.Queryx(`SELECT ds.id, ds.signup_deadline, COALESCE(cs.grace_period_sec, 0)
+		FROM daily_sessions ds LEFT JOIN chat_settings cs ON cs.chat_id=ds.chat_id
+		WHERE ds.closed=0 AND ds.signup_deadline <= ? AND (?=0 OR ds.chat_id=?)`, now.UTC(), s.SandboxChatID, s.SandboxChatID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var ids []int64
 	for rows.Next() {
-		var id int64
-		if err := rows.Scan(&id); err != nil {
+		var id, graceSec int64
+		var deadline time.Time
+		if err := rows.Scan(&id, &deadline, &graceSec); err != nil {
 			return nil, err
 		}
+		if deadline.Add(time.Duration(graceSec) * time.Second).After(now) {
+			continue // still within grace period
+		}
 		ids = append(ids, id)
 	}
 	return ids, rows.Err()
@@ -224,10 +531,10 @@ func (s *Store) GetParticipants(sessionID int64) ([]Participant, error) {
 	return res, rows.Err()
 }
 
-// HasAnySessionForDate returns true if there is at least one session for the given date (YYYY-MM-DD).
-func (s *Store) HasAnySessionForDate(date string) (bool, error) {
+// HasAnySessionForDate returns true if there is at least one session for the given date (YYYY-MM-DD) and slot.
+func (s *Store) HasAnySessionForDate(date string, slot int) (bool, error) {
 	var x int
-	err := s.DB.Get(&x, "SELECT 1 FROM daily_sessions WHERE session_date=? LIMIT 1", date)
+	err := s.DB.Get(&x, "SELECT 1 FROM daily_sessions WHERE session_date=? AND slot=? LIMIT 1", date, slot)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return false, nil
@@ -237,14 +544,126 @@ func (s *Store) HasAnySessionForDate(date string) (bool, error) {
 	return true, nil
 }
 
+// LapsedParticipants returns users who joined earlier sessions of the chat but none of its
+// last `sessions` sessions, most recently seen first. Anonymized rows are skipped: there is no name to mention.
+func (s *Store) LapsedParticipants(chatID int64, sessions int) ([]Participant, error) {
+	// SQLite takes bare columns of an aggregate query from the row holding MAX(p.id),
+	// i.e. each user's latest username and display name.
+	rows, err := s.DB.Queryx(`SELECT p.user_id, COALESCE(p.username,''), COALESCE(p.display_name,''), MAX(p.id)
+		FROM participants p JOIN daily_sessions ds ON ds.id=p.session_id
+		WHERE ds.chat_id=? AND p.anonymized=0 AND p.user_id NOT IN (
+			SELECT user_id FROM participants WHERE session_id IN (
+				SELECT id FROM daily_sessions WHERE chat_id=? ORDER BY session_date DESC, id DESC LIMIT ?))
+		GROUP BY p.user_id ORDER BY MAX(p.id) DESC`, chatID, chatID, sessions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []Participant
+	for rows.Next() {
+		var p Participant
+		var lastID int64
+		if err := rows.Scan(&p.UserID, &p.Username, &p.DisplayName, &lastID); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+// Test-mode fake participants use user IDs in (FakeUserIDBase, FakeUserIDBase+1000).
+// Reporting queries leave them out.
+const FakeUserIDBase = 900000
+
+// IsFakeUserID reports whether the ID belongs to a test-mode fake participant.
+func IsFakeUserID(id int64) bool {
+	return id > FakeUserIDBase && id < FakeUserIDBase+1000
+}
+
+// ParticipantsForDate returns real participants of every session on a date, keyed by session ID.
+func (s *Store) ParticipantsForDate(date string) (map[int64][]Participant, error) {
+	rows, err := s.DB.Queryx(`SELECT p.session_id, p.user_id, COALESCE(p.username,''), COALESCE(p.display_name,'')
+		FROM participants p JOIN daily_sessions ds ON ds.id=p.session_id
+		WHERE ds.session_date=? AND NOT (p.user_id > ? AND p.user_id < ?)
+		ORDER BY p.session_id, p.id`, date, FakeUserIDBase, FakeUserIDBase+1000)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := map[int64][]Participant{}
+	for rows.Next() {
+		var sessionID int64
+		var p Participant
+		if err := rows.Scan(&sessionID, &p.UserID, &p.Username, &p.DisplayName); err != nil {
+			return nil, err
+		}
+		res[sessionID] = append(res[sessionID], p)
+	}
+	return res, rows.Err()
+}
+
 type Participant struct {
 	UserID      int64
 	Username    string
 	DisplayName string
 }
 
+// IsSessionClosed reports whether the session has been published or abandoned.
+func (s *Store) IsSessionClosed(id int64) (bool, error) {
+	var closed bool
+	err := s.DB.Get(&closed, "SELECT closed FROM daily_sessions WHERE id=?", id)
+	return closed, err
+}
+
 func (s *Store) CloseSession(id int64) error {
-	_, err := s.DB.Exec("UPDATE daily_sessions SET closed=1 WHERE id=?", id)
+	_, err := s.DB.Exec("UPDATE daily_sessions SET closed=1, closing=0 WHERE id=?", id)
+	return err
+}
+
+// AbandonStaleSessions closes open sessions whose deadline is before the cutoff without publishing
+// them (e.g. after long downtime) and returns their IDs. Sessions being closed right now are left alone.
+func (s *Store) AbandonStaleSessions(ctx context.Context, before time.Time) ([]int64, error) {
+	var ids []int64
+	err := s.WithTx(ctx, func(tx *sqlx.Tx) error {
+		if err := tx.Select(&ids, "SELECT id FROM daily_sessions WHERE closed=0 AND closing=0 AND signup_deadline < ? AND (?=0 OR chat_id=?)",
+			before.UTC(), s.SandboxChatID, s.SandboxChatID); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if _, err := tx.Exec("UPDATE daily_sessions SET closed=1, abandoned=1 WHERE id=?", id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// StaleClaimAge is how long a close claim holds. A process that died mid-close leaves its claim
+// behind; once older than this, another closer may take it over. It is well above the longest
+// close, including send retries.
+const StaleClaimAge = 15 * time.Minute
+
+// ClaimSessionForClose marks an open session as being closed by the caller at now.
+// It returns true only for the single caller that won the claim; a claim older than
+// StaleClaimAge, or one from before claims were timestamped, can be taken over.
+func (s *Store) ClaimSessionForClose(id int64, now time.Time) (bool, error) {
+	now = now.UTC()
+	res, err := s.DB.Exec(`UPDATE daily_sessions SET closing=1, closing_at=?
+		WHERE id=? AND closed=0 AND (closing=0 OR closing_at IS NULL OR closing_at < ?)`, now, id, now.Add(-StaleClaimAge))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// ReleaseSessionClaim drops a close claim so the session can be retried later.
+func (s *Store) ReleaseSessionClaim(id int64) error {
+	_, err := s.DB.Exec("UPDATE daily_sessions SET closing=0 WHERE id=? AND closed=0", id)
 	return err
 }
 
@@ -255,18 +674,39 @@ func (s *Store) CountSessionsByDate(date string) (int, error) {
 	return c, err
 }
 
-// SessionOpen checks if session is not closed and deadline not passed at given time.
+// DateSession is one chat's session on a given date.
+type DateSession struct {
+	ID     int64 `db:"id"`
+	ChatID int64 `db:"chat_id"`
+	Slot   int   `db:"slot"`
+}
+
+// SessionsByDate returns the sessions of every chat on a date; ParticipantsForDate has their participants.
+func (s *Store) SessionsByDate(date string) ([]DateSession, error) {
+	var res []DateSession
+	err := s.DB.Select(&res, "SELECT id, chat_id, slot FROM daily_sessions WHERE session_date=? ORDER BY chat_id, slot", date)
+	return res, err
+}
+
+// SessionOpen checks if session is not closed and its effective deadline (signup_deadline plus
+// the chat's grace period) has not passed at given time.
 func (s *Store) SessionOpen(id int64, now time.Time) (bool, error) {
 	var closed int
-	var deadline time.Time
-	err := s.DB.QueryRowx("SELECT closed, COALESCE(signup_deadline, CURRENT_TIMESTAMP) FROM daily_sessions WHERE id=?", id).Scan(&closed, &deadline)
+	var deadline sql.NullTime
+	var graceSec int64
+	err := s.DB.QueryRowx(`SELECT ds.closed, ds.signup_deadline, COALESCE(cs.grace_period_sec, 0)
+		FROM daily_sessions ds LEFT JOIN chat_settings cs ON cs.chat_id=ds.chat_id WHERE ds.id=?`, id).Scan(&closed, &deadline, &graceSec)
 	if err != nil {
 		return false, err
 	}
 	if closed != 0 {
 		return false, nil
 	}
-	if now.UTC().After(deadline.UTC()) {
+	if !deadline.Valid {
+		return true, nil
+	}
+	effective := deadline.Time.UTC().Add(time.Duration(graceSec) * time.Second)
+	if now.UTC().After(effective) {
 		return false, nil
 	}
 	return true, nil
@@ -0,0 +1,356 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestStore opens a migrated in-memory store; Open keeps a single connection, so every
+// query sees the same database.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	st, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.DB.Close() })
+	return st
+}
+
+// newTestSession creates a chat and an open session in it.
+func newTestSession(t *testing.T, st *Store, chatID int64, date string) int64 {
+	t.Helper()
+	if err := st.UpsertChat(chatID, "test"); err != nil {
+		t.Fatalf("upsert chat: %v", err)
+	}
+	id, err := st.CreateOrGetTodaySession(chatID, date, 0, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	return id
+}
+
+func TestClaimSessionForCloseConcurrent(t *testing.T) {
+	// two processes: separate stores on one database file
+	path := filepath.Join(t.TempDir(), "bot.db")
+	var stores []*Store
+	for i := 0; i < 2; i++ {
+		st, err := Open(path)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		t.Cleanup(func() { _ = st.DB.Close() })
+		stores = append(stores, st)
+	}
+	id := newTestSession(t, stores[0], -100, "2024-05-06")
+
+	const workers = 20
+	now := time.Now()
+	var wg sync.WaitGroup
+	won := make(chan bool, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(st *Store) {
+			defer wg.Done()
+			ok, err := st.ClaimSessionForClose(id, now)
+			if err != nil {
+				t.Errorf("claim: %v", err)
+			}
+			won <- ok
+		}(stores[i%2])
+	}
+	wg.Wait()
+	close(won)
+	winners := 0
+	for ok := range won {
+		if ok {
+			winners++
+		}
+	}
+	if winners != 1 {
+		t.Fatalf("winners = %d, want 1", winners)
+	}
+}
+
+func TestClaimSessionForClose(t *testing.T) {
+	st := newTestStore(t)
+	id := newTestSession(t, st, -100, "2024-05-06")
+	start := time.Now()
+
+	steps := []struct {
+		name string
+		do   func() error
+		at   time.Duration
+		want bool
+	}{
+		{"first claim", nil, 0, true},
+		{"claimed twice", nil, time.Minute, false},
+		{"claim not yet stale", nil, StaleClaimAge, false},
+		{"stale claim taken over", nil, StaleClaimAge + time.Minute, true},
+		{"taken over claim holds", nil, StaleClaimAge + 2*time.Minute, false},
+		{"after release", func() error { return st.ReleaseSessionClaim(id) }, StaleClaimAge + 3*time.Minute, true},
+		{"after close", func() error { return st.CloseSession(id) }, 2 * StaleClaimAge, false},
+	}
+	for _, step := range steps {
+		if step.do != nil {
+			if err := step.do(); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+		}
+		ok, err := st.ClaimSessionForClose(id, start.Add(step.at))
+		if err != nil {
+			t.Fatalf("%s: claim: %v", step.name, err)
+		}
+		if ok != step.want {
+			t.Errorf("%s: claimed = %v, want %v", step.name, ok, step.want)
+		}
+	}
+}
+
+func TestClaimSessionForCloseUntimedClaim(t *testing.T) {
+	st := newTestStore(t)
+	id := newTestSession(t, st, -100, "2024-05-06")
+	// a claim left by a version that did not record closing_at
+	if _, err := st.DB.Exec("UPDATE daily_sessions SET closing=1 WHERE id=?", id); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := st.ClaimSessionForClose(id, time.Now())
+	if err != nil || !ok {
+		t.Fatalf("claimed = %v, err = %v; want true", ok, err)
+	}
+}
+
+func TestSessionOpenGrace(t *testing.T) {
+	deadline := time.Date(2024, 5, 6, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		grace int // seconds, 0 leaves the setting unset
+		at    time.Duration
+		open  bool
+	}{
+		{"before deadline", 0, -time.Minute, true},
+		{"at deadline", 0, 0, true},
+		{"after deadline without grace", 0, time.Second, false},
+		{"within grace", 300, 4 * time.Minute, true},
+		{"at end of grace", 300, 5 * time.Minute, true},
+		{"after grace", 300, 5*time.Minute + time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newTestStore(t)
+			if err := st.UpsertChat(-100, "test"); err != nil {
+				t.Fatal(err)
+			}
+			id, err := st.CreateOrGetTodaySession(-100, "2024-05-06", 0, deadline)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.grace > 0 {
+				grace := time.Duration(tt.grace) * time.Second
+				if err := st.UpdateChatSettings(-100, ChatSettingsPatch{ChatSettings: ChatSettings{GracePeriod: &grace}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			now := deadline.Add(tt.at)
+
+			open, err := st.SessionOpen(id, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if open != tt.open {
+				t.Errorf("SessionOpen = %v, want %v", open, tt.open)
+			}
+			due, err := st.GetOpenSessionsToClose(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the closer picks a session up once it is no longer open, and already at the very
+			// end of the grace period
+			wantDue := !tt.open || tt.at == time.Duration(tt.grace)*time.Second
+			if gotDue := len(due) == 1; gotDue != wantDue {
+				t.Errorf("due for close = %v, want %v", gotDue, wantDue)
+			}
+		})
+	}
+}
+
+func TestParticipantsForDate(t *testing.T) {
+	st := newTestStore(t)
+	const date = "2024-05-06"
+	a := newTestSession(t, st, -100, date)
+	b := newTestSession(t, st, -200, date)
+	b2, err := st.CreateOrGetTodaySession(-200, date, 1, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := newTestSession(t, st, -300, date)
+	other := newTestSession(t, st, -100, "2024-05-07")
+	add := func(session, user int64) {
+		t.Helper()
+		if err := st.AddParticipant(session, user, "", fmt.Sprintf("User %d", user)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add(a, 1)
+	add(a, 2)
+	add(a, FakeUserIDBase+1)
+	add(b, 1)
+	add(b2, 3)
+	add(other, 4)
+
+	got, err := st.ParticipantsForDate(date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[int64][]int64{}
+	for session, parts := range got {
+		for _, p := range parts {
+			ids[session] = append(ids[session], p.UserID)
+		}
+	}
+	want := map[int64][]int64{a: {1, 2}, b: {1}, b2: {3}}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("participants = %v, want %v", ids, want)
+	}
+
+	sessions, err := st.SessionsByDate(date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSessions := []DateSession{{ID: empty, ChatID: -300}, {ID: b, ChatID: -200}, {ID: b2, ChatID: -200, Slot: 1}, {ID: a, ChatID: -100}}
+	if !reflect.DeepEqual(sessions, wantSessions) {
+		t.Errorf("sessions = %+v, want %+v", sessions, wantSessions)
+	}
+}
+
+func TestLapsedParticipants(t *testing.T) {
+	const chatID = -100
+	st := newTestStore(t)
+	var sessions []int64
+	for _, date := range []string{"2024-05-01", "2024-05-02", "2024-05-03", "2024-05-04", "2024-05-05"} {
+		sessions = append(sessions, newTestSession(t, st, chatID, date))
+	}
+	other := newTestSession(t, st, -200, "2024-05-05")
+	joins := []struct {
+		session int
+		user    int64
+		name    string
+	}{
+		{0, 1, "Active"},
+		{0, 2, "Lapsed"},
+		{0, 5, "Gone"},
+		{0, 6, "Anonymized"},
+		{1, 2, "Lapsed Renamed"},
+		{1, 4, "Left"},
+		{2, 3, "Recent"},
+		{4, 1, "Active"},
+		{4, 4, "Left"},
+	}
+	for _, j := range joins {
+		if err := st.AddParticipant(sessions[j.session], j.user, "", j.name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.AddParticipant(other, 7, "", "Elsewhere"); err != nil {
+		t.Fatal(err)
+	}
+	// user 4 left the latest session, user 5 the only one they had joined
+	for _, l := range []struct{ session, user int64 }{{sessions[4], 4}, {sessions[0], 5}} {
+		if _, err := st.RemoveParticipant(l.session, l.user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.DB.Exec("UPDATE participants SET anonymized=1, display_name=NULL WHERE user_id=6"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := st.LapsedParticipants(chatID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Participant{{UserID: 3, DisplayName: "Recent"}, {UserID: 4, DisplayName: "Left"}, {UserID: 2, DisplayName: "Lapsed Renamed"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lapsed = %+v, want %+v", got, want)
+	}
+
+	// a window covering every session leaves nobody lapsed
+	if got, err := st.LapsedParticipants(chatID, 10); err != nil || len(got) != 0 {
+		t.Errorf("lapsed over all sessions = %+v, %v; want none", got, err)
+	}
+}
+
+func TestSandboxChatID(t *testing.T) {
+	tests := []struct {
+		name    string
+		sandbox int64
+		want    []int64 // chats seen by each query
+	}{
+		{"all chats", 0, []int64{-200, -100}},
+		{"sandbox", -100, []int64{-100}},
+		{"sandbox chat unknown", -300, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newTestStore(t)
+			st.SandboxChatID = tt.sandbox
+			sessions := map[int64]int64{}
+			for _, chatID := range []int64{-100, -200} {
+				if err := st.UpsertChat(chatID, "test"); err != nil {
+					t.Fatal(err)
+				}
+				// already past its deadline, so both closing and abandoning see it
+				id, err := st.CreateOrGetTodaySession(chatID, "2024-05-06", 0, time.Now().Add(-time.Hour))
+				if err != nil {
+					t.Fatal(err)
+				}
+				sessions[id] = chatID
+			}
+			chatsOf := func(ids []int64) []int64 {
+				var chats []int64
+				for _, id := range ids {
+					chats = append(chats, sessions[id])
+				}
+				sort.Slice(chats, func(i, j int) bool { return chats[i] < chats[j] })
+				return chats
+			}
+
+			ids, err := st.ChatIDs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ChatIDs = %v, want %v", ids, tt.want)
+			}
+			due, err := st.GetOpenSessionsToClose(time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := chatsOf(due); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sessions to close in chats %v, want %v", got, tt.want)
+			}
+			abandoned, err := st.AbandonStaleSessions(context.Background(), time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := chatsOf(abandoned); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("abandoned sessions in chats %v, want %v", got, tt.want)
+			}
+			// sessions outside the sandbox are left open
+			for id, chatID := range sessions {
+				closed, err := st.IsSessionClosed(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := tt.sandbox == 0 || chatID == tt.sandbox; closed != want {
+					t.Errorf("chat %d session closed = %v, want %v", chatID, closed, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package db
+
+// InviteDiag summarises daily_sessions by whether the invite message was recorded.
+// A session without invite_message_id most likely means the send failed after the row was created.
+type InviteDiag struct {
+	Total         int
+	Sent          int
+	MissingOpen   int // no invite, still accepting signups
+	MissingClosed int // no invite, closed or abandoned
+	Recent        []MissingInvite
+}
+
+// MissingInvite is a session whose invite message ID was never stored.
+type MissingInvite struct {
+	ID     int64  `db:"id"`
+	ChatID int64  `db:"chat_id"`
+	Date   string `db:"session_date"`
+	Closed bool   `db:"closed"`
+}
+
+// InviteDiagnostics is a read-only scan of sessions for missing invite message IDs.
+// Recent holds up to limit newest offending sessions.
+func (s *Store) InviteDiagnostics(limit int) (InviteDiag, error) {
+	var d InviteDiag
+	err := s.DB.QueryRow(`SELECT
+		COUNT(*),
+		COALESCE(SUM(invite_message_id IS NOT NULL), 0),
+		COALESCE(SUM(invite_message_id IS NULL AND closed=0 AND abandoned=0), 0),
+		COALESCE(SUM(invite_message_id IS NULL AND (closed=1 OR abandoned=1)), 0)
+		FROM daily_sessions`).Scan(&d.Total, &d.Sent, &d.MissingOpen, &d.MissingClosed)
+	if err != nil {
+		return d, err
+	}
+	err = s.DB.Select(&d.Recent, `SELECT id, chat_id, session_date, (closed=1 OR abandoned=1) AS closed
+		FROM daily_sessions WHERE invite_message_id IS NULL
+		ORDER BY session_date DESC, id DESC LIMIT ?`, limit)
+	return d, err
+}
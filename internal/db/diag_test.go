@@ -0,0 +1,61 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInviteDiagnostics(t *testing.T) {
+	st := newTestStore(t)
+	sent := newTestSession(t, st, -100, "2024-05-06")
+	if err := st.SetInviteMessageID(sent, 10); err != nil {
+		t.Fatal(err)
+	}
+	open := newTestSession(t, st, -100, "2024-05-07")
+	closed := newTestSession(t, st, -200, "2024-05-05")
+	if err := st.CloseSession(closed); err != nil {
+		t.Fatal(err)
+	}
+	// a closed session whose invite did go out is not a problem
+	sentClosed := newTestSession(t, st, -200, "2024-05-06")
+	if err := st.SetInviteMessageID(sentClosed, 11); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.CloseSession(sentClosed); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := st.InviteDiagnostics(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Total != 4 || d.Sent != 2 || d.MissingOpen != 1 || d.MissingClosed != 1 {
+		t.Errorf("counts = total %d sent %d open %d closed %d, want 4 2 1 1", d.Total, d.Sent, d.MissingOpen, d.MissingClosed)
+	}
+	want := []MissingInvite{
+		{ID: open, ChatID: -100, Date: "2024-05-07"},
+		{ID: closed, ChatID: -200, Date: "2024-05-05", Closed: true},
+	}
+	if !reflect.DeepEqual(d.Recent, want) {
+		t.Errorf("recent = %+v, want %+v", d.Recent, want)
+	}
+
+	d, err = st.InviteDiagnostics(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Recent) != 1 || d.Recent[0].ID != open {
+		t.Errorf("limited recent = %+v, want only session %d", d.Recent, open)
+	}
+}
+
+func TestInviteDiagnosticsEmpty(t *testing.T) {
+	st := newTestStore(t)
+	d, err := st.InviteDiagnostics(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Total != 0 || d.Sent != 0 || d.MissingOpen != 0 || d.MissingClosed != 0 || len(d.Recent) != 0 {
+		t.Errorf("diagnostics = %+v, want zero", d)
+	}
+}
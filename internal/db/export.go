@@ -0,0 +1,164 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ChatConfigVersion is the current format version of ChatConfigExport.
+const ChatConfigVersion = 1
+
+// ChatConfigExport is a portable copy of a chat's configuration: its settings overrides and facilitators.
+type ChatConfigExport struct {
+	Version      int                    `json:"version"`
+	Settings     map[string]interface{} `json:"settings"`
+	Facilitators []int64                `json:"facilitators"`
+}
+
+// ExportChatConfig collects the chat's per-chat configuration. Unset overrides are omitted.
+func (s *Store) ExportChatConfig(chatID int64) (ChatConfigExport, error) {
+	exp := ChatConfigExport{Version: ChatConfigVersion, Settings: map[string]interface{}{}, Facilitators: []int64{}}
+	cols := settingColumnList()
+	row := map[string]interface{}{}
+	err := s.DB.QueryRowx("SELECT "+strings.Join(cols, ", ")+" FROM chat_settings WHERE chat_id=?", chatID).MapScan(row)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return exp, err
+	}
+	for col, v := range row {
+		switch val := v.(type) {
+		case nil:
+			continue
+		case []byte:
+			exp.Settings[col] = string(val)
+		default:
+			exp.Settings[col] = val
+		}
+	}
+	if err := s.DB.Select(&exp.Facilitators, "SELECT user_id FROM facilitators WHERE chat_id=? ORDER BY user_id", chatID); err != nil {
+		return exp, err
+	}
+	return exp, nil
+}
+
+// ParseChatConfig decodes and validates an exported configuration.
+func ParseChatConfig(data []byte) (ChatConfigExport, error) {
+	var exp ChatConfigExport
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&exp); err != nil {
+		return exp, err
+	}
+	if exp.Version < 1 || exp.Version > ChatConfigVersion {
+		return exp, fmt.Errorf("unsupported config version %d", exp.Version)
+	}
+	for col, v := range exp.Settings {
+		if !isChatSettingColumn(col) {
+			return exp, fmt.Errorf("unknown setting %q", col)
+		}
+		switch val := v.(type) {
+		case nil, string:
+		case bool:
+			if val {
+				exp.Settings[col] = 1
+			} else {
+				exp.Settings[col] = 0
+			}
+		case json.Number:
+			n, err := val.Int64()
+			if err != nil {
+				return exp, fmt.Errorf("setting %q: %v", col, err)
+			}
+			exp.Settings[col] = n
+		default:
+			return exp, fmt.Errorf("setting %q has unsupported type %T", col, v)
+		}
+	}
+	return exp, nil
+}
+
+// ImportChatConfig replaces the chat's configuration with exp in one transaction.
+func (s *Store) ImportChatConfig(ctx context.Context, chatID int64, exp ChatConfigExport) error {
+	return s.WithTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec("DELETE FROM chat_settings WHERE chat_id=?", chatID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM facilitators WHERE chat_id=?", chatID); err != nil {
+			return err
+		}
+		if len(exp.Settings) > 0 {
+			cols := []string{"chat_id"}
+			args := []interface{}{chatID}
+			for col, v := range exp.Settings {
+				if !isChatSettingColumn(col) {
+					return fmt.Errorf("unknown setting %q", col)
+				}
+				cols = append(cols, col)
+				args = append(args, v)
+			}
+			q := fmt.Sprintf("INSERT INTO chat_settings (%s) VALUES (?%s)", strings.Join(cols, ", "), strings.Repeat(", ?", len(cols)-1))
+			if _, err := tx.Exec(q, args...); err != nil {
+				return err
+			}
+		}
+		for _, id := range exp.Facilitators {
+			if _, err := tx.Exec("INSERT INTO facilitators (chat_id, user_id) VALUES (?, ?) ON CONFLICT DO NOTHING", chatID, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SessionExportRow is one participant of one session in ExportSessions. Group is the 1-based group
+// number in the published results, 0 when the session published no groups.
+type SessionExportRow struct {
+	Date        string
+	Slot        int
+	Group       int
+	UserID      int64
+	Username    string
+	DisplayName string
+}
+
+// ExportSessions returns every real participant of the chat's sessions, oldest session first and
+// in signup order within a session.
+func (s *Store) ExportSessions(chatID int64) ([]SessionExportRow, error) {
+	rows, err := s.DB.Queryx(`SELECT ds.session_date, ds.slot, COALESCE(g.group_no+1, 0), p.user_id,
+			COALESCE(p.username,''), COALESCE(p.display_name,'')
+		FROM participants p
+		JOIN daily_sessions ds ON ds.id=p.session_id
+		LEFT JOIN published_groups g ON g.session_id=p.session_id AND g.user_id=p.user_id
+		WHERE ds.chat_id=? AND NOT (p.user_id > ? AND p.user_id < ?)
+		ORDER BY ds.session_date, ds.slot, ds.id, p.id`, chatID, FakeUserIDBase, FakeUserIDBase+1000)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []SessionExportRow
+	for rows.Next() {
+		var r SessionExportRow
+		if err := rows.Scan(&r.Date, &r.Slot, &r.Group, &r.UserID, &r.Username, &r.DisplayName); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+func settingColumnList() []string {
+	cols := make([]string, 0, len(chatSettingColumns))
+	for c := range chatSettingColumns {
+		cols = append(cols, c)
+	}
+	sort.Strings(cols)
+	return cols
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// RequestExtension records a participant's request to extend signups and returns
+// how many distinct participants have asked so far.
+func (s *Store) RequestExtension(sessionID, userID int64) (int, error) {
+	if _, err := s.DB.Exec("INSERT INTO extension_requests (session_id, user_id) VALUES (?, ?) ON CONFLICT DO NOTHING", sessionID, userID); err != nil {
+		return 0, err
+	}
+	var n int
+	err := s.DB.Get(&n, "SELECT COUNT(1) FROM extension_requests WHERE session_id=?", sessionID)
+	return n, err
+}
+
+// RecordLateTap notes that the user tapped join after signups closed. It returns how many distinct
+// users tapped late so far and whether this user is new among them.
+func (s *Store) RecordLateTap(sessionID, userID int64) (n int, added bool, err error) {
+	res, err := s.DB.Exec("INSERT INTO late_taps (session_id, user_id) VALUES (?, ?) ON CONFLICT DO NOTHING", sessionID, userID)
+	if err != nil {
+		return 0, false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return 0, false, err
+	}
+	n, err = s.CountLateTaps(sessionID)
+	return n, inserted > 0, err
+}
+
+// CountLateTaps returns how many distinct users tapped join after the session's signups closed.
+func (s *Store) CountLateTaps(sessionID int64) (int, error) {
+	var n int
+	err := s.DB.Get(&n, "SELECT COUNT(1) FROM late_taps WHERE session_id=?", sessionID)
+	return n, err
+}
+
+// JoinsNearDeadline counts participants who joined within window before the session's deadline,
+// or after it during the grace period.
+func (s *Store) JoinsNearDeadline(sessionID int64, window time.Duration) (int, error) {
+	var deadline sql.NullTime
+	if err := s.DB.Get(&deadline, "SELECT signup_deadline FROM daily_sessions WHERE id=?", sessionID); err != nil || !deadline.Valid {
+		return 0, err
+	}
+	// joined_at is CURRENT_TIMESTAMP text, so compare against the same UTC layout
+	since := deadline.Time.UTC().Add(-window).Format("2006-01-02 15:04:05")
+	var n int
+	err := s.DB.Get(&n, "SELECT COUNT(1) FROM participants WHERE session_id=? AND joined_at >= ?", sessionID, since)
+	return n, err
+}
+
+// SessionExtended reports whether the session's signups were already extended.
+func (s *Store) SessionExtended(sessionID int64) (bool, error) {
+	var extended bool
+	err := s.DB.Get(&extended, "SELECT extended FROM daily_sessions WHERE id=?", sessionID)
+	return extended, err
+}
+
+// ExtendSession moves an open session's deadline by d. A session is extended at most once;
+// applied is false if it already was, or is closed. It returns the new deadline.
+func (s *Store) ExtendSession(sessionID int64, d time.Duration) (deadline time.Time, applied bool, err error) {
+	err = s.WithTx(context.Background(), func(tx *sqlx.Tx) error {
+		var current sql.NullTime
+		err := tx.QueryRowx("SELECT signup_deadline FROM daily_sessions WHERE id=? AND closed=0 AND closing=0 AND extended=0", sessionID).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !current.Valid) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		deadline = current.Time.UTC().Add(d)
+		if _, err := tx.Exec("UPDATE daily_sessions SET signup_deadline=?, extended=1 WHERE id=?", deadline, sessionID); err != nil {
+			return err
+		}
+		applied = true
+		return nil
+	})
+	return deadline, applied, err
+}
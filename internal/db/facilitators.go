@@ -0,0 +1,31 @@
+package db
+
+// SetFacilitator marks or unmarks a user as the chat's facilitator.
+func (s *Store) SetFacilitator(chatID, userID int64, on bool) error {
+	if !on {
+		_, err := s.DB.Exec("DELETE FROM facilitators WHERE chat_id=? AND user_id=?", chatID, userID)
+		return err
+	}
+	_, err := s.DB.Exec("INSERT INTO facilitators (chat_id, user_id) VALUES (?, ?) ON CONFLICT DO NOTHING", chatID, userID)
+	return err
+}
+
+// IsFacilitator reports whether the user is a facilitator of the chat.
+func (s *Store) IsFacilitator(chatID, userID int64) (bool, error) {
+	var n int
+	err := s.DB.Get(&n, "SELECT COUNT(1) FROM facilitators WHERE chat_id=? AND user_id=?", chatID, userID)
+	return n > 0, err
+}
+
+// Facilitators returns the set of the chat's facilitator user IDs.
+func (s *Store) Facilitators(chatID int64) (map[int64]bool, error) {
+	var ids []int64
+	if err := s.DB.Select(&ids, "SELECT user_id FROM facilitators WHERE chat_id=?", chatID); err != nil {
+		return nil, err
+	}
+	set := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
@@ -0,0 +1,51 @@
+package db
+
+import "time"
+
+// RecordFeedback stores a participant's answer, replacing an earlier one for the same session.
+func (s *Store) RecordFeedback(sessionID, userID int64, met bool) error {
+	_, err := s.DB.Exec(`INSERT INTO feedback (session_id, user_id, met) VALUES (?, ?, ?)
+		ON CONFLICT(session_id, user_id) DO UPDATE SET met=excluded.met, updated_at=CURRENT_TIMESTAMP`, sessionID, userID, met)
+	return err
+}
+
+// FeedbackStats aggregates answers for a chat's sessions.
+type FeedbackStats struct {
+	Answers int
+	Met     int
+}
+
+// MetShare is the fraction of answers saying the meeting happened.
+func (f FeedbackStats) MetShare() float64 {
+	if f.Answers == 0 {
+		return 0
+	}
+	return float64(f.Met) / float64(f.Answers)
+}
+
+// LowShowUpUsers returns chat members whose feedback over the chat's last `sessions` sessions says
+// the meeting took place in less than maxPercent of at least minAnswers answers.
+func (s *Store) LowShowUpUsers(chatID int64, sessions, minAnswers, maxPercent int) (map[int64]bool, error) {
+	var ids []int64
+	err := s.DB.Select(&ids, `SELECT f.user_id FROM feedback f
+		WHERE f.session_id IN (SELECT id FROM daily_sessions WHERE chat_id=? ORDER BY session_date DESC LIMIT ?)
+		GROUP BY f.user_id
+		HAVING COUNT(1) >= ? AND SUM(f.met)*100 < ?*COUNT(1)`, chatID, sessions, minAnswers, maxPercent)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
+
+// ChatFeedbackStats aggregates feedback of the chat's sessions dated on or after since.
+func (s *Store) ChatFeedbackStats(chatID int64, since time.Time) (FeedbackStats, error) {
+	var st FeedbackStats
+	err := s.DB.QueryRowx(`SELECT COUNT(1), COALESCE(SUM(f.met), 0) FROM feedback f
+		JOIN daily_sessions ds ON ds.id=f.session_id
+		WHERE ds.chat_id=? AND ds.session_date>=?`, chatID, since.UTC().Format("2006-01-02")).Scan(&st.Answers, &st.Met)
+	return st, err
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLowShowUpUsers(t *testing.T) {
+	const chatID = -100
+	st := newTestStore(t)
+	var sessions []int64
+	for day := 1; day <= 6; day++ {
+		sessions = append(sessions, newTestSession(t, st, chatID, fmt.Sprintf("2024-05-%02d", day)))
+	}
+	other := newTestSession(t, st, -200, "2024-05-06")
+	// answers per user, oldest session first; sessions 0 and 1 fall outside the last four
+	answers := map[int64][]int{ // 1 met, 0 missed, -1 no answer
+		1: {-1, -1, 1, 0, 0, 0},   // 25%: low
+		2: {-1, -1, 1, 0, 1, 0},   // exactly 50%: not below the threshold
+		3: {-1, -1, -1, -1, 0, 0}, // too few answers to judge
+		4: {0, 0, 0, -1, -1, 1},   // missed only before the window
+		5: {1, 1, -1, 0, 0, 0},    // 0% in the window
+	}
+	for user, row := range answers {
+		for i, a := range row {
+			if a < 0 {
+				continue
+			}
+			if err := st.RecordFeedback(sessions[i], user, a == 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// misses in another chat do not count here
+	for _, user := range []int64{2, 6} {
+		if err := st.RecordFeedback(other, user, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := st.LowShowUpUsers(chatID, 4, 3, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int64]bool{1: true, 5: true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("low show-up = %v, want %v", got, want)
+	}
+
+	// a wider window brings user 4's old misses in
+	got, err = st.LowShowUpUsers(chatID, 6, 3, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int64]bool{1: true, 4: true, 5: true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("low show-up over six sessions = %v, want %v", got, want)
+	}
+
+	if got, err := st.LowShowUpUsers(-300, 4, 3, 50); err != nil || len(got) != 0 {
+		t.Errorf("chat without feedback = %v, %v; want none", got, err)
+	}
+}
@@ -0,0 +1,28 @@
+package db
+
+// GlobalHolidayChatID is the chat_id of holidays that apply to every chat.
+const GlobalHolidayChatID = 0
+
+// AddHoliday marks date (YYYY-MM-DD) as a day without invites in the chat, or in every chat
+// for GlobalHolidayChatID.
+func (s *Store) AddHoliday(chatID int64, date string) error {
+	_, err := s.DB.Exec("INSERT INTO holidays (chat_id, holiday_date) VALUES (?, ?) ON CONFLICT DO NOTHING", chatID, date)
+	return err
+}
+
+// RemoveHoliday drops a holiday added with AddHoliday and reports whether there was one.
+func (s *Store) RemoveHoliday(chatID int64, date string) (bool, error) {
+	res, err := s.DB.Exec("DELETE FROM holidays WHERE chat_id=? AND holiday_date=?", chatID, date)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// IsHoliday reports whether date is a holiday for the chat, its own or a global one.
+func (s *Store) IsHoliday(chatID int64, date string) (bool, error) {
+	var n int
+	err := s.DB.Get(&n, "SELECT COUNT(1) FROM holidays WHERE chat_id IN (?, ?) AND holiday_date=?", chatID, GlobalHolidayChatID, date)
+	return n > 0, err
+}
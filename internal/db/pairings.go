@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"coffeetrix24/internal/logic"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SavePublishedGroups records the groups published for the session, replacing an earlier record.
+func (s *Store) SavePublishedGroups(sessionID int64, groups []logic.Group) error {
+	return s.WithTx(context.Background(), func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec("DELETE FROM published_groups WHERE session_id=?", sessionID); err != nil {
+			return err
+		}
+		for i, g := range groups {
+			for _, u := range g.Members {
+				if _, err := tx.Exec("INSERT INTO published_groups (session_id, group_no, user_id) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+					sessionID, i, u.ID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// GetSessionGroups returns the groups published for the session in their published order, with
+// the members' names as they signed up. It returns nil when nothing was published.
+func (s *Store) GetSessionGroups(sessionID int64) ([][]Participant, error) {
+	rows, err := s.DB.Queryx(`SELECT g.group_no, g.user_id, COALESCE(p.username,''), COALESCE(p.display_name,'')
+		FROM published_groups g
+		LEFT JOIN participants p ON p.session_id=g.session_id AND p.user_id=g.user_id
+		WHERE g.session_id=?
+		ORDER BY g.group_no, p.id`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var groups [][]Participant
+	last := -1
+	for rows.Next() {
+		var no int
+		var p Participant
+		if err := rows.Scan(&no, &p.UserID, &p.Username, &p.DisplayName); err != nil {
+			return nil, err
+		}
+		if no != last {
+			groups = append(groups, nil)
+			last = no
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], p)
+	}
+	return groups, rows.Err()
+}
+
+// DiversityStats summarizes how many different people each participant met.
+type DiversityStats struct {
+	// Partners maps a user ID to the number of distinct people they were grouped with.
+	Partners map[int64]int
+}
+
+// Average is the mean number of distinct partners per participant.
+func (d DiversityStats) Average() float64 {
+	if len(d.Partners) == 0 {
+		return 0
+	}
+	total := 0
+	for _, n := range d.Partners {
+		total += n
+	}
+	return float64(total) / float64(len(d.Partners))
+}
+
+// Max is the largest number of distinct partners any participant had.
+func (d DiversityStats) Max() int {
+	m := 0
+	for _, n := range d.Partners {
+		if n > m {
+			m = n
+		}
+	}
+	return m
+}
+
+// PartnerDiversity counts distinct partners per user over the chat's published groups of sessions
+// dated on or after since. Test-mode fake participants are left out on both sides.
+func (s *Store) PartnerDiversity(chatID int64, since time.Time) (DiversityStats, error) {
+	rows, err := s.DB.Queryx(`SELECT a.user_id, COUNT(DISTINCT b.user_id)
+		FROM published_groups a
+		JOIN published_groups b ON b.session_id=a.session_id AND b.group_no=a.group_no AND b.user_id<>a.user_id
+		JOIN daily_sessions ds ON ds.id=a.session_id
+		WHERE ds.chat_id=? AND ds.session_date>=?
+			AND NOT (a.user_id > ? AND a.user_id < ?) AND NOT (b.user_id > ? AND b.user_id < ?)
+		GROUP BY a.user_id`, chatID, since.UTC().Format("2006-01-02"),
+		FakeUserIDBase, FakeUserIDBase+1000, FakeUserIDBase, FakeUserIDBase+1000)
+	if err != nil {
+		return DiversityStats{}, err
+	}
+	defer rows.Close()
+	st := DiversityStats{Partners: map[int64]int{}}
+	for rows.Next() {
+		var userID int64
+		var n int
+		if err := rows.Scan(&userID, &n); err != nil {
+			return DiversityStats{}, err
+		}
+		st.Partners[userID] = n
+	}
+	return st, rows.Err()
+}
+
+// GetRecentPairs returns every two users grouped together in the chat's published groups of
+// sessions from the last sinceDays days, each pair once.
+func (s *Store) GetRecentPairs(chatID int64, sinceDays int) ([]logic.Pair, error) {
+	since := time.Now().UTC().AddDate(0, 0, -sinceDays).Format("2006-01-02")
+	rows, err := s.DB.Queryx(`SELECT DISTINCT a.user_id, b.user_id
+		FROM published_groups a
+		JOIN published_groups b ON b.session_id=a.session_id AND b.group_no=a.group_no AND b.user_id>a.user_id
+		JOIN daily_sessions ds ON ds.id=a.session_id
+		WHERE ds.chat_id=? AND ds.session_date>=?`, chatID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var pairs []logic.Pair
+	for rows.Next() {
+		var p logic.Pair
+		if err := rows.Scan(&p.A, &p.B); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, rows.Err()
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/logic"
+)
+
+// publishTestGroups stores groups of user IDs as published for a new session.
+func publishTestGroups(t *testing.T, st *Store, chatID int64, date string, groups ...[]int64) {
+	t.Helper()
+	id := newTestSession(t, st, chatID, date)
+	var gs []logic.Group
+	for _, ids := range groups {
+		var g logic.Group
+		for _, uid := range ids {
+			g.Members = append(g.Members, logic.User{ID: uid})
+		}
+		gs = append(gs, g)
+	}
+	if err := st.SavePublishedGroups(id, gs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPartnerDiversity(t *testing.T) {
+	const chatID = -100
+	st := newTestStore(t)
+	since := time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC)
+	publishTestGroups(t, st, chatID, "2024-05-01", []int64{1, 2}, []int64{3, 4, 5})
+	// 2 and 4 meet again with a test-mode fake, 1 and 3 for the first time
+	publishTestGroups(t, st, chatID, "2024-05-02", []int64{1, 3}, []int64{2, 4, FakeUserIDBase + 1})
+	publishTestGroups(t, st, chatID, "2024-05-03", []int64{3, 4})
+	// before the window, and in another chat
+	publishTestGroups(t, st, chatID, "2024-04-01", []int64{1, 5})
+	publishTestGroups(t, st, -200, "2024-05-01", []int64{1, 6})
+
+	d, err := st.PartnerDiversity(chatID, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64]int{1: 2, 2: 2, 3: 3, 4: 3, 5: 2}
+	if !reflect.DeepEqual(d.Partners, want) {
+		t.Errorf("partners = %v, want %v", d.Partners, want)
+	}
+	if avg := d.Average(); avg != 2.4 {
+		t.Errorf("average = %v, want 2.4", avg)
+	}
+	if max := d.Max(); max != 3 {
+		t.Errorf("max = %d, want 3", max)
+	}
+
+	d, err = st.PartnerDiversity(-300, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Partners) != 0 || d.Average() != 0 || d.Max() != 0 {
+		t.Errorf("chat without groups: %+v, average %v, max %d; want zero", d, d.Average(), d.Max())
+	}
+}
+
+func TestGetRecentPairs(t *testing.T) {
+	const chatID = -100
+	st := newTestStore(t)
+	today := time.Now().UTC()
+	day := func(ago int) string { return today.AddDate(0, 0, -ago).Format("2006-01-02") }
+	publishTestGroups(t, st, chatID, day(1), []int64{1, 2, 3}, []int64{4, 5})
+	// the same pair twice is listed once
+	publishTestGroups(t, st, chatID, day(2), []int64{2, 1})
+	publishTestGroups(t, st, chatID, day(30), []int64{1, 4})
+	publishTestGroups(t, st, -200, day(1), []int64{1, 6})
+
+	pairs, err := st.GetRecentPairs(chatID, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[logic.Pair]bool{}
+	for _, p := range pairs {
+		if got[p] {
+			t.Errorf("pair %v listed twice", p)
+		}
+		got[p] = true
+	}
+	want := map[logic.Pair]bool{{A: 1, B: 2}: true, {A: 1, B: 3}: true, {A: 2, B: 3}: true, {A: 4, B: 5}: true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pairs = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"coffeetrix24/internal/logic"
+)
+
+// SavePendingGroups stores a tentative grouping for the session, replacing an earlier unconfirmed one.
+func (s *Store) SavePendingGroups(sessionID int64, groups []logic.Group) error {
+	data, err := json.Marshal(groups)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.Exec(`INSERT INTO pending_groups (session_id, groups_json) VALUES (?, ?)
+		ON CONFLICT(session_id) DO UPDATE SET groups_json=excluded.groups_json, confirmed=0, updated_at=CURRENT_TIMESTAMP`,
+		sessionID, string(data))
+	return err
+}
+
+// PendingGroups returns the stored grouping of the session; ok is false when there is none.
+func (s *Store) PendingGroups(sessionID int64) (groups []logic.Group, confirmed bool, ok bool, err error) {
+	var data string
+	err = s.DB.QueryRowx("SELECT groups_json, confirmed FROM pending_groups WHERE session_id=?", sessionID).Scan(&data, &confirmed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, false, nil
+	}
+	if err != nil {
+		return nil, false, false, err
+	}
+	if err := json.Unmarshal([]byte(data), &groups); err != nil {
+		return nil, false, false, err
+	}
+	return groups, confirmed, true, nil
+}
+
+// ConfirmPendingGroups locks the session's tentative grouping; it reports false when there is none.
+func (s *Store) ConfirmPendingGroups(sessionID int64) (bool, error) {
+	r, err := s.DB.Exec("UPDATE pending_groups SET confirmed=1, updated_at=CURRENT_TIMESTAMP WHERE session_id=?", sessionID)
+	if err != nil {
+		return false, err
+	}
+	n, err := r.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SessionsToRemind returns open sessions not reminded yet whose deadline is ahead of now by less
+// than the chat's remind_before_sec. Chats without a reminder offset are skipped.
+func (s *Store) SessionsToRemind(now time.Time) ([]int64, error) {
+	rows, err := s.DB.Queryx(`SELECT ds.id, ds.signup_deadline, cs.remind_before_sec
+		FROM daily_sessions ds JOIN chat_settings cs ON cs.chat_id=ds.chat_id
+		WHERE ds.closed=0 AND ds.closing=0 AND ds.reminded=0 AND ds.invite_message_id IS NOT NULL
+			AND cs.remind_before_sec > 0 AND ds.signup_deadline > ? AND (?=0 OR ds.chat_id=?)`,
+		now.UTC(), s.SandboxChatID, s.SandboxChatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id, beforeSec int64
+		var deadline time.Time
+		if err := rows.Scan(&id, &deadline, &beforeSec); err != nil {
+			return nil, err
+		}
+		if deadline.Sub(now) <= time.Duration(beforeSec)*time.Second {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// MarkReminded records that the session's reminder went out. It returns true only for the single
+// caller that marked it, so a reminder is posted once.
+func (s *Store) MarkReminded(id int64) (bool, error) {
+	res, err := s.DB.Exec("UPDATE daily_sessions SET reminded=1 WHERE id=? AND reminded=0", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// SessionDeadline returns the session's signup deadline; ok is false when it has none.
+func (s *Store) SessionDeadline(id int64) (deadline time.Time, ok bool, err error) {
+	var d sql.NullTime
+	if err := s.DB.Get(&d, "SELECT signup_deadline FROM daily_sessions WHERE id=?", id); err != nil {
+		return time.Time{}, false, err
+	}
+	return d.Time, d.Valid, nil
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// RepairReport lists inconsistencies found by RepairSessions.
+type RepairReport struct {
+	// ClosedFutureDeadline are closed sessions whose deadline has not passed yet. This is expected
+	// after /confirm published early, so they are only reported, never changed.
+	ClosedFutureDeadline []int64
+	// StaleNoInvite are open sessions of past dates whose invite was never sent.
+	StaleNoInvite []int64
+	// Orphans counts rows per table that reference a session which no longer exists.
+	Orphans map[string]int
+	// Repaired is set when the fixes were applied.
+	Repaired bool
+}
+
+// sessionChildTables are the tables keyed by session_id, checked for orphans.
+var sessionChildTables = []string{"participants", "feedback", "pending_groups", "published_groups", "extension_requests", "late_taps"}
+
+// RepairSessions finds inconsistent session state as of now. With apply set it also fixes what is
+// safe to fix, in one transaction: stale sessions without an invite are abandoned and orphan rows deleted.
+func (s *Store) RepairSessions(ctx context.Context, now time.Time, apply bool) (RepairReport, error) {
+	rep := RepairReport{Orphans: map[string]int{}}
+	err := s.WithTx(ctx, func(tx *sqlx.Tx) error {
+		if err := tx.Select(&rep.ClosedFutureDeadline, "SELECT id FROM daily_sessions WHERE closed=1 AND signup_deadline > ? ORDER BY id", now.UTC()); err != nil {
+			return err
+		}
+		if err := tx.Select(&rep.StaleNoInvite, `SELECT id FROM daily_sessions
+			WHERE closed=0 AND invite_message_id IS NULL AND session_date < ? ORDER BY id`, now.UTC().Format("2006-01-02")); err != nil {
+			return err
+		}
+		for _, t := range sessionChildTables {
+			var n int
+			if err := tx.Get(&n, "SELECT COUNT(1) FROM "+t+" WHERE session_id NOT IN (SELECT id FROM daily_sessions)"); err != nil {
+				return err
+			}
+			if n > 0 {
+				rep.Orphans[t] = n
+			}
+		}
+		if !apply {
+			return nil
+		}
+		for _, id := range rep.StaleNoInvite {
+			if _, err := tx.Exec("UPDATE daily_sessions SET closed=1, closing=0, abandoned=1 WHERE id=?", id); err != nil {
+				return err
+			}
+		}
+		for t := range rep.Orphans {
+			if _, err := tx.Exec("DELETE FROM " + t + " WHERE session_id NOT IN (SELECT id FROM daily_sessions)"); err != nil {
+				return err
+			}
+		}
+		rep.Repaired = true
+		return nil
+	})
+	return rep, err
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRepairSessions(t *testing.T) {
+	st := newTestStore(t)
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	if err := st.UpsertChat(-100, "test"); err != nil {
+		t.Fatal(err)
+	}
+	session := func(date string, slot int, deadline time.Time) int64 {
+		t.Helper()
+		id, err := st.CreateOrGetTodaySession(-100, date, slot, deadline)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	exec := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := st.DB.Exec(query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// healthy: today's open session with its invite, and a past one published with its invite
+	healthy := session("2024-05-10", 0, now.Add(time.Hour))
+	if err := st.SetInviteMessageID(healthy, 10); err != nil {
+		t.Fatal(err)
+	}
+	published := session("2024-05-08", 0, now.Add(-48*time.Hour))
+	if err := st.SetInviteMessageID(published, 11); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.CloseSession(published); err != nil {
+		t.Fatal(err)
+	}
+	// today's session without an invite yet is not stale
+	session("2024-05-10", 1, now.Add(2*time.Hour))
+
+	// corrupted: closed ahead of its deadline, and a past session whose invite never went out
+	early := session("2024-05-11", 0, now.Add(24*time.Hour))
+	if err := st.CloseSession(early); err != nil {
+		t.Fatal(err)
+	}
+	stale := session("2024-05-09", 0, now.Add(-24*time.Hour))
+	// rows left behind by a session that no longer exists
+	const gone = 999
+	exec("INSERT INTO participants(session_id, user_id, display_name) VALUES (?, 1, 'A'), (?, 2, 'B')", gone, gone)
+	exec("INSERT INTO feedback(session_id, user_id, met) VALUES (?, 1, 1)", gone)
+	exec("INSERT INTO late_taps(session_id, user_id) VALUES (?, 3)", gone)
+	if err := st.AddParticipant(healthy, 1, "", "A"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := RepairReport{
+		ClosedFutureDeadline: []int64{early},
+		StaleNoInvite:        []int64{stale},
+		Orphans:              map[string]int{"participants": 2, "feedback": 1, "late_taps": 1},
+	}
+	// read-only by default: the same report twice, nothing changed
+	for i := 0; i < 2; i++ {
+		rep, err := st.RepairSessions(context.Background(), now, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(rep, want) {
+			t.Fatalf("check %d: report = %+v, want %+v", i+1, rep, want)
+		}
+	}
+
+	want.Repaired = true
+	rep, err := st.RepairSessions(context.Background(), now, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rep, want) {
+		t.Fatalf("repair report = %+v, want %+v", rep, want)
+	}
+	var abandoned bool
+	if err := st.DB.Get(&abandoned, "SELECT closed=1 AND abandoned=1 FROM daily_sessions WHERE id=?", stale); err != nil || !abandoned {
+		t.Errorf("stale session abandoned = %v, %v; want true", abandoned, err)
+	}
+	if closed, err := st.IsSessionClosed(healthy); err != nil || closed {
+		t.Errorf("healthy session closed = %v, %v; want open", closed, err)
+	}
+	if ok, err := st.IsParticipant(healthy, 1); err != nil || !ok {
+		t.Errorf("healthy participant kept = %v, %v; want true", ok, err)
+	}
+
+	// only the early close, which is never changed, is left
+	rep, err = st.RepairSessions(context.Background(), now, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = RepairReport{ClosedFutureDeadline: []int64{early}, Orphans: map[string]int{}}
+	if !reflect.DeepEqual(rep, want) {
+		t.Errorf("report after repair = %+v, want %+v", rep, want)
+	}
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// AnonymizedName replaces the display name of anonymized participants.
+const AnonymizedName = "участник"
+
+// AnonymizeOldParticipants scrubs usernames and display names from participants of sessions dated
+// before the cutoff and forgets directory entries not refreshed since then. User IDs stay, so
+// counts, feedback and pairing history keep working. It returns how many records were anonymized.
+func (s *Store) AnonymizeOldParticipants(before time.Time) (int, error) {
+	var total int64
+	err := s.WithTx(context.Background(), func(tx *sqlx.Tx) error {
+		res, err := tx.Exec(`UPDATE participants SET username=NULL, display_name=?, anonymized=1
+			WHERE anonymized=0 AND session_id IN (SELECT id FROM daily_sessions WHERE session_date < ?)`,
+			AnonymizedName, before.UTC().Format("2006-01-02"))
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		total += n
+		res, err = tx.Exec("DELETE FROM user_directory WHERE updated_at < ?", before.UTC())
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		total += n
+		return err
+	})
+	return int(total), err
+}
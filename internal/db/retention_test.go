@@ -0,0 +1,116 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/logic"
+)
+
+func TestAnonymizeOldParticipants(t *testing.T) {
+	const chatID = -100
+	st := newTestStore(t)
+	cutoff := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	old := newTestSession(t, st, chatID, "2024-05-01")
+	recent := newTestSession(t, st, chatID, "2024-05-20")
+	joins := []struct {
+		session  int64
+		user     int64
+		username string
+		name     string
+	}{
+		{old, 1, "anna", "Anna"},
+		{old, 2, "boris", "Boris"},
+		{old, 3, "", "Vera"},
+		{recent, 1, "anna", "Anna"},
+	}
+	for _, j := range joins {
+		if err := st.AddParticipant(j.session, j.user, j.username, j.name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.SavePublishedGroups(old, []logic.Group{{Members: []logic.User{{ID: 1}, {ID: 2}, {ID: 3}}}}); err != nil {
+		t.Fatal(err)
+	}
+	// anna was seen lately, boris only before the cutoff
+	for _, u := range []struct {
+		id             int64
+		username, name string
+	}{{1, "anna", "Anna"}, {2, "boris", "Boris"}} {
+		if err := st.RememberUser(u.id, u.username, u.name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.DB.Exec("UPDATE user_directory SET updated_at=? WHERE user_id=2", cutoff.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	statsBefore, err := st.ChatStats(chatID, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := st.AnonymizeOldParticipants(cutoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// three participant rows and one directory entry
+	if n != 4 {
+		t.Errorf("anonymized = %d, want 4", n)
+	}
+
+	// names and usernames of the old session are gone, user IDs are kept
+	parts, err := st.GetParticipants(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Participant{{UserID: 1, DisplayName: AnonymizedName}, {UserID: 2, DisplayName: AnonymizedName}, {UserID: 3, DisplayName: AnonymizedName}}
+	if !reflect.DeepEqual(parts, want) {
+		t.Errorf("old participants = %+v, want %+v", parts, want)
+	}
+	for _, name := range []string{"boris", "Boris", "Vera"} {
+		var left int
+		if err := st.DB.Get(&left, `SELECT
+			(SELECT COUNT(1) FROM participants WHERE username=? OR display_name=?) +
+			(SELECT COUNT(1) FROM user_directory WHERE username=? OR display_name=?)`, name, name, name, name); err != nil {
+			t.Fatal(err)
+		}
+		if left != 0 {
+			t.Errorf("%q is still stored in %d rows", name, left)
+		}
+	}
+	if _, ok, err := st.LookupUsername("boris"); err != nil || ok {
+		t.Errorf("stale directory entry found = %v, %v; want forgotten", ok, err)
+	}
+	// recent data is untouched
+	parts, err = st.GetParticipants(recent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Participant{{UserID: 1, Username: "anna", DisplayName: "Anna"}}; !reflect.DeepEqual(parts, want) {
+		t.Errorf("recent participants = %+v, want %+v", parts, want)
+	}
+	if p, ok, err := st.LookupUsername("anna"); err != nil || !ok || p.UserID != 1 {
+		t.Errorf("recent directory entry = %+v, %v, %v; want kept", p, ok, err)
+	}
+
+	// counts and pairing history survive
+	statsAfter, err := st.ChatStats(chatID, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statsAfter != statsBefore {
+		t.Errorf("stats = %+v, want %+v as before", statsAfter, statsBefore)
+	}
+	groups, err := st.GetSessionGroups(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0]) != 3 || groups[0][1].UserID != 2 || groups[0][1].DisplayName != AnonymizedName {
+		t.Errorf("old groups = %+v, want the three members anonymized", groups)
+	}
+
+	if n, err := st.AnonymizeOldParticipants(cutoff); err != nil || n != 0 {
+		t.Errorf("second run anonymized %d, %v; want 0", n, err)
+	}
+}
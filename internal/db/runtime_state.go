@@ -0,0 +1,74 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	keyUpdateOffset = "update_offset"
+	keyDailyRun     = "daily_last_run"
+)
+
+func (s *Store) getState(key string) (string, bool, error) {
+	var v string
+	err := s.DB.Get(&v, "SELECT value FROM runtime_state WHERE key=?", key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return v, true, nil
+}
+
+func (s *Store) setState(key, value string) error {
+	_, err := s.DB.Exec("INSERT INTO runtime_state (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value=excluded.value, updated_at=CURRENT_TIMESTAMP", key, value)
+	return err
+}
+
+// GetUpdateOffset returns the next Telegram update_id to request (0 if never stored).
+func (s *Store) GetUpdateOffset() (int, error) {
+	v, ok, err := s.getState(keyUpdateOffset)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.Atoi(v)
+}
+
+// SetUpdateOffset persists the offset after an update has been processed.
+func (s *Store) SetUpdateOffset(offset int) error {
+	return s.setState(keyUpdateOffset, strconv.Itoa(offset))
+}
+
+// DailyRun is the outcome of the last SendDailyInvites run.
+type DailyRun struct {
+	At      time.Time     `json:"at"`
+	Elapsed time.Duration `json:"elapsed"`
+	Chats   int           `json:"chats"`
+	Sent    int           `json:"sent"`
+	Skipped int           `json:"skipped"`
+	Failed  int           `json:"failed"`
+}
+
+// SetDailyRun stores the outcome of a daily run, replacing the previous one.
+func (s *Store) SetDailyRun(run DailyRun) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	return s.setState(keyDailyRun, string(data))
+}
+
+// GetDailyRun returns the last daily run; ok is false if none was recorded yet.
+func (s *Store) GetDailyRun() (run DailyRun, ok bool, err error) {
+	v, ok, err := s.getState(keyDailyRun)
+	if err != nil || !ok {
+		return run, false, err
+	}
+	err = json.Unmarshal([]byte(v), &run)
+	return run, err == nil, err
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDailyRun(t *testing.T) {
+	st := newTestStore(t)
+	if run, ok, err := st.GetDailyRun(); err != nil || ok {
+		t.Fatalf("before any run: %+v, %v, %v; want none", run, ok, err)
+	}
+
+	at := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	runs := []DailyRun{
+		{At: at, Elapsed: 1500 * time.Millisecond, Chats: 5, Sent: 3, Skipped: 1, Failed: 1},
+		// the next run replaces the previous one
+		{At: at.Add(24 * time.Hour), Elapsed: time.Second, Chats: 5, Sent: 5},
+	}
+	for _, want := range runs {
+		if err := st.SetDailyRun(want); err != nil {
+			t.Fatal(err)
+		}
+		got, ok, err := st.GetDailyRun()
+		if err != nil || !ok {
+			t.Fatalf("get: %v, %v", ok, err)
+		}
+		if !got.At.Equal(want.At) {
+			t.Errorf("at = %s, want %s", got.At, want.At)
+		}
+		got.At = want.At
+		if got != want {
+			t.Errorf("run = %+v, want %+v", got, want)
+		}
+	}
+
+	// the update offset is kept apart
+	if err := st.SetUpdateOffset(42); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := st.GetDailyRun(); err != nil || got.Sent != 5 {
+		t.Errorf("run after storing the offset = %+v, %v", got, err)
+	}
+	if offset, err := st.GetUpdateOffset(); err != nil || offset != 42 {
+		t.Errorf("offset = %d, %v; want 42", offset, err)
+	}
+}
+
+func TestDailyRunCorrupted(t *testing.T) {
+	st := newTestStore(t)
+	if err := st.setState(keyDailyRun, "{"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := st.GetDailyRun(); err == nil || ok {
+		t.Errorf("corrupted run: ok = %v, err = %v; want an error", ok, err)
+	}
+}
@@ -14,7 +14,10 @@ CREATE TABLE IF NOT EXISTS settings (
 CREATE TABLE IF NOT EXISTS chats (
     chat_id INTEGER PRIMARY KEY,
     title TEXT,
-    joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    empty_streak INTEGER NOT NULL DEFAULT 0, -- сколько сессий подряд никто не записался
+    active INTEGER NOT NULL DEFAULT 1, -- 0: бота удалили из чата, приглашения не отправляются
+    paused INTEGER NOT NULL DEFAULT 0 -- 1: админ приостановил приглашения (/pause)
 );
 
 -- Сессии дневных наборов участников (по чату и дате)
@@ -22,11 +25,19 @@ CREATE TABLE IF NOT EXISTS daily_sessions (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     chat_id INTEGER NOT NULL,
     session_date TEXT NOT NULL, -- YYYY-MM-DD
+    slot INTEGER NOT NULL DEFAULT 0, -- номер времени рассылки за день (0 — первое)
     invite_message_id INTEGER,  -- message id приглашения
     signup_deadline TIMESTAMP,  -- крайний срок набора (плюс 30 минут)
     closed INTEGER NOT NULL DEFAULT 0,
+    closing INTEGER NOT NULL DEFAULT 0, -- 1, пока кто-то публикует итоги
+    closing_at TIMESTAMP, -- когда взята блокировка closing; устаревшую может перехватить другой процесс
+    invite_uneditable INTEGER NOT NULL DEFAULT 0, -- 1: Telegram больше не даёт редактировать приглашение
+    abandoned INTEGER NOT NULL DEFAULT 0, -- 1: закрыта без публикации, т.к. устарела
+    extended INTEGER NOT NULL DEFAULT 0, -- 1: набор уже продлевали по просьбе участников
+    reminded INTEGER NOT NULL DEFAULT 0, -- 1: напоминание о скором конце набора уже отправлено
+    result_message_id INTEGER,  -- message id опубликованных итогов
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    UNIQUE(chat_id, session_date)
+    UNIQUE(chat_id, session_date, slot)
 );
 
 -- Участники текущего набора
@@ -37,5 +48,141 @@ CREATE TABLE IF NOT EXISTS participants (
     username TEXT,
     display_name TEXT,
     joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    anonymized INTEGER NOT NULL DEFAULT 0, -- 1: имя и username удалены по сроку хранения
     UNIQUE(session_id, user_id)
 );
+
+-- Переопределения настроек для отдельных чатов (NULL — глобальное значение)
+CREATE TABLE IF NOT EXISTS chat_settings (
+    chat_id INTEGER PRIMARY KEY,
+    signup_window_sec INTEGER, -- длительность окна набора, секунды
+    verify_members INTEGER,    -- 1: перед итогами исключать вышедших из чата
+    invite_media TEXT,         -- photo:<file_id> | sticker:<file_id> к приглашению
+    grace_period_sec INTEGER,  -- сколько ещё принимать записи после дедлайна, секунды
+    lapsed_mention_limit INTEGER, -- сколько «пропавших» участников упоминать в приглашении
+    lapsed_after_sessions INTEGER, -- после скольких пропущенных сессий участник считается пропавшим
+    label_single_group INTEGER,    -- 1: подписывать «Группа 1», даже если группа одна
+    results_visibility TEXT,       -- public | private | both
+    group_labels TEXT,             -- numeric | alpha | emoji:<e1>,<e2>,...
+    empty_streak_nudge INTEGER,    -- после скольких пустых сессий подряд предложить паузу (0 — никогда)
+    results_summary_threshold INTEGER, -- больше стольких участников — итоги сводкой и файлом (0 — всегда полностью)
+    pin_invite INTEGER,            -- 1: закреплять приглашение на время набора
+    noshow_threshold INTEGER,      -- участники, встречающиеся реже стольких процентов, не упоминаются (0 — выкл)
+    extend_votes INTEGER,          -- сколько участников должны попросить продлить набор (0 — кнопки нет)
+    extend_by_sec INTEGER,         -- на сколько продлевать набор по просьбе участников
+    min_group_size INTEGER,        -- минимальный размер группы для публикации
+    small_group_policy TEXT,       -- publish | merge | cancel — что делать с группами меньше min_group_size
+    max_group_size INTEGER,        -- жёсткий максимум размера группы (NULL — обычные группы по 2–3)
+    invite_emoji TEXT,             -- эмодзи на кнопке «Я участвую» и в ответе на запись
+    pair_only_policy TEXT,         -- publish | cancel | carry — что делать, если записались ровно двое
+    team_tags INTEGER,             -- 1: указывать в итогах команду участника из user_directory
+    late_note INTEGER,             -- 1: объяснять опоздавшим, почему встреча не состоялась
+    auto_extend_joins INTEGER,     -- столько записей в последние минуты набора продлевают его (0 — выкл)
+    auto_extend_window_sec INTEGER, -- за какой период до дедлайна считать эти записи, секунды
+    allow_leave INTEGER,           -- 0: записавшиеся не могут отписаться через /leave
+    leave_ack TEXT,                -- свой текст ответа на /leave
+    avoid_repeat_days INTEGER,     -- за сколько дней не сводить повторно тех, кто уже встречался (0 — выкл)
+    group_size INTEGER,            -- желаемый размер группы, остаток распределяется по группам (NULL — по 2–3)
+    balance_groups INTEGER,        -- 1: число групп ближе всего к желаемому размеру, участники раздаются по кругу
+    remind_before_sec INTEGER,     -- за сколько секунд до конца набора напомнить о нём (NULL/0 — не напоминать)
+    pin_results INTEGER,           -- 1: закреплять итоги до следующих итогов
+    invite_days INTEGER,           -- битовая маска дней недели с приглашениями, бит 0 — воскресенье (NULL — каждый день)
+    timezone TEXT,                 -- часовой пояс IANA (например Europe/Moscow): время приглашения и даты сессий — местные (NULL — UTC)
+    invite_cooldown_sec INTEGER    -- не отправлять новое приглашение раньше, чем через столько секунд после предыдущего (0 — без ограничения)
+);
+
+-- Служебное состояние процесса (ключ-значение), например offset long polling
+CREATE TABLE IF NOT EXISTS runtime_state (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Журнал действий владельца/администраторов
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor_id INTEGER NOT NULL,
+    chat_id INTEGER,
+    action TEXT NOT NULL,
+    details TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Отзывы участников: состоялась ли встреча
+CREATE TABLE IF NOT EXISTS feedback (
+    session_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    met INTEGER NOT NULL, -- 1: встретились, 0: не получилось
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, user_id)
+);
+
+-- Организаторы: записываются, но не попадают в группы
+CREATE TABLE IF NOT EXISTS facilitators (
+    chat_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (chat_id, user_id)
+);
+
+-- Предварительная разбивка на группы, которую админ смотрит до публикации
+CREATE TABLE IF NOT EXISTS pending_groups (
+    session_id INTEGER PRIMARY KEY,
+    groups_json TEXT NOT NULL,       -- [[{"ID":..,"Name":..},...],...]
+    confirmed INTEGER NOT NULL DEFAULT 0, -- 1: подтверждена, публикуется как есть
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Известные боту пользователи: позволяет находить user_id по @username
+CREATE TABLE IF NOT EXISTS user_directory (
+    user_id INTEGER PRIMARY KEY,
+    username TEXT,
+    display_name TEXT,
+    team TEXT, -- команда/отдел, который пользователь указал сам через /team
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Просьбы участников продлить набор
+CREATE TABLE IF NOT EXISTS extension_requests (
+    session_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (session_id, user_id)
+);
+
+-- Опубликованные группы: история, кто с кем встречался
+CREATE TABLE IF NOT EXISTS published_groups (
+    session_id INTEGER NOT NULL,
+    group_no INTEGER NOT NULL, -- номер группы в итогах, с 0
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (session_id, user_id)
+);
+
+-- Участники отменённого набора, которые переносятся в следующую сессию чата
+CREATE TABLE IF NOT EXISTS carried_participants (
+    chat_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    username TEXT,
+    display_name TEXT,
+    PRIMARY KEY (chat_id, user_id)
+);
+
+-- Нажатия «Я участвую» после окончания набора
+CREATE TABLE IF NOT EXISTS late_taps (
+    session_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (session_id, user_id)
+);
+
+-- Праздничные дни без приглашений; chat_id 0 — для всех чатов
+CREATE TABLE IF NOT EXISTS holidays (
+    chat_id INTEGER NOT NULL,
+    holiday_date TEXT NOT NULL, -- YYYY-MM-DD, по местной дате чата
+    PRIMARY KEY (chat_id, holiday_date)
+);
+
+-- Отказ участника от встреч в этот день (/skip)
+CREATE TABLE IF NOT EXISTS skips (
+    chat_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    skip_date TEXT NOT NULL, -- YYYY-MM-DD, по местной дате чата
+    PRIMARY KEY (chat_id, user_id, skip_date)
+);
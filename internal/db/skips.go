@@ -0,0 +1,24 @@
+package db
+
+// AddSkip records that the user opted out of the chat's rounds on date (YYYY-MM-DD).
+func (s *Store) AddSkip(chatID, userID int64, date string) error {
+	_, err := s.DB.Exec("INSERT INTO skips (chat_id, user_id, skip_date) VALUES (?, ?, ?) ON CONFLICT DO NOTHING", chatID, userID, date)
+	return err
+}
+
+// RemoveSkip drops an opt-out added with AddSkip and reports whether there was one.
+func (s *Store) RemoveSkip(chatID, userID int64, date string) (bool, error) {
+	res, err := s.DB.Exec("DELETE FROM skips WHERE chat_id=? AND user_id=? AND skip_date=?", chatID, userID, date)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// IsSkipped reports whether the user opted out of the chat's rounds on date.
+func (s *Store) IsSkipped(chatID, userID int64, date string) (bool, error) {
+	var n int
+	err := s.DB.Get(&n, "SELECT COUNT(1) FROM skips WHERE chat_id=? AND user_id=? AND skip_date=?", chatID, userID, date)
+	return n > 0, err
+}
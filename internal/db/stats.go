@@ -0,0 +1,79 @@
+package db
+
+import "time"
+
+// ChatStats summarizes participation in a chat for /stats.
+type ChatStats struct {
+	// Sessions counts sessions held: closed with at least one participant and not abandoned.
+	Sessions int
+	// Participants counts distinct real users who ever signed up.
+	Participants int
+	// RecentSessions and RecentGroups cover sessions with published groups since the cutoff.
+	RecentSessions int
+	RecentGroups   int
+}
+
+// AverageGroups is the mean number of groups per recent session.
+func (c ChatStats) AverageGroups() float64 {
+	if c.RecentSessions == 0 {
+		return 0
+	}
+	return float64(c.RecentGroups) / float64(c.RecentSessions)
+}
+
+// ChatStats counts the chat's sessions and participants, and its groups per session dated on or
+// after since.
+func (s *Store) ChatStats(chatID int64, since time.Time) (ChatStats, error) {
+	var st ChatStats
+	err := s.DB.QueryRowx(`SELECT
+		(SELECT COUNT(1) FROM daily_sessions ds WHERE ds.chat_id=? AND ds.closed=1 AND ds.abandoned=0
+			AND EXISTS (SELECT 1 FROM participants p WHERE p.session_id=ds.id)),
+		(SELECT COUNT(DISTINCT p.user_id) FROM participants p JOIN daily_sessions ds ON ds.id=p.session_id
+			WHERE ds.chat_id=? AND NOT (p.user_id > ? AND p.user_id < ?))`,
+		chatID, chatID, FakeUserIDBase, FakeUserIDBase+1000).Scan(&st.Sessions, &st.Participants)
+	if err != nil {
+		return st, err
+	}
+	err = s.DB.QueryRowx(`SELECT COUNT(1), COALESCE(SUM(g.groups), 0) FROM (
+			SELECT COUNT(DISTINCT pg.group_no) AS groups FROM published_groups pg
+			JOIN daily_sessions ds ON ds.id=pg.session_id
+			WHERE ds.chat_id=? AND ds.session_date>=? GROUP BY pg.session_id) g`,
+		chatID, since.UTC().Format("2006-01-02")).Scan(&st.RecentSessions, &st.RecentGroups)
+	return st, err
+}
+
+// LeaderboardEntry is a user's participation count in a chat.
+type LeaderboardEntry struct {
+	UserID   int64
+	Name     string
+	Sessions int
+}
+
+// Leaderboard returns up to limit real users of the chat by sessions joined, most first; ties go to
+// whoever joined more recently. Names come from the user directory, else from the user's latest
+// non-anonymized signup.
+func (s *Store) Leaderboard(chatID int64, limit int) ([]LeaderboardEntry, error) {
+	rows, err := s.DB.Queryx(`SELECT p.user_id, COUNT(DISTINCT p.session_id) AS n,
+			COALESCE(NULLIF(ud.display_name, ''),
+				(SELECT p2.display_name FROM participants p2 WHERE p2.user_id=p.user_id AND p2.anonymized=0
+					AND COALESCE(p2.display_name, '')<>'' ORDER BY p2.id DESC LIMIT 1), ?)
+		FROM participants p JOIN daily_sessions ds ON ds.id=p.session_id
+		LEFT JOIN user_directory ud ON ud.user_id=p.user_id
+		WHERE ds.chat_id=? AND NOT (p.user_id > ? AND p.user_id < ?)
+		GROUP BY p.user_id
+		ORDER BY n DESC, MAX(ds.session_date) DESC, MAX(ds.id) DESC
+		LIMIT ?`, AnonymizedName, chatID, FakeUserIDBase, FakeUserIDBase+1000, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []LeaderboardEntry
+	for rows.Next() {
+		var e LeaderboardEntry
+		if err := rows.Scan(&e.UserID, &e.Sessions, &e.Name); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// RememberUser records the current username and display name of a user. A username can move
+// between accounts, so it is cleared from any other user holding it.
+func (s *Store) RememberUser(userID int64, username, display string) error {
+	return s.WithTx(context.Background(), func(tx *sqlx.Tx) error {
+		if username != "" {
+			if _, err := tx.Exec("UPDATE user_directory SET username=NULL WHERE lower(username)=lower(?) AND user_id<>?", username, userID); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`INSERT INTO user_directory (user_id, username, display_name) VALUES (?, NULLIF(?, ''), ?)
+			ON CONFLICT(user_id) DO UPDATE SET username=excluded.username, display_name=excluded.display_name, updated_at=CURRENT_TIMESTAMP
+			WHERE username IS NOT excluded.username OR display_name IS NOT excluded.display_name`, userID, username, display)
+		return err
+	})
+}
+
+// LookupUsername finds a user by @username (case-insensitive, with or without "@").
+// ok is false when the bot has not seen the username.
+func (s *Store) LookupUsername(username string) (p Participant, ok bool, err error) {
+	username = strings.TrimPrefix(username, "@")
+	var name, display sql.NullString
+	err = s.DB.QueryRowx("SELECT user_id, username, display_name FROM user_directory WHERE lower(username)=lower(?)", username).
+		Scan(&p.UserID, &name, &display)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, false, nil
+	}
+	if err != nil {
+		return p, false, err
+	}
+	p.Username, p.DisplayName = name.String, display.String
+	return p, true, nil
+}
+
+// SetTeam stores the team a user names for themselves; an empty team clears it.
+func (s *Store) SetTeam(userID int64, team string) error {
+	_, err := s.DB.Exec(`INSERT INTO user_directory (user_id, team) VALUES (?, NULLIF(?, ''))
+		ON CONFLICT(user_id) DO UPDATE SET team=excluded.team, updated_at=CURRENT_TIMESTAMP`, userID, team)
+	return err
+}
+
+// Teams returns the known teams of the given users. Users without a team are absent.
+func (s *Store) Teams(userIDs []int64) (map[int64]string, error) {
+	teams := map[int64]string{}
+	if len(userIDs) == 0 {
+		return teams, nil
+	}
+	q, args, err := sqlx.In("SELECT user_id, team FROM user_directory WHERE team IS NOT NULL AND user_id IN (?)", userIDs)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Queryx(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var team string
+		if err := rows.Scan(&id, &team); err != nil {
+			return nil, err
+		}
+		teams[id] = team
+	}
+	return teams, rows.Err()
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUserDirectory(t *testing.T) {
+	st := newTestStore(t)
+	lookup := func(username string) (Participant, bool) {
+		t.Helper()
+		p, ok, err := st.LookupUsername(username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p, ok
+	}
+	steps := []struct {
+		name              string
+		user              int64
+		username, display string
+	}{
+		{"first seen", 1, "Ann", "Ann A"},
+		{"renamed", 1, "Ann", "Ann B"},
+		{"without username", 2, "", "Bob"},
+		// the username moved to another account
+		{"username taken over", 3, "ann", "New Ann"},
+	}
+	for _, s := range steps {
+		if err := st.RememberUser(s.user, s.username, s.display); err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+	}
+
+	for _, q := range []string{"ann", "@ANN", "Ann"} {
+		if p, ok := lookup(q); !ok || !reflect.DeepEqual(p, Participant{UserID: 3, Username: "ann", DisplayName: "New Ann"}) {
+			t.Errorf("lookup %q = %+v, %v; want user 3", q, p, ok)
+		}
+	}
+	if p, ok := lookup("bob"); ok {
+		t.Errorf("lookup of a user without username = %+v", p)
+	}
+	if p, ok := lookup("@nobody"); ok {
+		t.Errorf("lookup of an unknown username = %+v", p)
+	}
+	var left []string
+	if err := st.DB.Select(&left, "SELECT COALESCE(username,'') || '/' || display_name FROM user_directory WHERE user_id=1"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/Ann B"}; !reflect.DeepEqual(left, want) {
+		t.Errorf("previous holder = %v, want %v", left, want)
+	}
+}
+
+func TestUserDirectoryTeams(t *testing.T) {
+	st := newTestStore(t)
+	for _, step := range []func() error{
+		func() error { return st.SetTeam(1, "Платформа") },
+		// seeing the user again keeps their team
+		func() error { return st.RememberUser(1, "ann", "Ann") },
+		func() error { return st.RememberUser(2, "bob", "Bob") },
+		func() error { return st.SetTeam(2, "Маркетинг") },
+		func() error { return st.SetTeam(2, "") },
+		func() error { return st.SetTeam(3, "Продажи") },
+	} {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := st.Teams([]int64{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int64]string{1: "Платформа", 3: "Продажи"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("teams = %v, want %v", got, want)
+	}
+	if got, err := st.Teams(nil); err != nil || len(got) != 0 {
+		t.Errorf("teams of nobody = %v, %v", got, err)
+	}
+	// a team set before the user was seen does not hide them from lookups later
+	if err := st.RememberUser(3, "cid", "Cid"); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok, err := st.LookupUsername("cid"); err != nil || !ok || p.UserID != 3 {
+		t.Errorf("lookup = %+v, %v, %v; want user 3", p, ok, err)
+	}
+}
@@ -0,0 +1,48 @@
+// Package ical renders iCalendar (RFC 5545) feeds.
+package ical
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Event is a single calendar entry. Times are written in UTC.
+type Event struct {
+	UID     string
+	Start   time.Time
+	End     time.Time
+	Summary string
+}
+
+const stampLayout = "20060102T150405Z"
+
+// Feed renders a calendar named name with the events. now is used as every event's DTSTAMP.
+func Feed(name string, events []Event, now time.Time) string {
+	var sb strings.Builder
+	line := func(format string, args ...interface{}) {
+		sb.WriteString(fmt.Sprintf(format, args...))
+		sb.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//coffeetrix24//RU")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:%s", escape(name))
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:%s", e.UID)
+		line("DTSTAMP:%s", now.UTC().Format(stampLayout))
+		line("DTSTART:%s", e.Start.UTC().Format(stampLayout))
+		line("DTEND:%s", e.End.UTC().Format(stampLayout))
+		line("SUMMARY:%s", escape(e.Summary))
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return sb.String()
+}
+
+// escape quotes the characters that are special in iCalendar text values.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
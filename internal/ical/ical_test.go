@@ -0,0 +1,34 @@
+package ical
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFeed(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, msk)
+	events := []Event{
+		{UID: "1@test", Start: start, End: start.Add(30 * time.Minute), Summary: "Coffee ☕"},
+		{UID: "2@test", Start: start.AddDate(0, 0, 1), End: start.AddDate(0, 0, 1).Add(time.Hour), Summary: "a; b, c\\d\nnext"},
+	}
+	got := Feed("Team, coffee", events, time.Date(2024, 5, 5, 12, 30, 0, 0, time.UTC))
+
+	want, err := os.ReadFile(filepath.Join("testdata", "feed.ics"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("feed =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFeedEmpty(t *testing.T) {
+	got := Feed("Coffee", nil, time.Now())
+	want := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//coffeetrix24//RU\r\nCALSCALE:GREGORIAN\r\nX-WR-CALNAME:Coffee\r\nEND:VCALENDAR\r\n"
+	if got != want {
+		t.Errorf("feed = %q, want %q", got, want)
+	}
+}
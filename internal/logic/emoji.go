@@ -0,0 +1,74 @@
+package logic
+
+import "unicode"
+
+// IsSingleEmoji reports whether s is exactly one emoji: a pictograph with optional variation
+// selector, skin tone or keycap, ZWJ sequences of those, a flag of two regional indicators, or a
+// subdivision flag written with tag characters. Plain text and several emoji are rejected.
+func IsSingleEmoji(s string) bool {
+	runes := []rune(s)
+	if len(runes) == 0 || len(runes) > 16 {
+		return false
+	}
+	if len(runes) == 2 && isRegionalIndicator(runes[0]) && isRegionalIndicator(runes[1]) {
+		return true
+	}
+	i := 0
+	for {
+		// one element: base plus modifiers
+		if i >= len(runes) {
+			return false
+		}
+		base := runes[i]
+		i++
+		switch {
+		case isPictograph(base):
+		case (base >= '0' && base <= '9') || base == '#' || base == '*':
+			// keycap: digit, optional VS16, then U+20E3
+			if i < len(runes) && runes[i] == '\ufe0f' {
+				i++
+			}
+			if i >= len(runes) || runes[i] != '\u20e3' {
+				return false
+			}
+			i++
+			return i == len(runes)
+		default:
+			return false
+		}
+		for i < len(runes) && isEmojiModifier(runes[i]) {
+			i++
+		}
+		if i < len(runes) && runes[i] >= 0xE0020 && runes[i] <= 0xE007E {
+			for i < len(runes) && runes[i] >= 0xE0020 && runes[i] <= 0xE007E {
+				i++
+			}
+			// tag sequence must be closed by CANCEL TAG and ends the emoji
+			return i == len(runes)-1 && runes[i] == 0xE007F
+		}
+		if i == len(runes) {
+			return true
+		}
+		if runes[i] != '\u200d' {
+			return false
+		}
+		i++
+	}
+}
+
+func isRegionalIndicator(r rune) bool { return r >= 0x1F1E6 && r <= 0x1F1FF }
+
+func isEmojiModifier(r rune) bool {
+	return r == '\ufe0f' || (r >= 0x1F3FB && r <= 0x1F3FF)
+}
+
+func isPictograph(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF, r >= 0x2600 && r <= 0x27BF, r >= 0x2300 && r <= 0x23FF,
+		r >= 0x2B00 && r <= 0x2BFF, r >= 0x2190 && r <= 0x21FF:
+		return !isRegionalIndicator(r) && !(r >= 0x1F3FB && r <= 0x1F3FF)
+	case r == 0x00A9, r == 0x00AE, r == 0x203C, r == 0x2049, r == 0x2122, r == 0x2139, r == 0x3030, r == 0x303D, r == 0x3297, r == 0x3299:
+		return true
+	}
+	return unicode.Is(unicode.So, r) && r > 0x2000
+}
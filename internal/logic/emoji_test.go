@@ -0,0 +1,43 @@
+package logic
+
+import "testing"
+
+func TestIsSingleEmoji(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want bool
+	}{
+		{"pictograph", "☕", true},
+		{"with variation selector", "❤️", true},
+		{"supplementary plane", "🍩", true},
+		{"skin tone", "👍🏽", true},
+		{"zwj sequence", "👩‍💻", true},
+		{"zwj family", "👨‍👩‍👧", true},
+		{"flag", "🇷🇺", true},
+		{"subdivision flag", "🏴\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F", true},
+		{"keycap", "7️⃣", true},
+		{"keycap without selector", "#⃣", true},
+		{"empty", "", false},
+		{"text", "кофе", false},
+		{"digit", "7", false},
+		{"two emoji", "☕🍩", false},
+		{"emoji and text", "☕ кофе", false},
+		{"emoji with space", "☕ ", false},
+		{"lone regional indicator", "🇷", false},
+		{"three regional indicators", "🇷🇺🇷", false},
+		{"lone skin tone", "🏽", false},
+		{"trailing zwj", "👩‍", false},
+		{"leading zwj", "‍👩", false},
+		{"unclosed tag sequence", "🏴\U000E0067\U000E0062", false},
+		{"keycap followed by more", "7⃣☕", false},
+		{"too long", "👨‍👩‍👧‍👦‍👨‍👩‍👧‍👦‍👨", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSingleEmoji(tt.in); got != tt.want {
+				t.Errorf("IsSingleEmoji(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package logic
+
+import (
+	"math/rand"
+	"time"
+)
+
+// GroupConfig tunes how users are partitioned into groups.
+type GroupConfig struct {
+	// MaxSize is a hard cap: when it would be exceeded another group is formed instead of
+	// enlarging one. 0 keeps the default 2–3 grouping of MakeGroups.
+	MaxSize int
+	// TargetSize is the preferred group size: as many groups of it as fit are formed and the
+	// remainder is spread over them, so groups have TargetSize or a few more members. MaxSize,
+	// when set, still caps them. 0 keeps the default 2–3 grouping of MakeGroups.
+	TargetSize int
+	// Balanced picks the number of groups whose size is nearest to TargetSize (3 when unset)
+	// and deals members out round-robin, so sizes differ by at most one and no group has fewer
+	// than two members when at least two users take part.
+	Balanced bool
+}
+
+// BalancedTarget is the size Balanced aims for when TargetSize is unset.
+const BalancedTarget = 3
+
+// custom reports whether cfg departs from the default 2–3 grouping.
+func (cfg GroupConfig) custom() bool {
+	return cfg.MaxSize > 0 || cfg.TargetSize > 0 || cfg.Balanced
+}
+
+// MakeGroupsWith splits users according to cfg.
+func MakeGroupsWith(users []User, cfg GroupConfig) []Group {
+	return MakeGroupsWithConfigRand(users, cfg, rand.New(rand.NewSource(time.Now().UnixNano())))
+}
+
+// MakeGroupsWithConfigRand is MakeGroupsWith with the shuffle drawn from r.
+func MakeGroupsWithConfigRand(users []User, cfg GroupConfig, r *rand.Rand) []Group {
+	if !cfg.custom() {
+		return MakeGroupsWithRand(users, r)
+	}
+	n := len(users)
+	if n == 0 {
+		return nil
+	}
+	// shuffle a copy: the caller's slice keeps its order
+	users = append([]User(nil), users...)
+	r.Shuffle(n, func(i, j int) { users[i], users[j] = users[j], users[i] })
+	return partition(users, cfg)
+}
+
+// partition cuts already shuffled users into groups according to cfg.
+func partition(users []User, cfg GroupConfig) []Group {
+	if !cfg.custom() {
+		return partitionDefault(users)
+	}
+	if cfg.Balanced {
+		return partitionRoundRobin(users, balancedCount(len(users), cfg))
+	}
+	return partitionEven(users, groupCount(len(users), cfg))
+}
+
+// balancedCount is how many groups n users form in Balanced mode: n divided by the target
+// size, rounded to the nearest whole number, between one and n/2, raised to the fewest groups
+// that respect MaxSize.
+func balancedCount(n int, cfg GroupConfig) int {
+	target := cfg.TargetSize
+	if target <= 0 {
+		target = BalancedTarget
+	}
+	k := (n + target/2) / target
+	if k > n/2 {
+		k = n / 2
+	}
+	if k < 1 {
+		k = 1
+	}
+	if cfg.MaxSize > 0 {
+		if capped := (n + cfg.MaxSize - 1) / cfg.MaxSize; capped > k {
+			k = capped
+		}
+	}
+	return k
+}
+
+// partitionRoundRobin deals users into k groups in turn.
+func partitionRoundRobin(users []User, k int) []Group {
+	groups := make([]Group, k)
+	for i, u := range users {
+		groups[i%k].Members = append(groups[i%k].Members, u)
+	}
+	return groups
+}
+
+// groupCount is how many groups n users form under cfg: n/TargetSize (at least one), raised
+// to the fewest groups that respect MaxSize.
+func groupCount(n int, cfg GroupConfig) int {
+	k := 1
+	if cfg.TargetSize > 0 && n/cfg.TargetSize > k {
+		k = n / cfg.TargetSize
+	}
+	if cfg.MaxSize > 0 {
+		if capped := (n + cfg.MaxSize - 1) / cfg.MaxSize; capped > k {
+			k = capped
+		}
+	}
+	return k
+}
+
+// partitionEven cuts users into k groups with sizes differing by at most one.
+// Each group is capped at its own length so appending to it cannot overwrite the next one.
+func partitionEven(users []User, k int) []Group {
+	n := len(users)
+	groups := make([]Group, 0, k)
+	base, extra := n/k, n%k
+	i := 0
+	for g := 0; g < k; g++ {
+		size := base
+		if g < extra {
+			size++
+		}
+		groups = append(groups, Group{Members: users[i : i+size : i+size]})
+		i += size
+	}
+	return groups
+}
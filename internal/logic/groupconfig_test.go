@@ -0,0 +1,106 @@
+package logic
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func testUsers(n int) []User {
+	users := make([]User, n)
+	for i := range users {
+		users[i] = User{ID: int64(i + 1)}
+	}
+	return users
+}
+
+// checkPartition fails unless groups contain every user exactly once.
+func checkPartition(t *testing.T, users []User, groups []Group) {
+	t.Helper()
+	seen := map[int64]int{}
+	for _, g := range groups {
+		for _, u := range g.Members {
+			seen[u.ID]++
+		}
+	}
+	for _, u := range users {
+		if seen[u.ID] != 1 {
+			t.Errorf("user %d placed %d times", u.ID, seen[u.ID])
+		}
+	}
+	if len(seen) != len(users) {
+		t.Errorf("%d distinct users grouped, want %d", len(seen), len(users))
+	}
+}
+
+func TestMakeGroupsMaxSize(t *testing.T) {
+	for _, max := range []int{3, 4} {
+		for n := 2; n <= 30; n++ {
+			users := testUsers(n)
+			groups := MakeGroupsWithConfigRand(users, GroupConfig{MaxSize: max}, rand.New(rand.NewSource(int64(n))))
+			checkPartition(t, users, groups)
+			want := (n + max - 1) / max
+			if len(groups) != want {
+				t.Errorf("max %d, n %d: %d groups, want %d", max, n, len(groups), want)
+			}
+			lo, hi := n, 0
+			for _, g := range groups {
+				if s := len(g.Members); s < lo {
+					lo = s
+				}
+				if s := len(g.Members); s > hi {
+					hi = s
+				}
+			}
+			if hi > max {
+				t.Errorf("max %d, n %d: group of %d", max, n, hi)
+			}
+			if lo < 2 {
+				t.Errorf("max %d, n %d: group of %d", max, n, lo)
+			}
+			if hi-lo > 1 {
+				t.Errorf("max %d, n %d: sizes %d..%d not balanced", max, n, lo, hi)
+			}
+		}
+	}
+}
+
+func TestPartitionEvenSeparateGroups(t *testing.T) {
+	groups := partitionEven(testUsers(6), 3)
+	groups[0].Members = append(groups[0].Members, User{ID: 99})
+	if got := groups[1].Members[0].ID; got != 3 {
+		t.Errorf("appending to the first group changed the second one: first member %d, want 3", got)
+	}
+}
+
+func TestMakeGroupsBalanced(t *testing.T) {
+	tests := []struct {
+		n      int
+		groups int
+	}{
+		{1, 1}, {2, 1}, {3, 1}, {4, 1}, {5, 2}, {6, 2},
+		{7, 2}, {8, 3}, {9, 3}, {10, 3}, {11, 4}, {12, 4},
+	}
+	for _, tt := range tests {
+		users := testUsers(tt.n)
+		groups := MakeGroupsWithConfigRand(users, GroupConfig{Balanced: true}, rand.New(rand.NewSource(int64(tt.n))))
+		checkPartition(t, users, groups)
+		if len(groups) != tt.groups {
+			t.Errorf("n=%d: %d groups, want %d", tt.n, len(groups), tt.groups)
+		}
+		lo, hi := tt.n, 0
+		for _, g := range groups {
+			if s := len(g.Members); s < lo {
+				lo = s
+			}
+			if s := len(g.Members); s > hi {
+				hi = s
+			}
+		}
+		if tt.n >= 2 && lo < 2 {
+			t.Errorf("n=%d: group of %d", tt.n, lo)
+		}
+		if hi-lo > 1 {
+			t.Errorf("n=%d: sizes %d..%d not balanced", tt.n, lo, hi)
+		}
+	}
+}
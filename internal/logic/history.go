@@ -0,0 +1,93 @@
+package logic
+
+import "math/rand"
+
+// Pair is two users who were grouped together before. A is the smaller ID.
+type Pair struct {
+	A, B int64
+}
+
+// NewPair orders the IDs so that equal pairs compare equal.
+func NewPair(a, b int64) Pair {
+	if a > b {
+		a, b = b, a
+	}
+	return Pair{A: a, B: b}
+}
+
+// historyAttempts is how many random starting groupings MakeGroupsAvoidingHistory improves on.
+const historyAttempts = 8
+
+// MakeGroupsAvoidingHistory splits users like MakeGroupsWith but keeps apart people who were
+// grouped together recently. Group sizes are the same as without history. When no grouping
+// avoids every recent pair, the one with the fewest repeats found is returned. The result
+// depends only on the input and r.
+func MakeGroupsAvoidingHistory(users []User, cfg GroupConfig, recent []Pair, r *rand.Rand) []Group {
+	n := len(users)
+	if n == 0 {
+		return nil
+	}
+	seen := make(map[Pair]bool, len(recent))
+	for _, p := range recent {
+		seen[NewPair(p.A, p.B)] = true
+	}
+	var best []Group
+	bestCost := -1
+	for attempt := 0; attempt < historyAttempts; attempt++ {
+		shuffled := append([]User(nil), users...)
+		r.Shuffle(n, func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		groups := partition(shuffled, cfg)
+		cost := reduceRepeats(groups, seen)
+		if bestCost < 0 || cost < bestCost {
+			best, bestCost = groups, cost
+		}
+		if cost == 0 || len(seen) == 0 {
+			break
+		}
+	}
+	return best
+}
+
+// repeats counts the recent pairs within a group.
+func repeats(members []User, seen map[Pair]bool) int {
+	c := 0
+	for i := range members {
+		for j := i + 1; j < len(members); j++ {
+			if seen[NewPair(members[i].ID, members[j].ID)] {
+				c++
+			}
+		}
+	}
+	return c
+}
+
+// reduceRepeats swaps members between groups while a swap lowers the number of recent pairs
+// grouped together, and returns the number left. Group sizes do not change.
+func reduceRepeats(groups []Group, seen map[Pair]bool) int {
+	total := 0
+	for _, g := range groups {
+		total += repeats(g.Members, seen)
+	}
+	for improved := total > 0; improved && total > 0; {
+		improved = false
+		for a := range groups {
+			for b := a + 1; b < len(groups); b++ {
+				ga, gb := groups[a].Members, groups[b].Members
+				for i := range ga {
+					for j := range gb {
+						before := repeats(ga, seen) + repeats(gb, seen)
+						ga[i], gb[j] = gb[j], ga[i]
+						after := repeats(ga, seen) + repeats(gb, seen)
+						if after < before {
+							total -= before - after
+							improved = true
+							continue
+						}
+						ga[i], gb[j] = gb[j], ga[i]
+					}
+				}
+			}
+		}
+	}
+	return total
+}
@@ -0,0 +1,31 @@
+package logic
+
+import "sort"
+
+// HasSmallGroup reports whether any group has fewer than min members.
+func HasSmallGroup(groups []Group, min int) bool {
+	for _, g := range groups {
+		if len(g.Members) < min {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeSmallGroups folds groups with fewer than min members into the smallest other group
+// until none is left below min. ok is false when that is impossible, i.e. everyone together
+// is still fewer than min.
+func MergeSmallGroups(groups []Group, min int) (merged []Group, ok bool) {
+	merged = append([]Group(nil), groups...)
+	for len(merged) > 1 {
+		sort.SliceStable(merged, func(i, j int) bool { return len(merged[i].Members) < len(merged[j].Members) })
+		if len(merged[0].Members) >= min {
+			return merged, true
+		}
+		// merged[1] is the smallest remaining group, so sizes stay as even as possible
+		joined := append(append([]User(nil), merged[1].Members...), merged[0].Members...)
+		merged[1] = Group{Members: joined}
+		merged = merged[1:]
+	}
+	return merged, len(merged) == 1 && len(merged[0].Members) >= min
+}
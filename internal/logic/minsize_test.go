@@ -0,0 +1,86 @@
+package logic
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// groupsOfSizes splits consecutive users into groups of the given sizes.
+func groupsOfSizes(sizes ...int) []Group {
+	var groups []Group
+	id := int64(0)
+	for _, n := range sizes {
+		var g Group
+		for i := 0; i < n; i++ {
+			id++
+			g.Members = append(g.Members, User{ID: id})
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+func sortedSizes(groups []Group) []int {
+	sizes := make([]int, len(groups))
+	for i, g := range groups {
+		sizes[i] = len(g.Members)
+	}
+	sort.Ints(sizes)
+	return sizes
+}
+
+func TestHasSmallGroup(t *testing.T) {
+	tests := []struct {
+		sizes []int
+		min   int
+		want  bool
+	}{
+		{[]int{2, 2}, 2, false},
+		{[]int{3, 2}, 3, true},
+		{[]int{3, 3}, 3, false},
+		{nil, 3, false},
+		{[]int{2}, 0, false},
+	}
+	for _, tt := range tests {
+		if got := HasSmallGroup(groupsOfSizes(tt.sizes...), tt.min); got != tt.want {
+			t.Errorf("HasSmallGroup(%v, %d) = %v, want %v", tt.sizes, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestMergeSmallGroups(t *testing.T) {
+	tests := []struct {
+		sizes []int
+		min   int
+		want  []int
+		ok    bool
+	}{
+		{[]int{3, 2}, 3, []int{5}, true},
+		{[]int{2, 2}, 3, []int{4}, true},
+		// the small group joins the smallest other one, keeping sizes even
+		{[]int{2, 3, 4}, 3, []int{4, 5}, true},
+		{[]int{3, 3, 1}, 3, []int{3, 4}, true},
+		{[]int{2, 2, 2}, 3, []int{6}, true},
+		{[]int{3, 3}, 3, []int{3, 3}, true},
+		// nobody to merge with, or everyone together still too few
+		{[]int{2}, 3, []int{2}, false},
+		{[]int{1, 1}, 3, []int{2}, false},
+	}
+	for _, tt := range tests {
+		groups := groupsOfSizes(tt.sizes...)
+		merged, ok := MergeSmallGroups(groups, tt.min)
+		if got := sortedSizes(merged); fmt.Sprint(got) != fmt.Sprint(tt.want) || ok != tt.ok {
+			t.Errorf("MergeSmallGroups(%v, %d) = %v, %v; want %v, %v", tt.sizes, tt.min, got, ok, tt.want, tt.ok)
+		}
+		var users []User
+		for _, g := range groups {
+			users = append(users, g.Members...)
+		}
+		checkPartition(t, users, merged)
+		// the input is left as it was
+		if got := fmt.Sprint(sortedSizes(groups)); got != fmt.Sprint(sortedSizes(groupsOfSizes(tt.sizes...))) {
+			t.Errorf("MergeSmallGroups(%v) changed its input to %s", tt.sizes, got)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package logic
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SanitizeName makes a user-supplied name safe to print on one line of the results:
+// control and invisible formatting characters (bidi overrides, zero-width spaces) are
+// dropped and any run of whitespace, including newlines, collapses to a single space.
+// Emoji sequences are kept intact.
+func SanitizeName(name string) string {
+	var sb strings.Builder
+	sb.Grow(len(name))
+	for _, r := range name {
+		switch {
+		case unicode.IsSpace(r):
+			sb.WriteRune(' ')
+		case unicode.IsControl(r):
+			// drop
+		case unicode.Is(unicode.Cf, r) && !keepFormatRune(r):
+			// drop bidi overrides, zero-width spaces, BOM etc.
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// keepFormatRune reports format characters that are part of emoji sequences.
+func keepFormatRune(r rune) bool {
+	return r == '\u200d' || // zero width joiner
+		(r >= 0xE0020 && r <= 0xE007F) // tag characters for subdivision flags
+}
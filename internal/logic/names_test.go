@@ -0,0 +1,45 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Анна Петрова", "Анна Петрова"},
+		{"embedded newline", "Ivan\nПобедитель: Ivan", "Ivan Победитель: Ivan"},
+		{"carriage return and tab", "a\r\n\tb", "a b"},
+		{"rtl override", "evil\u202egnp.exe", "evilgnp.exe"},
+		{"rtl isolate", "\u2067name\u2069", "name"},
+		{"zero width space", "Ад\u200bмин", "Админ"},
+		{"byte order mark", "\ufeffBob", "Bob"},
+		{"control chars", "Bo\x00b\x07", "Bob"},
+		{"whitespace runs", "  many   spaces  ", "many spaces"},
+		{"only controls", "\u202e\u200b\n", ""},
+		{"emoji kept", "Маша ☕", "Маша ☕"},
+		{"zwj sequence kept", "👩\u200d💻 Dev", "👩\u200d💻 Dev"},
+		{"subdivision flag kept", "🏴\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F", "🏴\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeName(tt.in)
+			if got != tt.want {
+				t.Errorf("SanitizeName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if strings.ContainsAny(got, "\n\r") {
+				t.Errorf("SanitizeName(%q) = %q is not single-line", tt.in, got)
+			}
+			for _, r := range got {
+				if unicode.IsControl(r) || (unicode.Is(unicode.Cf, r) && !keepFormatRune(r)) {
+					t.Errorf("SanitizeName(%q) = %q keeps %U", tt.in, got, r)
+				}
+			}
+		})
+	}
+}
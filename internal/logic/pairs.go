@@ -16,13 +16,25 @@ type Group struct {
 
 // MakeGroups splits users into groups of 2-3, trying to avoid 1-person groups.
 func MakeGroups(users []User) []Group {
+	return MakeGroupsWithRand(users, rand.New(rand.NewSource(time.Now().UnixNano())))
+}
+
+// MakeGroupsWithRand is MakeGroups with the shuffle drawn from r, so a fixed seed
+// reproduces the same groups.
+func MakeGroupsWithRand(users []User, r *rand.Rand) []Group {
 	n := len(users)
 	if n == 0 {
 		return nil
 	}
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	// shuffle a copy: the caller's slice keeps its order
+	users = append([]User(nil), users...)
 	r.Shuffle(n, func(i, j int) { users[i], users[j] = users[j], users[i] })
+	return partitionDefault(users)
+}
 
+// partitionDefault cuts users, in order, into groups of 2-3 without a 1-person group.
+func partitionDefault(users []User) []Group {
+	n := len(users)
 	var groups []Group
 	i := 0
 	for i < n {
@@ -0,0 +1,64 @@
+package logic
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestMakeGroupsKeepsInput(t *testing.T) {
+	tests := []struct {
+		name string
+		make func([]User) []Group
+	}{
+		{"default", MakeGroups},
+		{"seeded", func(u []User) []Group { return MakeGroupsWithRand(u, rand.New(rand.NewSource(1))) }},
+		{"max size", func(u []User) []Group { return MakeGroupsWith(u, GroupConfig{MaxSize: 3}) }},
+		{"balanced", func(u []User) []Group { return MakeGroupsWith(u, GroupConfig{Balanced: true}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := testUsers(10)
+			before := fmt.Sprint(users)
+			checkPartition(t, users, tt.make(users))
+			if after := fmt.Sprint(users); after != before {
+				t.Errorf("input reordered: %s, was %s", after, before)
+			}
+		})
+	}
+}
+
+func TestMakeGroupsWithRandDeterministic(t *testing.T) {
+	users := testUsers(9)
+	a := MakeGroupsWithRand(users, rand.New(rand.NewSource(42)))
+	b := MakeGroupsWithRand(users, rand.New(rand.NewSource(42)))
+	if fmt.Sprint(a) != fmt.Sprint(b) {
+		t.Errorf("same seed, different groups:\n%v\n%v", a, b)
+	}
+}
+
+func TestPartitionDefault(t *testing.T) {
+	tests := []struct {
+		n     int
+		sizes string
+	}{
+		{0, "[]"},
+		{1, "[1]"},
+		{2, "[2]"},
+		{3, "[3]"},
+		{4, "[2 2]"},
+		{5, "[3 2]"},
+		{6, "[3 3]"},
+		{7, "[3 2 2]"},
+		{8, "[3 3 2]"},
+	}
+	for _, tt := range tests {
+		var sizes []int
+		for _, g := range partitionDefault(testUsers(tt.n)) {
+			sizes = append(sizes, len(g.Members))
+		}
+		if got := fmt.Sprint(sizes); got != tt.sizes {
+			t.Errorf("n=%d: sizes %s, want %s", tt.n, got, tt.sizes)
+		}
+	}
+}
@@ -1,10 +1,235 @@
 package messages
 
 const (
-	IntroMessage = "Привет! Я бот для Random Coffee ☕️. Каждый день я буду приглашать всех желающих присоединиться к случайным встречам. Нажимайте кнопку ‘Я участвую’ — и через 30 минут я соберу пары и опубликую списки."
-	DailyInvite = "Кто хочет на Random Coffee сегодня? Нажимайте кнопку ‘Я участвую’. Через 30 минут я составлю пары!"
-	ImInButton  = "Я участвую"
-	JoinedAck   = "Отлично! Я добавил вас в список участников. Итоги будут через 30 минут."
-	AlreadyIn   = "Вы уже в списке участников на сегодня."
-	NoParticipants = "Сегодня никто не записался на Random Coffee. Попробуем завтра!"
+	IntroMessage          = "Привет! Я бот для Random Coffee ☕️. Каждый день я буду приглашать всех желающих присоединиться к случайным встречам. Нажимайте кнопку ‘Я участвую’ — и через 30 минут я соберу пары и опубликую списки."
+	DailyInvite           = "Кто хочет на Random Coffee сегодня? Нажимайте кнопку ‘Я участвую’. Через 30 минут я составлю пары!"
+	ImInButton            = "Я участвую"
+	ImInButtonCount       = "%s (%d)"
+	ExtendButton          = "Продлить ⏰"
+	LeaveButton           = "Выйти"
+	JoinedAck             = "Отлично! Я добавил вас в список участников. Итоги будут через 30 минут."
+	AlreadyIn             = "Вы уже в списке участников на сегодня."
+	SignupClosed          = "Набор участников уже закрыт."
+	InviteClosedNotice    = "Набор участников закрыт — кнопки в этом приглашении больше не работают."
+	ExtendJoinFirst       = "Продлить набор могут только записавшиеся."
+	ExtendUnavailable     = "Продлить набор уже нельзя."
+	ExtendVoted           = "Ваш голос учтён: %d из %d."
+	ExtendVotedDone       = "Ваш голос учтён."
+	ExtendApplied         = "По просьбе участников набор продлён до %s."
+	AutoExtendApplied     = "Участники всё ещё записываются — набор продлён до %s."
+	NoParticipants        = "Сегодня никто не записался на Random Coffee. Попробуем завтра!"
+	SmallGroupsCancelled  = "Сегодня записалось слишком мало людей, чтобы собрать группы от %d человек. Встреча отменяется — попробуем в следующий раз!"
+	LateTapsNote          = "Несколько человек не успели записаться — в следующий раз нажимайте «Я участвую» раньше!"
+	PairOnlyCancelled     = "Сегодня записались только двое — встреча отменяется. Попробуем в следующий раз!"
+	PairOnlyCarried       = "Сегодня записались только двое — встреча переносится, оба уже записаны на следующий раз."
+	EmptyStreakNudge      = "Уже %d раз подряд никто не записался на Random Coffee. Кажется, кофе не заходит — может, администраторам стоит поменять время или приостановить приглашения?"
+	LapsedMentionPrefix   = "Давно вас не было: "
+	ResultsHeader         = "Итоги Random Coffee на сегодня:"
+	ResultsSentPrivately  = "Итоги Random Coffee разосланы участникам в личные сообщения."
+	ResultsUnreachable    = "Не смог написать в личку: %s. Напишите боту /start, чтобы получать итоги."
+	DMGroupHeader         = "Ваша группа Random Coffee на сегодня: %s"
+	GroupLabel            = "Группа %s: "
+	ResultsRosterAttached = "Полный список групп — в файле ниже."
+	SummaryGroups         = "%d %s по %d %s"
+	SummaryTotal          = "всего %d %s"
+	GroupOne              = "группа"
+	GroupFew              = "группы"
+	GroupMany             = "групп"
+	PersonOne             = "человеку"
+	PersonFew             = "человека"
+	PersonMany            = "человек"
+	ParticipantOne        = "участник"
+	ParticipantFew        = "участника"
+	ParticipantMany       = "участников"
+	MinuteOne             = "минута"
+	MinuteFew             = "минуты"
+	MinuteMany            = "минут"
+	RemindLeft            = "Осталось %d %s! Кто ещё не записался — жмите кнопку в приглашении."
+	ResultsFacilitators   = "Организатор: %s\n"
+	NameWithTeam          = "%s (%s)"
+)
+
+// Команды
+const (
+	AdminOnly        = "Только администраторы могут менять настройки."
+	AdminCheckFailed = "Не удалось проверить права администратора, попробуйте ещё раз."
+	InternalError    = "Что-то пошло не так, попробуйте позже."
+
+	ConfigHeader          = "Настройки чата:"
+	ConfigDailyTime       = "• время приглашения: %s %s%s"
+	ConfigWindow          = "• окно набора: %s%s"
+	ConfigGrace           = "• приём опоздавших после дедлайна: %s%s"
+	ConfigCooldown        = "• мин. интервал между приглашениями: %s%s"
+	ConfigVerifyMembers   = "• проверять, что участники ещё в чате: %s%s"
+	ConfigInviteMedia     = "• картинка к приглашению: %s%s"
+	ConfigInviteDays      = "• дни приглашений: %s%s"
+	ConfigGroupSize       = "• размер групп: %s%s"
+	ConfigGroupDefault    = "2–3"
+	ConfigGroupBalanced   = " (поровну)"
+	ConfigGroupMin        = ", не меньше %d"
+	ConfigGroupMax        = ", не больше %d"
+	ConfigResults         = "• итоги: %s, подписи групп: %s%s"
+	ConfigPaused          = "• приглашения на паузе: %s"
+	ConfigFooter          = "* — задано для этого чата, остальное — глобальные значения."
+	ExplainSchedule       = "Приглашение отправляется каждый день в %s по %s, набор участников длится %s."
+	ExplainDays           = " Дни приглашений: %s, в остальные дни приглашения нет."
+	ExplainGrace          = " Опоздавших принимаем ещё %s после окончания набора."
+	ExplainRemind         = " За %s до конца набора бот напомнит о нём."
+	ExplainExtend         = " Если продлить набор попросят %d из записавшихся, он один раз продлевается на %s."
+	ExplainGroups         = "\nЗатем участники делятся на группы по 2–3 человека."
+	ExplainGroupsCapped   = "\nЗатем участники делятся на группы не больше %d человек, как можно ровнее."
+	ExplainGroupsTarget   = "\nЗатем участники делятся на группы по %d человек, оставшиеся добавляются в эти группы."
+	ExplainGroupsBalanced = "\nЗатем участники делятся на группы как можно ближе к %d человек, все группы почти одного размера."
+	ExplainGroupsMax      = " Больше %d человек в группе не бывает."
+	ExplainAvoidRepeats   = " Тех, кто встречался за последние %d дн., бот старается не сводить снова."
+	ExplainSmallMerge     = " Группы меньше %d человек объединяются с другими."
+	ExplainSmallCancel    = " Если получается группа меньше %d человек, встреча отменяется."
+	ExplainPairOnlyCancel = " Если записались только двое, встреча отменяется."
+	ExplainPairOnlyCarry  = " Если записались только двое, встреча переносится и они автоматически записываются на следующую."
+	ExplainResultsPublic  = "\nИтоги публикуются в чате."
+	ExplainResultsPrivate = "\nИтоги приходят участникам в личные сообщения."
+	ExplainResultsBoth    = "\nИтоги публикуются в чате и дублируются в личку."
+	OverriddenMark        = " *"
+
+	SetUsage    = "Использование: /set <параметр> <значение|default>\nПараметры: window (например 45m), grace (например 5m), cooldown (например 10m, 0 — без ограничения), verify_members (on/off), lapsed_mentions (0–20), lapsed_after (сессий), label_single (on/off), results (public/private/both), labels (numeric/alpha/emoji:🍩,☕,…), empty_nudge (0 — выкл), summary_over (участников, 0 — всегда списком), pin_invite (on/off), pin_results (on/off), noshow (%, 0 — выкл), extend_votes (0 — выкл), extend_by (например 15m), remind_before (например 5m, 0 — не напоминать), timezone (например Europe/Moscow), days (all, weekdays или список: mon-fri, пн,ср,пт), auto_extend (записей перед дедлайном, 0 — выкл), auto_extend_window (например 5m), group_size (2–20), balance (on/off), max_group (2–20), avoid_repeats (дней, 0 — выкл), min_group (0 — без минимума), small_groups (publish/merge/cancel), pair_only (publish/cancel/carry), team_tags (on/off), late_note (on/off), allow_leave (on/off), emoji (один эмодзи)"
+	SetBadValue = "Недопустимое значение для %s."
+	SetBadEmoji = "Нужен ровно один эмодзи, например: /set emoji ☕"
+	SetDone     = "Готово, настройка сохранена."
+
+	SetGroupBounds = "Мин. размер группы (%d) не может быть больше максимального (%d)."
+
+	SetWindowUsage = "Использование: /setwindow <длительность|default>, например /setwindow 45m — сколько длится набор после приглашения в этом чате (от 1m до 24h); default — общее значение бота."
+
+	SetTimeUsage    = "Использование: /settime ЧЧ:ММ (время UTC, в чатах с /set timezone — местное), например /settime 09:30. Несколько приглашений в день — через запятую: /settime 09:00,15:00. Подходит и выражение cron из пяти полей: /settime 0 10 * * 2,4 — по вторникам и четвергам в 10:00."
+	SetTimeCronDone = "Готово: приглашения будут приходить по расписанию cron «%s» (%s). Расписание общее для всех чатов бота (в чатах со своим часовым поясом — по местному времени)."
+	SetTimeDone     = "Готово: приглашения будут приходить в %s %s. Время общее для всех чатов бота (в чатах со своим часовым поясом — по местному времени); если до ближайшей рассылки осталось совсем мало, новое время заработает со следующего дня."
+
+	PauseDone     = "Приглашения в этом чате приостановлены. История встреч сохранена; /resume — возобновить."
+	PauseAlready  = "Приглашения в этом чате уже приостановлены. /resume — возобновить."
+	ResumeDone    = "Приглашения в этом чате возобновлены — следующее придёт по расписанию."
+	ResumeAlready = "Приглашения в этом чате и так приходят по расписанию."
+
+	CoffeeAlreadySent = "Приглашение на эту встречу сегодня уже отправлено — ищите его выше в чате."
+	CoffeeSkipped     = "Приглашение не отправлено: в этом чате сейчас нет рассылки (пауза, выходной день или праздник) либо недавно уже было приглашение."
+	CoffeeFailed      = "Не удалось отправить приглашение, попробуйте позже."
+
+	HolidayUsage    = "Использование: /addholiday ГГГГ-ММ-ДД или /delholiday ГГГГ-ММ-ДД, например /addholiday 2025-01-01. Владелец бота может добавить all, чтобы день был выходным во всех чатах."
+	HolidayAdded    = "Готово: %s приглашения не будет."
+	HolidayRemoved  = "Готово: %s больше не выходной."
+	HolidayNotFound = "%s не отмечен как выходной."
+
+	AddUsage           = "Ответьте командой /add на сообщение человека, которого нужно добавить, или укажите /add @username."
+	NoOpenSession      = "Сегодня нет открытого набора участников."
+	AddAlreadyIn       = "%s уже в списке участников."
+	AddDone            = "Добавил %s в список участников."
+	RemoveUsage        = "Ответьте командой /remove на сообщение человека или укажите /remove @username."
+	RemoveNotIn        = "%s нет в списке участников."
+	RemoveDone         = "Убрал %s из списка участников."
+	IdentifyNoUsername = "У вас не задан username в Telegram, по нему вас не найти."
+	IdentifyDone       = "Запомнил: @%s — это вы."
+	LeaveDone          = "Готово, вы больше не в списке участников на сегодня."
+	LeaveNotIn         = "Вас и так нет в списке участников."
+	LeaveNotAllowed    = "В этом чате отписаться нельзя — записались, значит идёте!"
+	SkipDone           = "Понял, сегодня без вас. Передумаете — /unskip."
+	SkipAlready        = "Вы уже отказались от сегодняшней встречи. Передумаете — /unskip."
+	UnskipDone         = "Отказ отменён — можно записываться на сегодняшнюю встречу."
+	UnskipNotSkipped   = "Вы не отказывались от сегодняшней встречи."
+	UnskipTooLate      = "Набор на сегодня уже закрыт — отменять отказ поздно."
+	JoinSkipped        = "Вы отказались от сегодняшней встречи. Чтобы записаться, сначала отправьте /unskip."
+	SetLeaveTextUsage  = "Использование: /setleavetext <текст ответа на /leave, до %d символов>, /setleavetext default — стандартный ответ."
+	TeamUsage          = "Использование: /team <команда или отдел>, /team off — убрать."
+	TeamTooLong        = "Название команды — не длиннее %d символов."
+	TeamDone           = "Запомнил: вы из команды «%s»."
+	TeamCleared        = "Команда больше не указывается."
+	RecountDone        = "Участников сейчас: %d."
+	TestInviteSent     = "Отправил пробное приглашение вам в личку."
+	TestInviteDMFailed = "Не получилось написать вам в личку — напишите боту /start и повторите."
+	TestInviteTapped   = "Это пробное приглашение, запись не ведётся."
+	PreviewHeader      = "Предварительные группы (ещё не опубликованы):"
+	PreviewFooter      = "/reshuffle — перемешать заново, /confirm — опубликовать."
+	PreviewEmpty       = "Пока некого распределять по группам."
+	ConfirmNoPreview   = "Нет предварительной разбивки. Сначала вызовите /preview."
+	FacilitatorUsage   = "Ответьте командой /facilitator на сообщение человека, чтобы сделать его организатором (он не попадает в группы) или снять эту роль."
+	FacilitatorOn      = "%s теперь организатор и не попадает в группы."
+	FacilitatorOff     = "%s больше не организатор."
+
+	SetInviteImageUsage = "Ответьте командой /setinviteimage на сообщение с картинкой или стикером. /setinviteimage off — приглашение без картинки."
+	ImportConfigUsage   = "Ответьте командой /importconfig на файл, полученный через /exportconfig."
+	ImportConfigInvalid = "Файл не похож на выгрузку настроек или слишком большой."
+	ImportConfigDone    = "Настройки загружены."
+	ExportEmpty         = "В этом чате ещё не было встреч — выгружать нечего."
+
+	InspectUsage           = "Использование: /inspect <chatID>"
+	ChatNotFound           = "Чат не найден."
+	InspectHeader          = "Чат «%s» (%d), добавлен %s"
+	InspectSettings        = "Окно набора: %s, проверка участников: %s"
+	InspectNoSessions      = "Сессий пока не было."
+	InspectSession         = "%s #%d — %s, участников: %d"
+	SessionOpenLabel       = "открыта"
+	SessionClosedLabel     = "закрыта"
+	PurgeUsage             = "Использование: /purgechat <chatID>"
+	PurgeConfirm           = "Все данные чата %d будут удалены без возможности восстановления. Для подтверждения отправьте: /purgechat %[1]d %s"
+	OwnerAbandonedSessions = "Закрыты без публикации устаревшие сессии: %v"
+	PurgeDone              = "Данные чата %d удалены."
+	MergeUsersUsage        = "Использование: /mergeusers <keepID> <dropID> — история dropID переходит к keepID."
+	MergeUsersDone         = "История пользователя %d перенесена к %d."
+	DiagInvites            = "Сессий: %d, приглашение отправлено: %d. Без приглашения: открытых %d, закрытых %d."
+	DiagMissing            = "%s #%d, чат %d — %s"
+	DaySummary             = "За %s: сессий %d, участников %d."
+	DaySession             = "чат %d, слот %d — %d"
+	DayUsage               = "Использование: /day [ГГГГ-ММ-ДД]"
+	CloseIntervalUsage     = "Использование: /closeinterval <интервал|default>, от 1s до 5m, например /closeinterval 5s"
+	CloseIntervalDone      = "Сессии теперь проверяются каждые %s (до перезапуска)."
+	UptimeStarted          = "Запущен %s, работает %s."
+	UptimeNoDailyRun       = "Ежедневная рассылка с тех пор, как ведётся учёт, ещё не запускалась."
+	UptimeDailyRun         = "Последняя рассылка: %s — чатов %d, отправлено %d, пропущено %d, ошибок %d, заняло %s."
+
+	HistoryUsage     = "Использование: /history [количество]"
+	HistoryEmpty     = "В этом чате ещё не было встреч."
+	HistoryHeader    = "История встреч (%d–%d из %d):"
+	HistoryRound     = "📅 %s — участников: %d"
+	HistoryTruncated = "…список сокращён."
+	HistoryNewer     = "« Новее"
+	HistoryOlder     = "Старее »"
+
+	FeedbackMet            = "Встретились ✅"
+	FeedbackMissed         = "Не получилось ❌"
+	FeedbackThanks         = "Спасибо, ответ записан!"
+	FeedbackNotParticipant = "Отзыв могут оставить только участники этой встречи."
+	FeedbackNoData         = "За последние 30 дней отзывов о встречах нет."
+	FeedbackStats          = "Отзывы за 30 дней: %d, встретились — %.0f%%."
+
+	ICalName     = "Random Coffee"
+	ICalEvent    = "Random Coffee ☕ — запись участников"
+	ICalURL      = "Календарь приглашений на неделю вперёд, добавьте его в свой календарь по ссылке:\n%s"
+	ICalDisabled = "Календарь не настроен: владельцу бота нужно задать HTTP_ADDR и PUBLIC_URL."
+
+	DiversityNoData = "За последние 90 дней встреч с опубликованными группами не было."
+	DiversityStats  = "За последние 90 дней в среднем каждый участник встретился с %.1f разными людьми (участников: %d, больше всех — %d)."
+
+	StatsNoData   = "В этом чате ещё не было встреч."
+	Stats         = "Статистика чата:\n• встреч проведено: %d\n• участников за всё время: %d"
+	StatsGroups   = "• групп за встречу за 30 дней: %.1f (встреч: %d)"
+	StatsNoRecent = "• за последние 30 дней встреч с группами не было"
+
+	LeaderboardUsage  = "Использование: /leaderboard [N] — N самых активных участников (по умолчанию 10, не больше 30)."
+	LeaderboardEmpty  = "Пока никто не записывался на встречи — самое время начать! ☕"
+	LeaderboardHeader = "Самые активные участники:"
+	LeaderboardLine   = "%d. %s — %d"
+
+	HelpPrivate  = "Привет! Я бот для Random Coffee ☕️: каждый день приглашаю участников группы на случайные встречи и собираю их в пары и тройки. Добавьте меня в групповой чат и отправьте там /help — покажу, что умею."
+	HelpSchedule = "Приглашения приходят в %s %s, набор длится %s."
+	HelpMember   = "Команды:\n/explain — как собираются группы\n/leave — выйти из сегодняшнего набора\n/skip, /unskip — отказаться от сегодняшней встречи и передумать\n/team <название> — указать свою команду\n/history [N] — последние встречи и группы\n/stats — статистика участия\n/leaderboard [N] — самые активные участники\n/feedback — как часто встречи состоялись\n/diversity — со сколькими разными людьми встречались\n/ical — ссылка на календарь приглашений\n/identify — запомнить ваш @username"
+	HelpAdmin    = "Для админов:\n/config — настройки чата; /set <параметр> <значение> — изменить\n/settime ЧЧ:ММ — время приглашений; /setwindow 45m — длительность набора\n/pause, /resume — приостановить и возобновить приглашения\n/addholiday, /delholiday ГГГГ-ММ-ДД — дни без приглашений\n/coffee — отправить приглашение сейчас; /close — закрыть набор досрочно\n/preview, /reshuffle, /confirm — посмотреть группы заранее и утвердить\n/add, /remove @username — записать или убрать участника\n/facilitator — отметить ведущего (ответом на сообщение)\n/testinvite — как будет выглядеть приглашение\n/setinviteimage, /setleavetext — оформление\n/recount — пересчитать участников на кнопке\n/export — выгрузить встречи в CSV; /exportconfig, /importconfig — перенос настроек"
+	VersionReply = "coffeetrix24 версии %s"
+	HelpOwner    = "Для владельца бота:\n/version — версия бота\n/inspect, /diag, /uptime — состояние бота\n/day [дата] — сессии всех чатов за день\n/closeinterval — как часто закрываются наборы\n/purgechat, /mergeusers — удаление данных чата и слияние пользователей"
+
+	SetupButton         = "Настроить ⚙️"
+	SetupStepHeader     = "Настройка, шаг %d из %d."
+	SetupWindowPrompt   = "Сколько длится набор участников после приглашения?"
+	SetupResultsPrompt  = "Куда отправлять итоги?"
+	SetupResultsPublic  = "В чат"
+	SetupResultsPrivate = "В личку"
+	SetupResultsBoth    = "Туда и туда"
+	SetupDone           = "Готово! Настройки сохранены, посмотреть их можно командой /config."
 )
@@ -0,0 +1,117 @@
+// Package metrics keeps the process-wide counters and serves them in the Prometheus text
+// exposition format, so no client library is needed.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	InvitesSent        = newCounter("coffeetrix_invites_sent_total", "Invites posted to chats.")
+	SessionsClosed     = newCounter("coffeetrix_sessions_closed_total", "Sessions closed with their results posted.")
+	ParticipantsJoined = newCounter("coffeetrix_participants_joined_total", "Signups through the join button.")
+	SendErrors         = newCounter("coffeetrix_telegram_send_errors_total", "Telegram sends that failed after retries.")
+	// SchedulerIterations is labelled by loop: daily, zoned, closer, remind, retention.
+	SchedulerIterations = newCounterVec("coffeetrix_scheduler_iterations_total", "Scheduler loop iterations.", "loop")
+	// InFlight is the number of updates, sends and closes currently being processed.
+	InFlight = newGauge("coffeetrix_inflight_tasks", "Updates, sends and session closes currently being processed.")
+)
+
+var startTime = time.Now()
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	v          uint64 // first for 64-bit atomic alignment on 32-bit platforms
+	name, help string
+}
+
+func newCounter(name, help string) *Counter { return &Counter{name: name, help: help} }
+
+// Inc adds one.
+func (c *Counter) Inc() { atomic.AddUint64(&c.v, 1) }
+
+// Value returns the current count.
+func (c *Counter) Value() uint64 { return atomic.LoadUint64(&c.v) }
+
+// CounterVec is a family of counters told apart by the value of one label.
+type CounterVec struct {
+	name, help, label string
+	mu                sync.Mutex
+	values            map[string]*uint64
+}
+
+func newCounterVec(name, help, label string) *CounterVec {
+	return &CounterVec{name: name, help: help, label: label, values: map[string]*uint64{}}
+}
+
+// Inc adds one to the counter with the given label value.
+func (v *CounterVec) Inc(labelValue string) {
+	v.mu.Lock()
+	p, ok := v.values[labelValue]
+	if !ok {
+		p = new(uint64)
+		v.values[labelValue] = p
+	}
+	v.mu.Unlock()
+	atomic.AddUint64(p, 1)
+}
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	v          int64
+	name, help string
+}
+
+func newGauge(name, help string) *Gauge { return &Gauge{name: name, help: help} }
+
+// Add changes the gauge by d.
+func (g *Gauge) Add(d int64) { atomic.AddInt64(&g.v, d) }
+
+// WriteText writes every metric in the Prometheus text exposition format.
+func WriteText(w io.Writer) {
+	for _, c := range []*Counter{InvitesSent, SessionsClosed, ParticipantsJoined, SendErrors} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.Value())
+	}
+	v := SchedulerIterations
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", v.name, v.help, v.name)
+	v.mu.Lock()
+	labels := make([]string, 0, len(v.values))
+	for l := range v.values {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	for _, l := range labels {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", v.name, v.label, l, atomic.LoadUint64(v.values[l]))
+	}
+	v.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", InFlight.name, InFlight.help, InFlight.name, InFlight.name, atomic.LoadInt64(&InFlight.v))
+	fmt.Fprintf(w, "# HELP coffeetrix_start_time_seconds Process start time.\n# TYPE coffeetrix_start_time_seconds gauge\ncoffeetrix_start_time_seconds %d\n", startTime.Unix())
+}
+
+// Serve exposes the metrics at /metrics on addr until ctx is done.
+func Serve(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteText(w)
+	})
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	log.Printf("metrics: listening on %s", addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("metrics: server failed err=%v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronHorizon bounds the search for the next fire of a cron expression, so that a spec that never
+// matches (e.g. "0 9 31 2 *") ends the search instead of looping forever.
+const cronHorizon = 5 * 366 * 24 * time.Hour
+
+// cronSpec is a parsed five-field cron expression: minute, hour, day of month, month, day of week.
+// Each field is a bit set of the allowed values.
+type cronSpec struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+var cronMonthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronDayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// parseCron parses a standard cron expression such as "0 10 * * 2,4". Fields accept *, numbers,
+// ranges (1-5), steps (*/15, 1-5/2), lists, and month and weekday names; weekday 7 is Sunday.
+func parseCron(expr string) (*cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron: want 5 fields, got %d", len(fields))
+	}
+	c := &cronSpec{expr: strings.Join(fields, " ")}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, err
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7, cronDayNames); err != nil {
+		return nil, err
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny = fields[2] == "*"
+	c.dowAny = fields[4] == "*"
+	return c, nil
+}
+
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("cron: bad step in %q", part)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = cronValue(from, min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = cronValue(to, min, max, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("cron: bad range %q", rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func cronValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("cron: value %q out of range %d-%d", s, min, max)
+	}
+	return v, nil
+}
+
+// dayMatches applies the usual cron rule: when both day of month and day of week are restricted,
+// a day matching either of them counts.
+func (c *cronSpec) dayMatches(t time.Time) bool {
+	if c.month&(1<<t.Month()) == 0 {
+		return false
+	}
+	domOK := c.dom&(1<<t.Day()) != 0
+	dowOK := c.dow&(1<<t.Weekday()) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowOK
+	case c.dowAny:
+		return domOK
+	}
+	return domOK || dowOK
+}
+
+// next returns the first fire strictly after from, as wall time in from's location, or the zero
+// time when there is none within cronHorizon.
+func (c *cronSpec) next(from time.Time) time.Time {
+	loc := from.Location()
+	t := from.Truncate(time.Minute).Add(time.Minute)
+	limit := from.Add(cronHorizon)
+	for t.Before(limit) {
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minute&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// firesOn counts the fires on t's date up to and including t and returns the latest of them.
+func (c *cronSpec) firesOn(t time.Time) (n int, last time.Time) {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for f := c.next(midnight.Add(-time.Minute)); !f.IsZero() && !f.After(t); f = c.next(f) {
+		n++
+		last = f
+	}
+	return n, last
+}
@@ -2,62 +2,462 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"coffeetrix24/internal/db"
+	"coffeetrix24/internal/metrics"
 )
 
+// Clock abstracts wall time so tests can simulate clock jumps and drive the closer's waits.
+type Clock interface {
+	Now() time.Time
+	// After is time.After on this clock.
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// dailyTickInterval is how often loopDaily re-reads the daily time and checks the wall clock.
+var dailyTickInterval = time.Minute
+
+// clockJumpThreshold is how far the wall clock may deviate from the minute ticker
+// before loopDaily treats it as a clock jump and re-arms its timer.
+const clockJumpThreshold = 30 * time.Second
+
 type Scheduler struct {
 	Store           *db.Store
 	OnDailyInvite   func()
 	OnCloseSessions func(ids []int64)
+	// OnAbandonSessions is told about sessions abandoned for being older than MaxSessionAge.
+	OnAbandonSessions func(ids []int64)
+	// OnRemind is given sessions whose deadline is within their chat's reminder offset.
+	OnRemind func(ids []int64)
+	// OnZonedInvite is given chats with their own timezone once their local daily time comes;
+	// OnDailyInvite covers the other chats at the daily time in UTC.
+	OnZonedInvite func(chatIDs []int64)
 	// Config
-	CloseInterval time.Duration
-	DisableDaily  bool
+	DisableDaily bool
+	Clock        Clock
+	// MaxSessionAge abandons instead of publishing sessions whose deadline passed longer ago (0 disables).
+	MaxSessionAge time.Duration
+	// RetentionPeriod anonymizes participant names older than this (0 disables).
+	RetentionPeriod time.Duration
+	// MinNotice keeps a daily time change from firing sooner than this after the change (see reschedule).
+	MinNotice time.Duration
+	// CatchUpWindow sends today's invite at startup when the daily time passed less than this
+	// long ago and it has not gone out yet (0 disables).
+	CatchUpWindow time.Duration
+
+	// closeInterval is the closer's polling period in nanoseconds; it may change while running.
+	closeInterval int64
+	// loops tracks the running loops for Wait.
+	loops sync.WaitGroup
 }
 
+// Bounds and default of the closer's polling period.
+const (
+	DefaultCloseInterval = 30 * time.Second
+	MinCloseInterval     = time.Second
+	MaxCloseInterval     = 5 * time.Minute
+)
+
+// retentionInterval is how often the anonymization job runs.
+const retentionInterval = 6 * time.Hour
+
+// remindInterval is how often sessions are checked for a due reminder.
+const remindInterval = 30 * time.Second
+
 func New(store *db.Store) *Scheduler {
-	return &Scheduler{Store: store, CloseInterval: 30 * time.Second}
+	return &Scheduler{Store: store, Clock: realClock{}, closeInterval: int64(DefaultCloseInterval)}
+}
+
+// CloseInterval is the closer's current polling period.
+func (s *Scheduler) CloseInterval() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.closeInterval))
+}
+
+// SetCloseInterval changes the closer's polling period, taking effect after its current wait.
+func (s *Scheduler) SetCloseInterval(d time.Duration) error {
+	if d < MinCloseInterval || d > MaxCloseInterval {
+		return fmt.Errorf("close interval %s out of range [%s, %s]", d, MinCloseInterval, MaxCloseInterval)
+	}
+	atomic.StoreInt64(&s.closeInterval, int64(d))
+	return nil
+}
+
+// clockJumped reports whether the wall clock moved by more than the threshold between two
+// ticks that are interval apart on the monotonic clock (NTP step, VM resume, manual change).
+func clockJumped(prevTick, now time.Time, interval time.Duration) bool {
+	// Round(0) strips monotonic readings so the difference is measured on the wall clock.
+	drift := now.Round(0).Sub(prevTick.Round(0)) - interval
+	return drift > clockJumpThreshold || drift < -clockJumpThreshold
 }
 
 // Start runs scheduling loop for daily invite and session closing.
 func (s *Scheduler) Start(ctx context.Context) {
 	if !s.DisableDaily {
-		go s.loopDaily(ctx)
+		s.run(ctx, s.loopDaily)
+		if s.OnZonedInvite != nil {
+			s.run(ctx, s.loopZoned)
+		}
+	}
+	s.run(ctx, s.loopCloser)
+	if s.RetentionPeriod > 0 {
+		s.run(ctx, s.loopRetention)
+	}
+	if s.OnRemind != nil {
+		s.run(ctx, s.loopRemind)
 	}
-	go s.loopCloser(ctx)
 }
 
-func parseDaily(t string) (int, int) {
-	parts := strings.Split(t, ":")
-	if len(parts) != 2 {
-		return 9, 0
+func (s *Scheduler) run(ctx context.Context, loop func(context.Context)) {
+	s.loops.Add(1)
+	go func() {
+		defer s.loops.Done()
+		loop(ctx)
+	}()
+}
+
+// Wait blocks until the loops started by Start have returned after their context was cancelled,
+// including any callback they were running, or until ctx is done.
+func (s *Scheduler) Wait(ctx context.Context) error {
+	return waitGroup(ctx, &s.loops)
+}
+
+// waitGroup waits for wg or ctx, whichever comes first.
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	hh, err1 := strconv.Atoi(parts[0])
-	mm, err2 := strconv.Atoi(parts[1])
-	if err1 != nil || err2 != nil {
-		return 9, 0
+}
+
+// loopRemind hands sessions with a due reminder to OnRemind every remindInterval.
+func (s *Scheduler) loopRemind(ctx context.Context) {
+	log.Printf("scheduler: loopRemind start interval=%s", remindInterval)
+	ticker := time.NewTicker(remindInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			metrics.SchedulerIterations.Inc("remind")
+			ids, err := s.Store.SessionsToRemind(s.Clock.Now().UTC())
+			if err != nil {
+				log.Println("remind error:", err)
+				continue
+			}
+			if len(ids) > 0 {
+				log.Printf("scheduler: reminding sessions ids=%v", ids)
+				s.OnRemind(ids)
+			}
+		}
 	}
-	if hh < 0 || hh > 23 || mm < 0 || mm > 59 {
-		return 9, 0
+}
+
+// loopRetention anonymizes old participant data at start and then every retentionInterval.
+func (s *Scheduler) loopRetention(ctx context.Context) {
+	log.Printf("scheduler: loopRetention start period=%s", s.RetentionPeriod)
+	for {
+		metrics.SchedulerIterations.Inc("retention")
+		n, err := s.Store.AnonymizeOldParticipants(s.Clock.Now().Add(-s.RetentionPeriod))
+		if err != nil {
+			log.Println("retention error:", err)
+		} else if n > 0 {
+			log.Printf("scheduler: anonymized records=%d older than %s", n, s.RetentionPeriod)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retentionInterval):
+		}
 	}
-	return hh, mm
 }
 
-func (s *Scheduler) loopDaily(ctx context.Context) {
-	// Timer that re-reads settings every minute and reschedules if time changed.
-	log.Println("scheduler: loopDaily start")
-	getNext := func(hh, mm int, from time.Time) time.Time {
-		n := time.Date(from.Year(), from.Month(), from.Day(), hh, mm, 0, 0, time.UTC)
+// clock is one time of day of the daily schedule.
+type clock struct{ hh, mm int }
+
+func (c clock) String() string { return fmt.Sprintf("%02d:%02d", c.hh, c.mm) }
+
+// schedule is the parsed daily time: either fixed times of day or a cron expression.
+type schedule struct {
+	times []clock
+	cron  *cronSpec
+}
+
+// defaultSchedule is used when the daily time cannot be parsed.
+var defaultSchedule = schedule{times: []clock{{9, 0}}}
+
+// String is the canonical form of the schedule, e.g. "09:00,15:00" or "0 10 * * 2,4".
+func (sc schedule) String() string {
+	if sc.cron != nil {
+		return sc.cron.expr
+	}
+	parts := make([]string, len(sc.times))
+	for i, c := range sc.times {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// isCron tells a cron expression from a list of times of day, which always contains a colon.
+func isCron(t string) bool {
+	return !strings.Contains(t, ":")
+}
+
+// parseDaily parses the daily time: a comma-separated list of HH:MM such as "09:00,15:00", sorted
+// and deduplicated, or a cron expression such as "0 10 * * 2,4". A malformed value, or a cron
+// expression that never fires, falls back to 09:00.
+func parseDaily(t string) schedule {
+	if isCron(t) {
+		spec, err := parseCron(t)
+		if err != nil || spec.next(time.Now()).IsZero() {
+			return defaultSchedule
+		}
+		return schedule{cron: spec}
+	}
+	var times []clock
+	for _, part := range strings.Split(t, ",") {
+		parts := strings.Split(strings.TrimSpace(part), ":")
+		if len(parts) != 2 {
+			return defaultSchedule
+		}
+		hh, err1 := strconv.Atoi(parts[0])
+		mm, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil {
+			return defaultSchedule
+		}
+		if hh < 0 || hh > 23 || mm < 0 || mm > 59 {
+			return defaultSchedule
+		}
+		times = append(times, clock{hh, mm})
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].hh*60+times[i].mm < times[j].hh*60+times[j].mm
+	})
+	uniq := times[:1]
+	for _, c := range times[1:] {
+		if c != uniq[len(uniq)-1] {
+			uniq = append(uniq, c)
+		}
+	}
+	return schedule{times: uniq}
+}
+
+// NormalizeDaily validates a daily time and returns its canonical form: times sorted and without
+// duplicates, or a cron expression with single spaces. ok is false for a malformed value or a
+// cron expression that never fires.
+func NormalizeDaily(t string) (string, bool) {
+	t = strings.TrimSpace(t)
+	if isCron(t) {
+		spec, err := parseCron(t)
+		if err != nil || spec.next(time.Now()).IsZero() {
+			return "", false
+		}
+		return spec.expr, true
+	}
+	for _, part := range strings.Split(t, ",") {
+		if _, err := time.Parse("15:04", strings.TrimSpace(part)); err != nil {
+			return "", false
+		}
+	}
+	return parseDaily(t).String(), true
+}
+
+// IsCron reports whether the daily time is a cron expression rather than times of day.
+func IsCron(daily string) bool {
+	return isCron(daily)
+}
+
+// SlotAt is the index of the session slot t belongs to: the last of the daily fires that passed
+// on t's date in loc, or 0 before the first one.
+func SlotAt(daily string, t time.Time, loc *time.Location) int {
+	return slotAt(parseDaily(daily), t, loc)
+}
+
+func slotAt(sc schedule, t time.Time, loc *time.Location) int {
+	t = t.In(loc)
+	if sc.cron != nil {
+		if n, _ := sc.cron.firesOn(t); n > 0 {
+			return n - 1
+		}
+		return 0
+	}
+	min := t.Hour()*60 + t.Minute()
+	slot := 0
+	for i, c := range sc.times {
+		if c.hh*60+c.mm <= min {
+			slot = i
+		}
+	}
+	return slot
+}
+
+// lastToday returns the latest fire on t's date in loc at or before t; ok is false before the
+// first one.
+func lastToday(sc schedule, t time.Time, loc *time.Location) (last time.Time, ok bool) {
+	t = t.In(loc)
+	if sc.cron != nil {
+		n, last := sc.cron.firesOn(t)
+		return last, n > 0
+	}
+	for _, c := range sc.times {
+		target := time.Date(t.Year(), t.Month(), t.Day(), c.hh, c.mm, 0, 0, loc)
+		if !target.After(t) {
+			last, ok = target, true
+		}
+	}
+	return last, ok
+}
+
+// nextAt returns the first fire of the schedule UTC strictly after from.
+func nextAt(sc schedule, from time.Time) time.Time {
+	return nextAtIn(sc, from, time.UTC)
+}
+
+// nextAtIn returns the first fire of the schedule, as wall time in loc, strictly after from.
+func nextAtIn(sc schedule, from time.Time, loc *time.Location) time.Time {
+	from = from.In(loc)
+	if sc.cron != nil {
+		return sc.cron.next(from)
+	}
+	var next time.Time
+	for _, c := range sc.times {
+		n := time.Date(from.Year(), from.Month(), from.Day(), c.hh, c.mm, 0, 0, loc)
 		if !n.After(from) {
-			n = n.Add(24 * time.Hour)
+			// by date rather than 24h so that a DST change keeps the wall time
+			n = time.Date(from.Year(), from.Month(), from.Day()+1, c.hh, c.mm, 0, 0, loc)
+		}
+		if next.IsZero() || n.Before(next) {
+			next = n
+		}
+	}
+	return next
+}
+
+// sameDay reports whether a and b fall on the same UTC date.
+func sameDay(a, b time.Time) bool {
+	ya, ma, da := a.UTC().Date()
+	yb, mb, db := b.UTC().Date()
+	return ya == yb && ma == mb && da == db
+}
+
+// sameSlot reports whether a and b fall into the same session slot of the same UTC date.
+func sameSlot(sc schedule, a, b time.Time) bool {
+	return sameDay(a, b) && slotAt(sc, a, time.UTC) == slotAt(sc, b, time.UTC)
+}
+
+// reschedule picks the next fire after the daily times changed at now. oldNext is the fire
+// armed for the previous times; lastFired is the last fire of this process (zero if none).
+// A change never makes the invite go out within notice of it, and never yields a second invite
+// for a slot or a slot without one:
+//   - a fire due within notice stays as announced; the new times apply after it;
+//   - otherwise the new times apply from their first occurrence at least notice away, except
+//     for a slot that already had its invite;
+//   - if that occurrence falls into a later slot than oldNext, oldNext still fires and the new
+//     times apply afterwards, so the pending slot is not skipped.
+func reschedule(sc schedule, oldNext, lastFired, now time.Time, notice time.Duration) time.Time {
+	if oldNext.Sub(now) < notice {
+		return oldNext
+	}
+	next := nextAt(sc, now.Add(notice))
+	for !lastFired.IsZero() && sameSlot(sc, next, lastFired) {
+		next = nextAt(sc, next)
+	}
+	if oldNext.Before(next) && !sameSlot(sc, oldNext, next) {
+		return oldNext
+	}
+	return next
+}
+
+// catchUpDue reports whether today's latest fire UTC passed within CatchUpWindow before now while
+// no session exists for its slot, i.e. the bot was down when the invite was due.
+func (s *Scheduler) catchUpDue(sc schedule, now time.Time) bool {
+	if s.CatchUpWindow <= 0 {
+		return false
+	}
+	now = now.UTC()
+	last, ok := lastToday(sc, now, time.UTC)
+	if !ok || now.Sub(last) > s.CatchUpWindow {
+		return false
+	}
+	sent, err := s.Store.HasAnySessionForDate(now.Format("2006-01-02"), slotAt(sc, now, time.UTC))
+	if err != nil {
+		log.Println("catch-up error:", err)
+		return false
+	}
+	return !sent
+}
+
+// nextAfterFire is the first fire after one at firedAt, skipping the rest of its slot so that a
+// time moved later within the same slot does not cause a second invite.
+func nextAfterFire(sc schedule, firedAt time.Time) time.Time {
+	next := nextAt(sc, firedAt)
+	for !next.IsZero() && sameSlot(sc, next, firedAt) {
+		next = nextAt(sc, next)
+	}
+	return next
+}
+
+// NextFires returns the next n fire times of the daily schedule after from.
+// It is the same computation loopDaily uses, exposed for dry runs.
+func NextFires(daily string, from time.Time, n int) []time.Time {
+	return NextFiresIn(daily, from, n, time.UTC)
+}
+
+// NextFiresIn is NextFires for a chat whose daily time is wall time in loc.
+func NextFiresIn(daily string, from time.Time, n int, loc *time.Location) []time.Time {
+	sc := parseDaily(daily)
+	res := make([]time.Time, 0, n)
+	for i := 0; i < n; i++ {
+		if from = nextAtIn(sc, from, loc); from.IsZero() {
+			break
 		}
-		return n
+		res = append(res, from)
 	}
-	ticker := time.NewTicker(time.Minute)
+	return res
+}
+
+// FiresUntil returns the fires of the daily schedule, as wall time in loc, after from and before
+// until, at most max of them.
+func FiresUntil(daily string, from, until time.Time, loc *time.Location, max int) []time.Time {
+	var res []time.Time
+	for _, t := range NextFiresIn(daily, from, max, loc) {
+		if !t.Before(until) {
+			break
+		}
+		res = append(res, t)
+	}
+	return res
+}
+
+// loopDaily fires OnDailyInvite at each of the configured times. A minute ticker re-reads settings
+// and reschedules when the times changed. Go timers run on the monotonic clock, so when the wall
+// clock jumps the timer would fire at the wrong wall time; the ticker detects such jumps and
+// re-arms the timer for the same target: a backward jump delays it, a forward jump past the
+// target fires immediately (per-slot dedup in the bot prevents a second invite).
+func (s *Scheduler) loopDaily(ctx context.Context) {
+	log.Println("scheduler: loopDaily start")
+	ticker := time.NewTicker(dailyTickInterval)
 	defer ticker.Stop()
 
 	// initial schedule
@@ -65,11 +465,18 @@ func (s *Scheduler) loopDaily(ctx context.Context) {
 	if err != nil {
 		daily = "09:00"
 	}
-	hh, mm := parseDaily(daily)
-	now := time.Now().UTC()
-	next := getNext(hh, mm, now)
-	log.Printf("scheduler: initial daily_time=%s parsed=%02d:%02d next=%s", daily, hh, mm, next.Format(time.RFC3339))
-	timer := time.NewTimer(time.Until(next))
+	sc := parseDaily(daily)
+	now := s.Clock.Now().UTC()
+	next := nextAt(sc, now)
+	log.Printf("scheduler: initial daily_time=%s parsed=%s next=%s", daily, sc, next.Format(time.RFC3339))
+	timer := time.NewTimer(next.Sub(now))
+	lastTick := now
+	var lastFired time.Time
+	if s.OnDailyInvite != nil && s.catchUpDue(sc, now) {
+		log.Printf("scheduler: catch-up daily invite now=%s schedule=%s window=%s", now.Format(time.RFC3339), sc, s.CatchUpWindow)
+		s.OnDailyInvite()
+		lastFired = now
+	}
 	defer func() {
 		if !timer.Stop() {
 			select {
@@ -84,52 +491,83 @@ func (s *Scheduler) loopDaily(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-timer.C:
-			log.Printf("scheduler: firing daily invite now=%s target=%02d:%02d nextWas=%s", time.Now().UTC().Format(time.RFC3339), hh, mm, next.Format(time.RFC3339))
+			metrics.SchedulerIterations.Inc("daily")
+			log.Printf("scheduler: firing daily invite now=%s schedule=%s nextWas=%s", s.Clock.Now().UTC().Format(time.RFC3339), sc, next.Format(time.RFC3339))
 			if s.OnDailyInvite != nil {
 				s.OnDailyInvite()
 			}
 			// after firing, compute next based on current setting
-			now = time.Now().UTC()
+			now = s.Clock.Now().UTC()
+			lastFired = now
 			daily, err = s.Store.GetDailyTime()
 			if err != nil {
 				daily = "09:00"
 			}
-			hh, mm = parseDaily(daily)
-			next = getNext(hh, mm, now)
-			timer = time.NewTimer(time.Until(next))
+			sc = parseDaily(daily)
+			next = nextAfterFire(sc, now)
+			timer = time.NewTimer(next.Sub(now))
 		case <-ticker.C:
-			// check if time changed and reschedule
-			daily2, err2 := s.Store.GetDailyTime()
-			if err2 != nil {
-				continue
-			}
-			h2, m2 := parseDaily(daily2)
-			newNext := getNext(h2, m2, time.Now().UTC())
-			// if scheduling changed, reset timer
-			if !newNext.Equal(next) {
-				log.Printf("scheduler: reschedule due to config change oldNext=%s newNext=%s", next.Format(time.RFC3339), newNext.Format(time.RFC3339))
-				next = newNext
+			metrics.SchedulerIterations.Inc("daily")
+			now = s.Clock.Now().UTC()
+			if clockJumped(lastTick, now, dailyTickInterval) {
+				log.Printf("scheduler: wall clock jump detected expected=%s now=%s, re-arming for next=%s", lastTick.Add(dailyTickInterval).Format(time.RFC3339), now.Format(time.RFC3339), next.Format(time.RFC3339))
 				if !timer.Stop() {
 					select {
 					case <-timer.C:
 					default:
 					}
 				}
-				timer = time.NewTimer(time.Until(next))
-				hh, mm = h2, m2
+				timer = time.NewTimer(next.Sub(now))
+			}
+			lastTick = now
+			// check if time changed and reschedule
+			daily2, err2 := s.Store.GetDailyTime()
+			if err2 != nil {
+				continue
+			}
+			sc2 := parseDaily(daily2)
+			if sc2.String() == sc.String() {
+				continue
+			}
+			sc = sc2
+			newNext := reschedule(sc, next, lastFired, now, s.MinNotice)
+			if newNext.Equal(next) {
+				log.Printf("scheduler: daily_time changed to %s, keeping next=%s (min notice %s)", sc, next.Format(time.RFC3339), s.MinNotice)
+				continue
+			}
+			log.Printf("scheduler: reschedule due to config change oldNext=%s newNext=%s", next.Format(time.RFC3339), newNext.Format(time.RFC3339))
+			next = newNext
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
 			}
+			timer = time.NewTimer(next.Sub(now))
 		}
 	}
 }
 
 func (s *Scheduler) loopCloser(ctx context.Context) {
-	log.Printf("scheduler: loopCloser start interval=%s", s.CloseInterval)
+	log.Printf("scheduler: loopCloser start interval=%s", s.CloseInterval())
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-time.After(s.CloseInterval):
-			now := time.Now().UTC()
+		case <-s.Clock.After(s.CloseInterval()):
+			metrics.SchedulerIterations.Inc("closer")
+			now := s.Clock.Now().UTC()
+			if s.MaxSessionAge > 0 {
+				stale, err := s.Store.AbandonStaleSessions(ctx, now.Add(-s.MaxSessionAge))
+				if err != nil {
+					log.Println("closer abandon error:", err)
+				} else if len(stale) > 0 {
+					log.Printf("scheduler: abandoned stale sessions ids=%v maxAge=%s", stale, s.MaxSessionAge)
+					if s.OnAbandonSessions != nil {
+						s.OnAbandonSessions(stale)
+					}
+				}
+			}
 			ids, err := s.Store.GetOpenSessionsToClose(now)
 			if err != nil {
 				log.Println("closer error:", err)
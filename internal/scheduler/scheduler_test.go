@@ -0,0 +1,451 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"coffeetrix24/internal/db"
+)
+
+// fakeClock is a wall clock that stands still until set. Its After channels fire once the
+// clock is set at or past their deadline.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+	// waits, when not nil, receives the duration of every After call.
+	waits chan time.Duration
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.mu.Lock()
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), ch: ch})
+	waits := c.waits
+	c.mu.Unlock()
+	if waits != nil {
+		waits <- d
+	}
+	return ch
+}
+
+func (c *fakeClock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+	pending := c.timers[:0]
+	for _, tm := range c.timers {
+		if tm.at.After(t) {
+			pending = append(pending, tm)
+			continue
+		}
+		tm.ch <- t
+	}
+	c.timers = pending
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.set(c.Now().Add(d))
+}
+
+func newTestStore(t *testing.T, daily string) *db.Store {
+	t.Helper()
+	st, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.DB.Close() })
+	if err := st.EnsureSettings(daily); err != nil {
+		t.Fatalf("settings: %v", err)
+	}
+	return st
+}
+
+func TestLoopDailyClockJump(t *testing.T) {
+	defer func(d time.Duration) { dailyTickInterval = d }(dailyTickInterval)
+	dailyTickInterval = 10 * time.Millisecond
+
+	day := func(hh, mm, ss int) time.Time { return time.Date(2024, 5, 6, hh, mm, ss, 0, time.UTC) }
+	tests := []struct {
+		name string
+		// start is the wall time when the loop starts; the clock jumps to jumpTo shortly after.
+		start, jumpTo time.Time
+		wantFire      bool
+	}{
+		// the timer was armed for an hour; the jump past 09:00 must fire at once
+		{"forward past the target", day(8, 0, 0), day(9, 0, 30), true},
+		// the timer was armed for half a second; after the jump 09:00 is an hour away again
+		{"backward before the target", day(8, 59, 59).Add(500 * time.Millisecond), day(7, 59, 59), false},
+		{"no jump, target far away", day(8, 0, 0), day(8, 0, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := &fakeClock{now: tt.start}
+			s := New(newTestStore(t, "09:00"))
+			s.Clock = clk
+			fired := make(chan struct{}, 1)
+			s.OnDailyInvite = func() {
+				select {
+				case fired <- struct{}{}:
+				default:
+				}
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				s.loopDaily(ctx)
+				close(done)
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			time.Sleep(5 * dailyTickInterval)
+			clk.set(tt.jumpTo)
+			select {
+			case <-fired:
+				if !tt.wantFire {
+					t.Fatal("daily invite fired")
+				}
+			case <-time.After(time.Second):
+				if tt.wantFire {
+					t.Fatal("daily invite did not fire")
+				}
+			}
+		})
+	}
+}
+
+func TestLoopDailyReschedule(t *testing.T) {
+	defer func(d time.Duration) { dailyTickInterval = d }(dailyTickInterval)
+	dailyTickInterval = 10 * time.Millisecond
+
+	day := func(hh, mm, ss int) time.Time { return time.Date(2024, 5, 6, hh, mm, ss, 0, time.UTC) }
+	tests := []struct {
+		name string
+		// the loop starts at start with daily, which changes to changeTo before the clock moves to jumpTo
+		start            time.Time
+		daily, changeTo  string
+		jumpTo           time.Time
+		catchUp          bool // the loop starts with a catch-up invite
+		wantFireAfterJmp bool
+	}{
+		{"moved later within the fired slot", day(9, 5, 0), "09:00", "09:30", day(9, 31, 0), true, false},
+		{"moved later, old time passes", day(8, 0, 0), "09:00", "10:00", day(9, 0, 30), false, false},
+		{"moved earlier", day(8, 0, 0), "09:00", "08:30", day(8, 30, 30), false, true},
+		{"pending slot moved past now still fires", day(8, 0, 0), "09:00,15:00", "07:00,12:00", day(9, 0, 30), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := &fakeClock{now: tt.start}
+			st := newTestStore(t, tt.daily)
+			s := New(st)
+			s.Clock = clk
+			if tt.catchUp {
+				s.CatchUpWindow = 10 * time.Minute
+			}
+			fired := make(chan struct{}, 10)
+			s.OnDailyInvite = func() { fired <- struct{}{} }
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				s.loopDaily(ctx)
+				close(done)
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			if tt.catchUp {
+				select {
+				case <-fired:
+				case <-time.After(time.Second):
+					t.Fatal("catch-up invite did not fire")
+				}
+			}
+			time.Sleep(5 * dailyTickInterval)
+			if err := st.SetDailyTime(tt.changeTo); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(5 * dailyTickInterval)
+			clk.set(tt.jumpTo)
+
+			select {
+			case <-fired:
+				if !tt.wantFireAfterJmp {
+					t.Fatal("daily invite fired again")
+				}
+			case <-time.After(300 * time.Millisecond):
+				if tt.wantFireAfterJmp {
+					t.Fatal("daily invite did not fire")
+				}
+			}
+			// exactly one fire for the slot
+			select {
+			case <-fired:
+				t.Fatal("daily invite fired twice")
+			case <-time.After(5 * dailyTickInterval):
+			}
+		})
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	day := func(hh, mm int) time.Time { return time.Date(2024, 5, 6, hh, mm, 0, 0, time.UTC) }
+	tomorrow := func(hh, mm int) time.Time { return day(hh, mm).AddDate(0, 0, 1) }
+	tests := []struct {
+		name                    string
+		daily                   string
+		oldNext, lastFired, now time.Time
+		notice                  time.Duration
+		want                    time.Time
+	}{
+		{"later today", "10:00", day(9, 0), time.Time{}, day(8, 0), 0, day(10, 0)},
+		{"earlier today", "08:30", day(9, 0), time.Time{}, day(8, 0), 0, day(8, 30)},
+		// today's pending invite still goes out at the old time rather than being skipped
+		{"already past today", "07:00", day(9, 0), time.Time{}, day(8, 0), 0, day(9, 0)},
+		{"due within notice stays", "10:00", day(9, 0), time.Time{}, day(8, 50), 15 * time.Minute, day(9, 0)},
+		{"new time within notice", "08:10", day(9, 0), time.Time{}, day(8, 0), 15 * time.Minute, day(9, 0)},
+		{"slot already fired", "09:30", tomorrow(9, 0), day(9, 0), day(9, 5), 0, tomorrow(9, 30)},
+		{"pending slot not skipped", "07:00,12:00", day(9, 0), time.Time{}, day(8, 0), 0, day(9, 0)},
+		{"second slot of a fired day", "09:00,15:00", tomorrow(9, 0), day(9, 0), day(9, 5), 0, day(15, 0)},
+	}
+	for _, tt := range tests {
+		got := reschedule(parseDaily(tt.daily), tt.oldNext, tt.lastFired, tt.now, tt.notice)
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: reschedule = %s, want %s", tt.name, got.Format(time.RFC3339), tt.want.Format(time.RFC3339))
+		}
+	}
+}
+
+func TestCatchUpDue(t *testing.T) {
+	day := func(hh, mm int) time.Time { return time.Date(2024, 5, 6, hh, mm, 0, 0, time.UTC) }
+	tests := []struct {
+		name    string
+		window  time.Duration
+		now     time.Time
+		session bool // today's slot already has a session
+		want    bool
+	}{
+		{"disabled", 0, day(9, 5), false, false},
+		{"within window", 10 * time.Minute, day(9, 5), false, true},
+		{"invite already out", 10 * time.Minute, day(9, 5), true, false},
+		{"window passed", 10 * time.Minute, day(9, 30), false, false},
+		{"before the daily time", 10 * time.Minute, day(8, 55), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newTestStore(t, "09:00")
+			if tt.session {
+				if err := st.UpsertChat(-100, "test"); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := st.CreateOrGetTodaySession(-100, "2024-05-06", 0, day(9, 30)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := New(st)
+			s.CatchUpWindow = tt.window
+			if got := s.catchUpDue(parseDaily("09:00"), tt.now); got != tt.want {
+				t.Errorf("catchUpDue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoopCloserInterval(t *testing.T) {
+	start := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	clk := &fakeClock{now: start, waits: make(chan time.Duration, 10)}
+	st := newTestStore(t, "09:00")
+	if err := st.UpsertChat(-100, "test"); err != nil {
+		t.Fatal(err)
+	}
+	// a session past its deadline is handed to the closer on every iteration
+	if _, err := st.CreateOrGetTodaySession(-100, "2024-05-06", 0, start.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	s := New(st)
+	s.Clock = clk
+	closed := make(chan struct{}, 10)
+	s.OnCloseSessions = func([]int64) { closed <- struct{}{} }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.loopCloser(ctx)
+
+	expectWait := func(want time.Duration) {
+		t.Helper()
+		select {
+		case d := <-clk.waits:
+			if d != want {
+				t.Fatalf("closer waits %s, want %s", d, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("closer is not waiting")
+		}
+	}
+	expectClose := func(want bool) {
+		t.Helper()
+		timeout := time.Second
+		if !want {
+			timeout = 50 * time.Millisecond
+		}
+		select {
+		case <-closed:
+			if !want {
+				t.Fatal("closer ran before its interval passed")
+			}
+		case <-time.After(timeout):
+			if want {
+				t.Fatal("closer did not run")
+			}
+		}
+	}
+
+	expectWait(DefaultCloseInterval)
+	clk.advance(DefaultCloseInterval - time.Second)
+	expectClose(false)
+	// the new interval applies from the next wait on
+	if err := s.SetCloseInterval(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	clk.advance(time.Second)
+	expectClose(true)
+	expectWait(5 * time.Second)
+	clk.advance(4 * time.Second)
+	expectClose(false)
+	clk.advance(time.Second)
+	expectClose(true)
+	expectWait(5 * time.Second)
+}
+
+func TestClockJumped(t *testing.T) {
+	prev := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"on time", prev.Add(time.Minute), false},
+		{"small drift", prev.Add(time.Minute + 20*time.Second), false},
+		{"forward jump", prev.Add(time.Hour), true},
+		{"backward jump", prev.Add(-time.Hour), true},
+		{"stopped clock", prev, true},
+	}
+	for _, tt := range tests {
+		if got := clockJumped(prev, tt.now, time.Minute); got != tt.want {
+			t.Errorf("%s: clockJumped = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNextFiresIn(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip("no tz database:", err)
+	}
+	from := time.Date(2024, 5, 10, 8, 0, 0, 0, time.UTC) // Friday
+	tests := []struct {
+		name  string
+		daily string
+		loc   *time.Location
+		want  []string
+	}{
+		{"once a day", "09:00", time.UTC, []string{"2024-05-10T09:00:00Z", "2024-05-11T09:00:00Z", "2024-05-12T09:00:00Z"}},
+		{"already passed today", "07:30", time.UTC, []string{"2024-05-11T07:30:00Z", "2024-05-12T07:30:00Z", "2024-05-13T07:30:00Z"}},
+		{"two slots", "15:00,09:00", time.UTC, []string{"2024-05-10T09:00:00Z", "2024-05-10T15:00:00Z", "2024-05-11T09:00:00Z"}},
+		{"chat timezone", "09:00", moscow, []string{"2024-05-11T09:00:00+03:00", "2024-05-12T09:00:00+03:00", "2024-05-13T09:00:00+03:00"}},
+		{"cron weekdays", "0 9 * * 1-5", time.UTC, []string{"2024-05-10T09:00:00Z", "2024-05-13T09:00:00Z", "2024-05-14T09:00:00Z"}},
+		{"malformed falls back to 09:00", "25:99", time.UTC, []string{"2024-05-10T09:00:00Z", "2024-05-11T09:00:00Z", "2024-05-12T09:00:00Z"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, f := range NextFiresIn(tt.daily, from, len(tt.want), tt.loc) {
+			got = append(got, f.Format(time.RFC3339))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: NextFiresIn(%q) = %v, want %v", tt.name, tt.daily, got, tt.want)
+		}
+	}
+}
+
+func TestLoopCloserAbandonsStale(t *testing.T) {
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	clk := &fakeClock{now: now, waits: make(chan time.Duration, 10)}
+	st := newTestStore(t, "09:00")
+	if err := st.UpsertChat(-100, "test"); err != nil {
+		t.Fatal(err)
+	}
+	session := func(date string, deadline time.Time) int64 {
+		t.Helper()
+		id, err := st.CreateOrGetTodaySession(-100, date, 0, deadline)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	stale := session("2024-05-03", now.Add(-72*time.Hour))
+	published := session("2024-05-04", now.Add(-48*time.Hour))
+	if err := st.CloseSession(published); err != nil {
+		t.Fatal(err)
+	}
+	// claimed by a closer that is still publishing it
+	publishing := session("2024-05-02", now.Add(-96*time.Hour))
+	if ok, err := st.ClaimSessionForClose(publishing, now); err != nil || !ok {
+		t.Fatalf("claim = %v, %v", ok, err)
+	}
+	due := session("2024-05-06", now.Add(-time.Minute))
+	session("2024-05-07", now.Add(time.Hour))
+
+	s := New(st)
+	s.Clock = clk
+	s.MaxSessionAge = 24 * time.Hour
+	abandoned := make(chan []int64, 1)
+	closed := make(chan []int64, 1)
+	s.OnAbandonSessions = func(ids []int64) { abandoned <- ids }
+	s.OnCloseSessions = func(ids []int64) { closed <- ids }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.loopCloser(ctx)
+	<-clk.waits
+	clk.advance(DefaultCloseInterval)
+
+	receive := func(ch chan []int64, what string) []int64 {
+		t.Helper()
+		select {
+		case ids := <-ch:
+			return ids
+		case <-time.After(time.Second):
+			t.Fatalf("no %s sessions", what)
+		}
+		return nil
+	}
+	if got := receive(abandoned, "abandoned"); len(got) != 1 || got[0] != stale {
+		t.Errorf("abandoned = %v, want [%d]", got, stale)
+	}
+	// the abandoned session is not handed over for publishing, the fresh one is; a claimed one
+	// is left to ClaimSessionForClose
+	got := receive(closed, "closed")
+	want := map[int64]bool{due: true, publishing: true}
+	if len(got) != len(want) || !want[got[0]] || !want[got[1]] {
+		t.Errorf("closed = %v, want %d and %d", got, due, publishing)
+	}
+	if ok, err := st.ClaimSessionForClose(stale, now); err != nil || ok {
+		t.Errorf("abandoned session claimed for publishing = %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"coffeetrix24/internal/metrics"
+)
+
+// zonedFireWindow is how long after a chat's local daily time its invite may still go out, so a
+// late minute tick does not skip the slot. The bot's per-slot dedup prevents a second invite.
+const zonedFireWindow = 10 * time.Minute
+
+// zonedDue returns the chats whose latest local fire of today passed less than window ago, each
+// with the slot of that fire.
+func zonedDue(sc schedule, zones map[int64]*time.Location, now time.Time, window time.Duration) map[int64]int {
+	due := map[int64]int{}
+	for chatID, loc := range zones {
+		if last, ok := lastToday(sc, now, loc); ok && now.Sub(last) < window {
+			due[chatID] = slotAt(sc, now, loc)
+		}
+	}
+	return due
+}
+
+// loopZoned fires OnZonedInvite for chats with their own timezone. Each minute it checks every
+// such chat against the daily times in its zone; a chat fires at most once per local slot. The
+// first check looks back CatchUpWindow, if longer, to catch up on invites missed while down.
+func (s *Scheduler) loopZoned(ctx context.Context) {
+	log.Println("scheduler: loopZoned start")
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	fired := map[int64]string{}
+	window := zonedFireWindow
+	if s.CatchUpWindow > window {
+		window = s.CatchUpWindow
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		metrics.SchedulerIterations.Inc("zoned")
+		names, err := s.Store.ChatTimezones()
+		if err != nil {
+			log.Println("zoned error:", err)
+			continue
+		}
+		if len(names) == 0 {
+			continue
+		}
+		daily, err := s.Store.GetDailyTime()
+		if err != nil {
+			continue
+		}
+		zones := make(map[int64]*time.Location, len(names))
+		for chatID, name := range names {
+			loc, err := time.LoadLocation(name)
+			if err != nil {
+				log.Printf("scheduler: skip chat=%d bad timezone %q: %v", chatID, name, err)
+				continue
+			}
+			zones[chatID] = loc
+		}
+		sc := parseDaily(daily)
+		now := s.Clock.Now()
+		var ids []int64
+		due := zonedDue(sc, zones, now, window)
+		window = zonedFireWindow
+		for chatID, slot := range due {
+			key := fmt.Sprintf("%s/%d", now.In(zones[chatID]).Format("2006-01-02"), slot)
+			if fired[chatID] == key {
+				continue
+			}
+			fired[chatID] = key
+			ids = append(ids, chatID)
+		}
+		if len(ids) > 0 {
+			log.Printf("scheduler: firing zoned invites chats=%v daily_time=%s", ids, sc)
+			s.OnZonedInvite(ids)
+		}
+	}
+}
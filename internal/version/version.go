@@ -1,4 +1,43 @@
 package version
-package version
 
-const Version = "0.1.0-debug"
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// Version is the release the binary was built as, set at build time with
+// -ldflags "-X coffeetrix24/internal/version.Version=v1.2.3". Unset builds report "dev".
+var Version = "dev"
+
+// String is Version for release builds. For dev builds it adds the VCS revision recorded by the
+// Go toolchain, if any, e.g. "dev (1a2b3c4d5e6f, modified)".
+func String() string {
+	if Version != "dev" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Version
+	}
+	var rev string
+	var modified bool
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if rev == "" {
+		return Version
+	}
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	parts := []string{rev}
+	if modified {
+		parts = append(parts, "modified")
+	}
+	return Version + " (" + strings.Join(parts, ", ") + ")"
+}